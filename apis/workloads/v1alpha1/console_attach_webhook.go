@@ -7,14 +7,85 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/gocardless/theatre/v3/pkg/logging"
 )
 
+// AttachEventTimeout bounds how long the attach webhook spends publishing each
+// lifecycle event, which it does after responding to the request
+const AttachEventTimeout = 30 * time.Second
+
+// ConsoleAttachPolicy configures whether the attach webhook only observes
+// attachments to console pods, or also enforces who may attach to them.
+//
+// +kubebuilder:object:generate=false
+type ConsoleAttachPolicy struct {
+	// Enforce denies attach and exec requests to console pods from anyone who
+	// has not been granted access to the console.
+	Enforce bool
+	// BreakGlassGroups are Kubernetes groups whose members are always permitted
+	// to attach, even when they have not been granted access to the console.
+	BreakGlassGroups []string
+}
+
+// Permits determines whether a user may attach to a console. The owner of the
+// console is always permitted, as is any member of a break-glass group.
+// Otherwise the user must be a subject of the console's RoleBinding, which the
+// controller populates with the template's additional attach subjects, the
+// console's authorisers and any users the console has been shared with.
+func (p ConsoleAttachPolicy) Permits(user authenticationv1.UserInfo, csl *Console, rb *rbacv1.RoleBinding) (bool, string) {
+	if user.Username == csl.Spec.User {
+		return true, "user is the console owner"
+	}
+
+	for _, group := range p.BreakGlassGroups {
+		if containsString(user.Groups, group) {
+			return true, fmt.Sprintf("user is a member of break-glass group %s", group)
+		}
+	}
+
+	if subject, reason := IsAttachSubject(user, rb); subject {
+		return true, reason
+	}
+
+	return false, "user is not the console owner, an attach subject or a member of a break-glass group"
+}
+
+// IsAttachSubject determines whether a user is a subject of the console's
+// RoleBinding, returning why if so. The RoleBinding is nil if it doesn't exist.
+func IsAttachSubject(user authenticationv1.UserInfo, rb *rbacv1.RoleBinding) (bool, string) {
+	if rb == nil {
+		return false, ""
+	}
+
+	for _, subject := range rb.Subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			if subject.Name == user.Username {
+				return true, "user is an attach subject of the console"
+			}
+		case rbacv1.GroupKind:
+			if containsString(user.Groups, subject.Name) {
+				return true, fmt.Sprintf("user is a member of attach subject group %s", subject.Name)
+			}
+		case rbacv1.ServiceAccountKind:
+			if fmt.Sprintf("system:serviceaccount:%s:%s", subject.Namespace, subject.Name) == user.Username {
+				return true, "user is an attach subject of the console"
+			}
+		}
+	}
+
+	return false, ""
+}
+
 // +kubebuilder:object:generate=false
 type ConsoleAttachObserverWebhook struct {
 	client            client.Client
@@ -23,15 +94,17 @@ type ConsoleAttachObserverWebhook struct {
 	logger            logr.Logger
 	decoder           *admission.Decoder
 	requestTimeout    time.Duration
+	policy            ConsoleAttachPolicy
 }
 
-func NewConsoleAttachObserverWebhook(c client.Client, recorder record.EventRecorder, lifecycleRecorder LifecycleEventRecorder, logger logr.Logger, requestTimeout time.Duration) *ConsoleAttachObserverWebhook {
+func NewConsoleAttachObserverWebhook(c client.Client, recorder record.EventRecorder, lifecycleRecorder LifecycleEventRecorder, logger logr.Logger, requestTimeout time.Duration, policy ConsoleAttachPolicy) *ConsoleAttachObserverWebhook {
 	return &ConsoleAttachObserverWebhook{
 		client:            c,
 		recorder:          recorder,
 		lifecycleRecorder: lifecycleRecorder,
 		logger:            logger,
 		requestTimeout:    requestTimeout,
+		policy:            policy,
 	}
 }
 
@@ -52,10 +125,24 @@ func (c *ConsoleAttachObserverWebhook) Handle(ctx context.Context, req admission
 		logging.WithNoRecord(logger).Info("completed request", "event", "request.end", "duration", time.Since(start).Seconds())
 	}(time.Now())
 
-	attachOptions := &corev1.PodAttachOptions{}
-	if err := c.decoder.Decode(req, attachOptions); err != nil {
-		logger.Error(err, "failed to decode attach options")
-		return admission.Errored(http.StatusBadRequest, err)
+	// Both attach and exec requests are handled by this webhook, and these
+	// carry different options. We only need to know the target container.
+	var containerName string
+	switch req.SubResource {
+	case "exec":
+		execOptions := &corev1.PodExecOptions{}
+		if err := c.decoder.Decode(req, execOptions); err != nil {
+			logger.Error(err, "failed to decode exec options")
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		containerName = execOptions.Container
+	default:
+		attachOptions := &corev1.PodAttachOptions{}
+		if err := c.decoder.Decode(req, attachOptions); err != nil {
+			logger.Error(err, "failed to decode attach options")
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		containerName = attachOptions.Container
 	}
 
 	rctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
@@ -80,6 +167,12 @@ func (c *ConsoleAttachObserverWebhook) Handle(ctx context.Context, req admission
 		return admission.Allowed("not a console; skipping observation")
 	}
 
+	// Requests that don't name a container are for the console container,
+	// which is always the first
+	if containerName == "" && len(pod.Spec.Containers) > 0 {
+		containerName = pod.Spec.Containers[0].Name
+	}
+
 	rctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
 	defer cancel()
 
@@ -104,6 +197,55 @@ func (c *ConsoleAttachObserverWebhook) Handle(ctx context.Context, req admission
 		"event", "console.attach",
 	)
 
+	rctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+
+	// The controller names the console's RoleBinding (via the
+	// DirectoryRoleBinding) after the console. It won't exist until the console
+	// is running, in which case only the owner and break-glass groups are
+	// permitted. Attaches are recorded with whether the user is one of its
+	// subjects, so auditors can tell them apart from other non-owners. Only
+	// enforcement needs it, so otherwise the attach is recorded without it.
+	var roleBinding *rbacv1.RoleBinding
+	rb := &rbacv1.RoleBinding{}
+	if err := c.client.Get(rctx, client.ObjectKey{
+		Namespace: csl.Namespace,
+		Name:      csl.Name,
+	}, rb); err == nil {
+		roleBinding = rb
+	} else if !apierrors.IsNotFound(err) {
+		logger.Error(err, "failed to get console rolebinding", "console", csl.Name)
+		if c.policy.Enforce {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	if c.policy.Enforce {
+		permitted, reason := c.policy.Permits(req.UserInfo, csl, roleBinding)
+		if !permitted {
+			msg := fmt.Sprintf(
+				"denied %s to pod %s/%s by user %s: %s",
+				req.SubResource, pod.Namespace, pod.Name, req.UserInfo.Username, reason,
+			)
+			logging.WithEventRecorder(logger.GetSink(), c.recorder, pod).Info(
+				msg,
+				"event", "ConsoleAttachDenied",
+				"error", msg,
+			)
+
+			// Dry runs are denied all the same, but nobody attempted to attach
+			if !*req.DryRun {
+				c.recordEvent(logger, "console.attach_denied", func(ctx context.Context) error {
+					return c.lifecycleRecorder.ConsoleAttachDenied(ctx, csl, req.UserInfo.Username, containerName, req.SubResource, reason)
+				})
+			}
+
+			return admission.Denied(reason)
+		}
+
+		logger.Info("attach permitted", "console", csl.Name, "reason", reason)
+	}
+
 	// If performing a dry-run we only want to log the attachment.
 	if *req.DryRun {
 		// Log an event observing the attachment
@@ -129,11 +271,55 @@ func (c *ConsoleAttachObserverWebhook) Handle(ctx context.Context, req admission
 			pod.Namespace, pod.Name, req.UserInfo.Username,
 		),
 		"event", "ConsoleAttach",
+		"collaborator", csl.IsCollaborator(req.UserInfo.Username),
 	)
-	err := c.lifecycleRecorder.ConsoleAttach(ctx, csl, req.UserInfo.Username, attachOptions.Container)
-	if err != nil {
-		logging.WithNoRecord(logger).Error(err, "failed to record event")
+	attachSubject, _ := IsAttachSubject(req.UserInfo, roleBinding)
+	c.recordEvent(logger, "console.attach", func(ctx context.Context) error {
+		return c.lifecycleRecorder.ConsoleAttach(ctx, csl, req.UserInfo.Username, containerName, req.SubResource, attachSubject)
+	})
+
+	// Only attachments open a session in the console status, which the
+	// controller closes once the container terminates or the console stops.
+	// Exec requests run their own process, so are not sessions of the console.
+	if req.SubResource == "attach" {
+		rctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+
+		if err := updateConsoleStatus(rctx, c.client, csl, func(csl *Console) {
+			csl.StartAttachSession(req.UserInfo.Username, containerName, time.Now())
+		}); err != nil {
+			logging.WithNoRecord(logger).Error(err, "failed to record attach session in console status")
+		}
 	}
 
 	return admission.Allowed("attachment observed")
 }
+
+// recordEvent publishes a lifecycle event without holding up the response.
+// Publishers may retry for longer than the API server waits for the webhook,
+// after which it applies the webhook's failure policy to the request, so would
+// allow a denied attach when that is Ignore.
+func (c *ConsoleAttachObserverWebhook) recordEvent(logger logr.Logger, event string, record func(context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), AttachEventTimeout)
+		defer cancel()
+
+		if err := record(ctx); err != nil {
+			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", event)
+		}
+	}()
+}
+
+// updateConsoleStatus applies the given change to the latest version of the
+// console, retrying if it conflicts with an update made by the controller
+func updateConsoleStatus(ctx context.Context, c client.Client, csl *Console, update func(*Console)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &Console{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(csl), latest); err != nil {
+			return err
+		}
+
+		update(latest)
+		return c.Update(ctx, latest)
+	})
+}
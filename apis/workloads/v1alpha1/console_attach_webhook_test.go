@@ -0,0 +1,114 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+var _ = Describe("Attach webhook", func() {
+	Describe("ConsoleAttachPolicy Permits", func() {
+		var (
+			policy      ConsoleAttachPolicy
+			user        authenticationv1.UserInfo
+			roleBinding *rbacv1.RoleBinding
+			permitted   bool
+		)
+
+		csl := &Console{Spec: ConsoleSpec{User: "owner@example.com"}}
+
+		BeforeEach(func() {
+			policy = ConsoleAttachPolicy{
+				Enforce:          true,
+				BreakGlassGroups: []string{"break-glass"},
+			}
+			user = authenticationv1.UserInfo{Username: "someone@example.com"}
+			roleBinding = &rbacv1.RoleBinding{
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.UserKind, Name: "owner@example.com"},
+					{Kind: rbacv1.UserKind, Name: "authoriser@example.com"},
+					{Kind: rbacv1.GroupKind, Name: "platform"},
+					{Kind: rbacv1.ServiceAccountKind, Namespace: "ci", Name: "tekton"},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			permitted, _ = policy.Permits(user, csl, roleBinding)
+		})
+
+		Context("when the user is the console owner", func() {
+			BeforeEach(func() {
+				user.Username = "owner@example.com"
+				roleBinding = nil
+			})
+
+			It("permits the attach", func() {
+				Expect(permitted).To(BeTrue())
+			})
+		})
+
+		Context("when the user is a subject of the rolebinding", func() {
+			BeforeEach(func() {
+				user.Username = "authoriser@example.com"
+			})
+
+			It("permits the attach", func() {
+				Expect(permitted).To(BeTrue())
+			})
+		})
+
+		Context("when the user is a member of a group subject", func() {
+			BeforeEach(func() {
+				user.Groups = []string{"platform"}
+			})
+
+			It("permits the attach", func() {
+				Expect(permitted).To(BeTrue())
+			})
+		})
+
+		Context("when the user is a service account subject", func() {
+			BeforeEach(func() {
+				user.Username = "system:serviceaccount:ci:tekton"
+			})
+
+			It("permits the attach", func() {
+				Expect(permitted).To(BeTrue())
+			})
+		})
+
+		Context("when the user is a member of a break-glass group", func() {
+			BeforeEach(func() {
+				user.Groups = []string{"break-glass"}
+				roleBinding = nil
+			})
+
+			It("permits the attach", func() {
+				Expect(permitted).To(BeTrue())
+			})
+		})
+
+		Context("when the user has not been granted access", func() {
+			BeforeEach(func() {
+				user.Groups = []string{"system:masters"}
+			})
+
+			It("denies the attach", func() {
+				Expect(permitted).To(BeFalse())
+			})
+		})
+
+		Context("when the console has no rolebinding yet", func() {
+			BeforeEach(func() {
+				user.Username = "authoriser@example.com"
+				roleBinding = nil
+			})
+
+			It("denies the attach", func() {
+				Expect(permitted).To(BeFalse())
+			})
+		})
+	})
+})
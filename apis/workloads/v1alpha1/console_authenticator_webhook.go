@@ -2,12 +2,16 @@ package v1alpha1
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -16,12 +20,17 @@ type ConsoleAuthenticatorWebhook struct {
 	lifecycleRecorder LifecycleEventRecorder
 	logger            logr.Logger
 	decoder           *admission.Decoder
+	managerUsername   string
 }
 
-func NewConsoleAuthenticatorWebhook(lifecycleRecorder LifecycleEventRecorder, logger logr.Logger) *ConsoleAuthenticatorWebhook {
+// NewConsoleAuthenticatorWebhook creates the webhook that sets the user of new
+// consoles. The manager's username identifies the requests that may change
+// what only the manager sets, which anyone may change if it is empty.
+func NewConsoleAuthenticatorWebhook(lifecycleRecorder LifecycleEventRecorder, logger logr.Logger, managerUsername string) *ConsoleAuthenticatorWebhook {
 	return &ConsoleAuthenticatorWebhook{
 		lifecycleRecorder: lifecycleRecorder,
 		logger:            logger,
+		managerUsername:   managerUsername,
 	}
 }
 
@@ -42,10 +51,24 @@ func (c *ConsoleAuthenticatorWebhook) Handle(ctx context.Context, req admission.
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	if req.Operation == admissionv1.Update {
+		return c.validateUpdate(logger, req, csl)
+	}
+
 	user := req.UserInfo.Username
+	if _, ok := csl.Labels[ConsoleScheduleLabel]; ok && !c.isManager(user) {
+		logger.Info("rejected schedule label", "event", "authentication.failure", "user", user)
+		return admission.Denied(fmt.Sprintf("the %s label can only be set by the workloads-manager", ConsoleScheduleLabel))
+	}
+
 	copy := csl.DeepCopy()
 	copy.Spec.User = user
 
+	if err := setBatchLabel(copy, user); err != nil {
+		logger.Info("invalid batch", "event", "authentication.failure", "error", err)
+		return admission.Denied(err.Error())
+	}
+
 	copyBytes, err := json.Marshal(copy)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
@@ -55,3 +78,67 @@ func (c *ConsoleAuthenticatorWebhook) Handle(ctx context.Context, req admission.
 
 	return admission.PatchResponseFromRaw(req.Object.Raw, copyBytes)
 }
+
+// validateUpdate prevents users other than the manager from changing what only
+// the manager records on a console
+func (c *ConsoleAuthenticatorWebhook) validateUpdate(logger logr.Logger, req admission.Request, csl *Console) admission.Response {
+	if c.isManager(req.UserInfo.Username) {
+		return admission.Allowed("update by the manager")
+	}
+
+	old := &Console{}
+	if err := c.decoder.DecodeRaw(req.OldObject, old); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if !equality.Semantic.DeepEqual(old.Status.AttachSessions, csl.Status.AttachSessions) {
+		logger.Info("rejected change to attach sessions", "event", "authentication.failure", "user", req.UserInfo.Username)
+		return admission.Denied("status.attachSessions can only be changed by the workloads-manager")
+	}
+
+	// These identify who created the console, and how, so are trusted by
+	// authorisers and the controller
+	if old.Spec.User != csl.Spec.User {
+		logger.Info("rejected change to user", "event", "authentication.failure", "user", req.UserInfo.Username)
+		return admission.Denied("spec.user can't be changed")
+	}
+	for _, label := range []string{ConsoleBatchLabel, ConsoleScheduleLabel} {
+		if old.Labels[label] != csl.Labels[label] {
+			logger.Info("rejected change to label", "event", "authentication.failure", "user", req.UserInfo.Username, "label", label)
+			return admission.Denied(fmt.Sprintf("the %s label can't be changed", label))
+		}
+	}
+
+	return admission.Allowed("update doesn't change what the manager records")
+}
+
+func (c *ConsoleAuthenticatorWebhook) isManager(username string) bool {
+	return c.managerUsername == "" || username == c.managerUsername
+}
+
+var batchPattern = regexp.MustCompile(`^[a-z0-9]{1,36}$`)
+
+// setBatchLabel labels a console with the batch requested by its annotation,
+// qualified by the user creating it. The label can't be set directly, as
+// authorisers trust it to identify consoles created together.
+func setBatchLabel(csl *Console, user string) error {
+	if _, ok := csl.Labels[ConsoleBatchLabel]; ok {
+		return fmt.Errorf("the %s label can't be set directly, use the %s annotation", ConsoleBatchLabel, ConsoleBatchAnnotation)
+	}
+
+	batch, ok := csl.Annotations[ConsoleBatchAnnotation]
+	if !ok {
+		return nil
+	}
+	if !batchPattern.MatchString(batch) {
+		return fmt.Errorf("the %s annotation must be up to 36 lowercase letters and digits", ConsoleBatchAnnotation)
+	}
+
+	if csl.Labels == nil {
+		csl.Labels = map[string]string{}
+	}
+	userHash := sha256.Sum256([]byte(user))
+	csl.Labels[ConsoleBatchLabel] = fmt.Sprintf("%s-%x", batch, userHash[:4])
+
+	return nil
+}
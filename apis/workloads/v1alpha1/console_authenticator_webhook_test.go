@@ -0,0 +1,138 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Console authenticator webhook", func() {
+	Describe("setBatchLabel", func() {
+		var csl *Console
+
+		BeforeEach(func() {
+			csl = &Console{ObjectMeta: metav1.ObjectMeta{Name: "console"}}
+		})
+
+		It("leaves consoles without a batch alone", func() {
+			Expect(setBatchLabel(csl, "alice@example.com")).To(Succeed())
+			Expect(csl.Labels).NotTo(HaveKey(ConsoleBatchLabel))
+		})
+
+		It("labels the console with the requested batch, qualified by the user", func() {
+			csl.Annotations = map[string]string{ConsoleBatchAnnotation: "1a2b3c4d"}
+			Expect(setBatchLabel(csl, "alice@example.com")).To(Succeed())
+
+			label := csl.Labels[ConsoleBatchLabel]
+			Expect(label).To(MatchRegexp(`^1a2b3c4d-[0-9a-f]{8}$`))
+
+			other := &Console{ObjectMeta: metav1.ObjectMeta{Annotations: csl.Annotations}}
+			Expect(setBatchLabel(other, "mallory@example.com")).To(Succeed())
+			Expect(other.Labels[ConsoleBatchLabel]).NotTo(Equal(label))
+		})
+
+		It("rejects a batch label set by the client", func() {
+			csl.Labels = map[string]string{ConsoleBatchLabel: "1a2b3c4d-00000000"}
+			Expect(setBatchLabel(csl, "alice@example.com")).To(MatchError(ContainSubstring("can't be set directly")))
+		})
+
+		It("rejects an invalid batch", func() {
+			csl.Annotations = map[string]string{ConsoleBatchAnnotation: "Not a batch!"}
+			Expect(setBatchLabel(csl, "alice@example.com")).To(MatchError(ContainSubstring("lowercase letters and digits")))
+		})
+	})
+
+	Describe("Handle", func() {
+		var (
+			webhook  *ConsoleAuthenticatorWebhook
+			old, new *Console
+		)
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(AddToScheme(scheme)).To(Succeed())
+			decoder, err := admission.NewDecoder(scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			webhook = NewConsoleAuthenticatorWebhook(nil, logr.Discard(), "system:serviceaccount:theatre-system:workloads-manager")
+			Expect(webhook.InjectDecoder(decoder)).To(Succeed())
+
+			old = &Console{ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "default"}}
+			old.StartAttachSession("alice@example.com", "console", time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+			new = old.DeepCopy()
+		})
+
+		create := func(username string) admission.Response {
+			raw, err := json.Marshal(new)
+			Expect(err).NotTo(HaveOccurred())
+
+			return webhook.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					UserInfo:  authenticationv1.UserInfo{Username: username},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+		}
+
+		update := func(username string) admission.Response {
+			oldRaw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			newRaw, err := json.Marshal(new)
+			Expect(err).NotTo(HaveOccurred())
+
+			return webhook.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					UserInfo:  authenticationv1.UserInfo{Username: username},
+					Object:    runtime.RawExtension{Raw: newRaw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			})
+		}
+
+		It("allows users to update the console without changing its attach sessions", func() {
+			new.Labels = map[string]string{"team": "payments"}
+			Expect(update("alice@example.com").Allowed).To(BeTrue())
+		})
+
+		It("rejects users changing its attach sessions", func() {
+			new.Status.AttachSessions = nil
+			Expect(update("alice@example.com").Allowed).To(BeFalse())
+		})
+
+		It("allows the manager to change its attach sessions", func() {
+			new.EndAttachSessions("", time.Date(2021, 1, 1, 13, 0, 0, 0, time.UTC))
+			Expect(update("system:serviceaccount:theatre-system:workloads-manager").Allowed).To(BeTrue())
+		})
+
+		It("rejects users changing its user", func() {
+			new.Spec.User = "mallory@example.com"
+			Expect(update("alice@example.com").Allowed).To(BeFalse())
+		})
+
+		It("rejects users changing its schedule label", func() {
+			new.Labels = map[string]string{ConsoleScheduleLabel: "cleanup"}
+			Expect(update("alice@example.com").Allowed).To(BeFalse())
+		})
+
+		It("rejects users creating a console with a schedule label", func() {
+			new.Labels = map[string]string{ConsoleScheduleLabel: "cleanup"}
+			Expect(create("alice@example.com").Allowed).To(BeFalse())
+		})
+
+		It("allows the manager to create a console with a schedule label", func() {
+			new.Labels = map[string]string{ConsoleScheduleLabel: "cleanup"}
+			Expect(create("system:serviceaccount:theatre-system:workloads-manager").Allowed).To(BeTrue())
+		})
+	})
+})
@@ -17,6 +17,10 @@ import (
 	rbacutils "github.com/gocardless/theatre/v3/pkg/rbac"
 )
 
+// ErrAuthoriserNotCurrentUser is returned when a user tries to add someone else
+// as an authoriser
+var ErrAuthoriserNotCurrentUser = errors.New("only the current user can be added as an authoriser")
+
 // +kubebuilder:object:generate=false
 type ConsoleAuthorisationWebhook struct {
 	client            client.Client
@@ -66,10 +70,11 @@ func (c *ConsoleAuthorisationWebhook) Handle(ctx context.Context, req admission.
 	}
 
 	update := &ConsoleAuthorisationUpdate{
-		existingAuth: existingAuth,
-		updatedAuth:  updatedAuth,
-		user:         user,
-		owner:        csl.Spec.User,
+		existingAuth:  existingAuth,
+		updatedAuth:   updatedAuth,
+		user:          user,
+		owner:         csl.Spec.User,
+		collaborators: csl.Spec.SharedWith,
 	}
 
 	if err := update.Validate(); err != nil {
@@ -78,6 +83,13 @@ func (c *ConsoleAuthorisationWebhook) Handle(ctx context.Context, req admission.
 	}
 
 	logger.Info("authorisation successful", "event", "authorisation.success")
+
+	// Clients dry-run authorisations to find out whether they're eligible to
+	// authorise a console
+	if req.DryRun != nil && *req.DryRun {
+		return admission.ValidationResponse(true, "")
+	}
+
 	err = c.lifecycleRecorder.ConsoleAuthorise(ctx, csl, user)
 	if err != nil {
 		logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.authorise")
@@ -98,10 +110,11 @@ func (c *ConsoleAuthorisationWebhook) getConsole(ctx context.Context, name, name
 }
 
 type ConsoleAuthorisationUpdate struct {
-	existingAuth *ConsoleAuthorisation
-	updatedAuth  *ConsoleAuthorisation
-	user         string
-	owner        string
+	existingAuth  *ConsoleAuthorisation
+	updatedAuth   *ConsoleAuthorisation
+	user          string
+	owner         string
+	collaborators []string
 }
 
 func (u *ConsoleAuthorisationUpdate) Validate() error {
@@ -123,7 +136,7 @@ func (u *ConsoleAuthorisationUpdate) Validate() error {
 	// check the user is only adding themselves to the list of authorisers
 	for _, s := range add {
 		if s.Name != u.user {
-			err = multierror.Append(err, errors.New("only the current user can be added as an authoriser"))
+			err = multierror.Append(err, ErrAuthoriserNotCurrentUser)
 			break
 		}
 	}
@@ -136,5 +149,14 @@ func (u *ConsoleAuthorisationUpdate) Validate() error {
 		}
 	}
 
+	// check that a user the console has been shared with isn't adding themselves
+	// to the list of authorisers
+	for _, s := range add {
+		if containsString(u.collaborators, s.Name) {
+			err = multierror.Append(err, errors.New("an authoriser cannot authorise a console that has been shared with them"))
+			break
+		}
+	}
+
 	return err
 }
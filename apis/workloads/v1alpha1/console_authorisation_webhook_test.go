@@ -28,19 +28,25 @@ var _ = Describe("Authorisation webhook", func() {
 	Describe("Validate", func() {
 		var (
 			updateFixture string
+			collaborators []string
 			update        *ConsoleAuthorisationUpdate
 			err           error
 		)
 
 		existingAuth := mustConsoleAuthorisationFixture("./testdata/console_authorisation_existing.yaml")
 
+		BeforeEach(func() {
+			collaborators = nil
+		})
+
 		JustBeforeEach(func() {
 			updatedAuth := mustConsoleAuthorisationFixture(updateFixture)
 			update = &ConsoleAuthorisationUpdate{
-				existingAuth: existingAuth,
-				updatedAuth:  updatedAuth,
-				user:         "current-user",
-				owner:        "user",
+				existingAuth:  existingAuth,
+				updatedAuth:   updatedAuth,
+				user:          "current-user",
+				owner:         "user",
+				collaborators: collaborators,
 			}
 
 			err = update.Validate()
@@ -101,6 +107,18 @@ var _ = Describe("Authorisation webhook", func() {
 			})
 		})
 
+		Context("Adding an authoriser who the console has been shared with", func() {
+			BeforeEach(func() {
+				updateFixture = "./testdata/console_authorisation_update_add.yaml"
+				collaborators = []string{"current-user"}
+			})
+
+			It("Returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(ContainSubstring("cannot authorise a console that has been shared with them")))
+			})
+		})
+
 		Context("Changing immutable fields", func() {
 			BeforeEach(func() {
 				updateFixture = "./testdata/console_authorisation_update_immutables.yaml"
@@ -0,0 +1,87 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConsoleHistorySpec is a compact record of a console, written by the
+// controller before the console is deleted
+type ConsoleHistorySpec struct {
+	// Name of the console, which may since have been reused
+	ConsoleName string `json:"consoleName"`
+	User        string `json:"user"`
+	Reason      string `json:"reason"`
+
+	ConsoleTemplateRef corev1.LocalObjectReference `json:"consoleTemplateRef"`
+	// Generation of the template and hash of the pod spec that the console ran
+	// with, as recorded in its status
+	// +optional
+	TemplateGeneration int64 `json:"templateGeneration,omitempty"`
+	// +optional
+	TemplateHash string `json:"templateHash,omitempty"`
+
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// +optional
+	Noninteractive bool `json:"noninteractive,omitempty"`
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// +optional
+	SharedWith []string `json:"sharedWith,omitempty"`
+
+	// Users that authorised the console
+	// +optional
+	Authorisers []string `json:"authorisers,omitempty"`
+
+	// Phase of the console when it was deleted
+	Phase ConsolePhase `json:"phase"`
+	// Time at which the console was created
+	CreationTime metav1.Time `json:"creationTime"`
+	// Time at which the console's job started, unset if it never did
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Time at which the console's job finished, or at which the console was
+	// deleted if it was still running
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+	// Exit code of the console's container, unset if it wasn't observed to exit
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+	// Sessions of the users that attached to the console
+	// +optional
+	AttachSessions []ConsoleAttachSession `json:"attachSessions,omitempty"`
+
+	// Time after which the record is deleted
+	ExpiryTime metav1.Time `json:"expiryTime"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+
+// ConsoleHistory records a console that has been deleted, so that it can be
+// listed once the console has gone. It is deleted after its expiry time.
+// +kubebuilder:printcolumn:name="Console",type="string",JSONPath=".spec.consoleName"
+// +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".spec.phase"
+// +kubebuilder:printcolumn:name="Exit Code",type="integer",JSONPath=".spec.exitCode"
+// +kubebuilder:printcolumn:name="Created",type="date",JSONPath=".spec.creationTime"
+type ConsoleHistory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ConsoleHistorySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ConsoleHistoryList contains a list of ConsoleHistory
+type ConsoleHistoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConsoleHistory `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConsoleHistory{}, &ConsoleHistoryList{})
+}
@@ -0,0 +1,172 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	DefaultTTLBeforeRunning = 1 * time.Hour
+	DefaultTTLAfterFinished = 24 * time.Hour
+
+	// ConsoleOutputSecretKey is the key of the captured output in the secret
+	// holding a non-interactive console's output
+	ConsoleOutputSecretKey = "output"
+)
+
+// WithTemplateTTLs returns a copy of the console with its TTLs set, falling
+// back to the template's defaults and then the controller's defaults
+func (c *Console) WithTemplateTTLs(template *ConsoleTemplate) *Console {
+	defaultTTLSecondsBeforeRunning := int32(DefaultTTLBeforeRunning.Seconds())
+	defaultTTLSecondsAfterFinished := int32(DefaultTTLAfterFinished.Seconds())
+
+	updatedCsl := c.DeepCopy()
+
+	if c.Spec.TTLSecondsBeforeRunning != nil {
+		updatedCsl.Spec.TTLSecondsBeforeRunning = c.Spec.TTLSecondsBeforeRunning
+	} else if template.Spec.DefaultTTLSecondsBeforeRunning != nil {
+		updatedCsl.Spec.TTLSecondsBeforeRunning = template.Spec.DefaultTTLSecondsBeforeRunning
+	} else {
+		updatedCsl.Spec.TTLSecondsBeforeRunning = &defaultTTLSecondsBeforeRunning
+	}
+
+	if c.Spec.TTLSecondsAfterFinished != nil {
+		updatedCsl.Spec.TTLSecondsAfterFinished = c.Spec.TTLSecondsAfterFinished
+	} else if template.Spec.DefaultTTLSecondsAfterFinished != nil {
+		updatedCsl.Spec.TTLSecondsAfterFinished = template.Spec.DefaultTTLSecondsAfterFinished
+	} else {
+		updatedCsl.Spec.TTLSecondsAfterFinished = &defaultTTLSecondsAfterFinished
+	}
+
+	return updatedCsl
+}
+
+// WithTemplateTimeout returns a copy of the console with a timeout between
+// [0, template.MaxTimeoutSeconds], or the template's default if unset. It also
+// returns whether the requested timeout was reduced to the maximum.
+func (c *Console) WithTemplateTimeout(template *ConsoleTemplate) (*Console, bool) {
+	var timeout int
+	max := template.Spec.MaxTimeoutSeconds
+	reduced := false
+
+	switch {
+	case c.Spec.TimeoutSeconds < 1:
+		timeout = template.Spec.DefaultTimeoutSeconds
+	case c.Spec.TimeoutSeconds > max:
+		timeout = max
+		reduced = true
+	default:
+		timeout = c.Spec.TimeoutSeconds
+	}
+
+	updatedCsl := c.DeepCopy()
+	updatedCsl.Spec.TimeoutSeconds = timeout
+
+	return updatedCsl, reduced
+}
+
+// BuildJob returns the job that runs the console, before the controller adds
+// any session recording to its pod template
+func (c *Console) BuildJob(template *ConsoleTemplate) *batchv1.Job {
+	timeout := int64(c.Spec.TimeoutSeconds)
+
+	username := strings.SplitN(c.Spec.User, "@", 2)[0]
+	jobTemplate := template.Spec.Template.DeepCopy()
+
+	// If there are no containers in the spec then the controller will be emitting
+	// warnings anyway, as the job will be rejected
+	if len(jobTemplate.Spec.Containers) > 0 {
+		container := &jobTemplate.Spec.Containers[0]
+
+		// Only replace the template command if one is specified
+		if len(c.Spec.Command) > 0 {
+			container.Command = c.Spec.Command[:1]
+			container.Args = c.Spec.Command[1:]
+		}
+
+		if !c.Spec.Noninteractive {
+			// Set these properties to ensure that it's possible to send input to the
+			// container when attaching
+			container.Stdin = true
+			container.TTY = true
+		}
+	}
+
+	// Job API SetDefaults_Job
+	// https://github.com/kubernetes/kubernetes/blob/master/pkg/apis/batch/v1/defaults.go#L28
+	completions := int32(1)
+	parallelism := int32(1)
+
+	// Do not retry console jobs if they fail. There is no guarantee that the
+	// command that the user submits will be idempotent.
+	// This also prevents multiple pods from being spawned by a job, which is
+	// important as other parts of the controller assume there will only ever be
+	// 1 pod per job.
+	backoffLimit := int32(0)
+	jobTemplate.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	// Merged labels from the console template and console. In case of
+	// conflicts second label set wins.
+	// The labels on the console can be user-defined, so we do not want to allow a
+	// user to create a console with a label that implies that it's for an application
+	// different to the console.
+	jobLabels := labels.Merge(c.Labels, template.Labels)
+	jobLabels = labels.Merge(jobLabels,
+		map[string]string{
+			"console-name": sanitiseLabel(c.Name),
+			"user":         sanitiseLabel(username),
+		})
+
+	jobTemplate.ObjectMeta.Labels = labels.Merge(
+		jobLabels,
+		jobTemplate.ObjectMeta.Labels,
+	)
+
+	podTemplate := (*corev1.PodTemplateSpec)(jobTemplate)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConsoleJobName(c.Name),
+			Namespace: c.Namespace,
+			Labels:    jobLabels,
+		},
+		Spec: batchv1.JobSpec{
+			Template:              *podTemplate,
+			Completions:           &completions,
+			Parallelism:           &parallelism,
+			ActiveDeadlineSeconds: &timeout,
+			BackoffLimit:          &backoffLimit,
+		},
+	}
+}
+
+// ConsoleJobName returns the name of the console's job. This ensures that the
+// job name (after suffixing with `-console`) does not exceed 63 characters.
+// This is the string length limit on labels and the job name is added as a
+// label to the pods it creates.
+func ConsoleJobName(consoleName string) string {
+	return fmt.Sprintf("%s-%s", truncateString(consoleName, 55), "console")
+}
+
+// Kubernetes labels must satisfy (([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])? and not
+// exceed 63 characters in length.
+// We don't bother with the first and last character sanitisation here - just anything
+// dodgy in the middle.
+// This is mostly so that, in tests, we correctly handle the system:unsecured user.
+func sanitiseLabel(l string) string {
+	return truncateString(regexp.MustCompile(`[^A-z0-9\-_.]`).ReplaceAllString(l, "-"), 63)
+}
+
+func truncateString(str string, length int) string {
+	if len(str) > length {
+		return str[0:length]
+	}
+	return str
+}
@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Console job", func() {
+	var (
+		csl *Console
+		tpl *ConsoleTemplate
+	)
+
+	BeforeEach(func() {
+		csl = &Console{
+			ObjectMeta: metav1.ObjectMeta{Name: "console-0", Namespace: "default"},
+			Spec: ConsoleSpec{
+				User:    "alice@example.com",
+				Command: []string{"bin/rails", "console"},
+			},
+		}
+		tpl = &ConsoleTemplate{
+			Spec: ConsoleTemplateSpec{
+				DefaultTimeoutSeconds: 600,
+				MaxTimeoutSeconds:     3600,
+				Template: PodTemplatePreserveMetadataSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "console", Image: "app:v1"}},
+					},
+				},
+			},
+		}
+	})
+
+	Describe("WithTemplateTTLs", func() {
+		It("falls back to the template's defaults and then the controller's", func() {
+			ttl := int32(60)
+			tpl.Spec.DefaultTTLSecondsAfterFinished = &ttl
+
+			updated := csl.WithTemplateTTLs(tpl)
+			Expect(*updated.Spec.TTLSecondsAfterFinished).To(Equal(ttl))
+			Expect(*updated.Spec.TTLSecondsBeforeRunning).To(Equal(int32(DefaultTTLBeforeRunning.Seconds())))
+			Expect(csl.Spec.TTLSecondsAfterFinished).To(BeNil())
+		})
+	})
+
+	Describe("WithTemplateTimeout", func() {
+		It("defaults an unset timeout", func() {
+			updated, reduced := csl.WithTemplateTimeout(tpl)
+			Expect(updated.Spec.TimeoutSeconds).To(Equal(600))
+			Expect(reduced).To(BeFalse())
+		})
+
+		It("reduces a timeout exceeding the template maximum", func() {
+			csl.Spec.TimeoutSeconds = 7200
+
+			updated, reduced := csl.WithTemplateTimeout(tpl)
+			Expect(updated.Spec.TimeoutSeconds).To(Equal(3600))
+			Expect(reduced).To(BeTrue())
+		})
+	})
+
+	Describe("BuildJob", func() {
+		It("runs the console's command in an attachable container", func() {
+			job := csl.BuildJob(tpl)
+
+			Expect(job.Name).To(Equal("console-0-console"))
+			Expect(job.Labels).To(HaveKeyWithValue("user", "alice"))
+
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Command).To(Equal([]string{"bin/rails"}))
+			Expect(container.Args).To(Equal([]string{"console"}))
+			Expect(container.TTY).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package v1alpha1
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConsoleScheduleSpec defines a console to be created on a recurring schedule
+type ConsoleScheduleSpec struct {
+	// The user that created the schedule. Consoles created by the schedule are
+	// shared with this user, allowing them to attach and view their output.
+	// This is set by an admission webhook, and cannot be changed.
+	User   string `json:"user"`
+	Reason string `json:"reason"`
+
+	ConsoleTemplateRef corev1.LocalObjectReference `json:"consoleTemplateRef"`
+
+	// The command and arguments to execute in each console
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+
+	// The schedule in cron format, e.g. "0 4 * * 1", evaluated in UTC
+	Schedule string `json:"schedule"`
+
+	// Number of seconds that each console should run for, clamped to the
+	// template's maximum
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=604800
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// Time until which the schedule's authorisation is valid. No consoles are
+	// created after this time. This can be at most 90 days after the schedule
+	// is created.
+	ExpiryTime metav1.Time `json:"expiryTime"`
+
+	// Stop creating consoles, without affecting the schedule's authorisation
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Authorisations given to the schedule, which apply to every console it
+	// creates. Authorisers can only append themselves to this list, and all
+	// other fields, except suspend, are immutable so that the authorisation
+	// can't be reused for something else.
+	// +optional
+	Authorisations []rbacv1.Subject `json:"authorisations,omitempty"`
+}
+
+// ConsoleScheduleStatus defines the observed state of ConsoleSchedule
+type ConsoleScheduleStatus struct {
+	Phase ConsoleSchedulePhase `json:"phase,omitempty"`
+	// Name of the authorisation rule that the schedule's command matched
+	// +optional
+	AuthorisationRuleName string `json:"authorisationRuleName,omitempty"`
+	// Time of the last schedule tick that was handled, whether or not a console
+	// was created for it
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// Name of the last console created by the schedule
+	// +optional
+	LastConsoleName string `json:"lastConsoleName,omitempty"`
+	// Time at which the next console will be created
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+}
+
+// ConsoleScheduleLabel is set on each console created by a schedule, to the
+// name of that schedule
+const ConsoleScheduleLabel = "console-schedule-name"
+
+// ConsoleScheduleStartingDeadline is how long after a schedule tick its
+// console can still be created. Ticks missed by more than this, e.g. while the
+// controller was unavailable, are skipped.
+const ConsoleScheduleStartingDeadline = time.Hour
+
+type ConsoleSchedulePhase string
+
+// These are valid phases for a console schedule
+const (
+	// ConsoleSchedulePendingAuthorisation means the schedule is awaiting the
+	// authorisations required by its command's authorisation rule
+	ConsoleSchedulePendingAuthorisation ConsoleSchedulePhase = "Pending Authorisation"
+	// ConsoleScheduleActive means consoles will be created at each tick
+	ConsoleScheduleActive ConsoleSchedulePhase = "Active"
+	// ConsoleScheduleSuspended means the schedule has been suspended
+	ConsoleScheduleSuspended ConsoleSchedulePhase = "Suspended"
+	// ConsoleScheduleExpired means the schedule's authorisation has expired
+	ConsoleScheduleExpired ConsoleSchedulePhase = "Expired"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+
+// ConsoleSchedule creates consoles on a recurring schedule, with an
+// authorisation that applies to every console it creates
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
+// +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Last Schedule",type="date",JSONPath=".status.lastScheduleTime"
+// +kubebuilder:printcolumn:name="Expiry",type="string",JSONPath=".spec.expiryTime"
+type ConsoleSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConsoleScheduleSpec   `json:"spec,omitempty"`
+	Status ConsoleScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ConsoleScheduleList contains a list of ConsoleSchedule
+type ConsoleScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConsoleSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConsoleSchedule{}, &ConsoleScheduleList{})
+}
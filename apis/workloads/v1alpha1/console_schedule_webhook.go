@@ -0,0 +1,161 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/gocardless/theatre/v3/pkg/cron"
+)
+
+// MaxConsoleScheduleAuthorisationPeriod is the longest period, from its
+// creation, for which a console schedule can be authorised
+const MaxConsoleScheduleAuthorisationPeriod = 90 * 24 * time.Hour
+
+// +kubebuilder:object:generate=false
+type ConsoleScheduleWebhook struct {
+	logger  logr.Logger
+	decoder *admission.Decoder
+}
+
+func NewConsoleScheduleWebhook(logger logr.Logger) *ConsoleScheduleWebhook {
+	return &ConsoleScheduleWebhook{
+		logger: logger,
+	}
+}
+
+func (c *ConsoleScheduleWebhook) InjectDecoder(d *admission.Decoder) error {
+	c.decoder = d
+	return nil
+}
+
+func (c *ConsoleScheduleWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	logger := c.logger.WithValues("uuid", string(req.UID), "user", req.UserInfo.Username)
+	logger.Info("starting request", "event", "request.start")
+	defer func(start time.Time) {
+		logger.Info("completed request", "event", "request.end", "duration", time.Since(start).Seconds())
+	}(time.Now())
+
+	schedule := &ConsoleSchedule{}
+	if err := c.decoder.Decode(req, schedule); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	user := req.UserInfo.Username
+
+	switch req.Operation {
+	case admissionv1.Create:
+		// The schedule is owned by whoever created it, and can't be created
+		// with authorisations already in place
+		copy := schedule.DeepCopy()
+		copy.Spec.User = user
+		copy.Spec.Authorisations = nil
+
+		if err := copy.Validate(time.Now()); err != nil {
+			logger.Info("console schedule rejected", "event", "validation.failure", "error", err)
+			return admission.ValidationResponse(false, fmt.Sprintf("the console schedule spec is invalid: %v", err))
+		}
+
+		copyBytes, err := json.Marshal(copy)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		logger.Info(fmt.Sprintf("authentication successful for user %s", user), "event", "authentication.success")
+		return admission.PatchResponseFromRaw(req.Object.Raw, copyBytes)
+
+	case admissionv1.Update:
+		existing := &ConsoleSchedule{}
+		if err := c.decoder.DecodeRaw(req.OldObject, existing); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		update := &ConsoleScheduleUpdate{
+			existing: existing,
+			updated:  schedule,
+			user:     user,
+		}
+		if err := update.Validate(); err != nil {
+			logger.Info("console schedule update rejected", "event", "validation.failure", "error", err)
+			return admission.ValidationResponse(false, fmt.Sprintf("the console schedule update is invalid: %v", err))
+		}
+
+		if update.IsAuthorisation() {
+			logger.Info("console schedule authorised", "event", "authorisation.success", "schedule", schedule.Name)
+		}
+	}
+
+	return admission.ValidationResponse(true, "")
+}
+
+// Validate checks that the schedule can be parsed, and that its authorisation
+// is valid for a bounded period from now
+func (s *ConsoleSchedule) Validate(now time.Time) error {
+	var err error
+
+	if _, parseErr := cron.Parse(s.Spec.Schedule); parseErr != nil {
+		err = multierror.Append(err, errors.Wrap(parseErr, "invalid spec.schedule"))
+	}
+
+	if !s.Spec.ExpiryTime.After(now) {
+		err = multierror.Append(err, errors.New("spec.expiryTime must be in the future"))
+	}
+	if s.Spec.ExpiryTime.After(now.Add(MaxConsoleScheduleAuthorisationPeriod)) {
+		err = multierror.Append(err, fmt.Errorf(
+			"spec.expiryTime can be at most %s from now", MaxConsoleScheduleAuthorisationPeriod,
+		))
+	}
+
+	return err
+}
+
+type ConsoleScheduleUpdate struct {
+	existing *ConsoleSchedule
+	updated  *ConsoleSchedule
+	user     string
+}
+
+// IsAuthorisation returns whether the update adds an authorisation
+func (u *ConsoleScheduleUpdate) IsAuthorisation() bool {
+	return len(u.updated.Spec.Authorisations) > len(u.existing.Spec.Authorisations)
+}
+
+// Validate checks that only the suspend field has changed, or that the user
+// has authorised the schedule, following the same rules as authorising a
+// console
+func (u *ConsoleScheduleUpdate) Validate() error {
+	var err error
+
+	existingSpec, updatedSpec := u.existing.Spec.DeepCopy(), u.updated.Spec.DeepCopy()
+	existingSpec.Suspend, updatedSpec.Suspend = false, false
+	existingSpec.Authorisations, updatedSpec.Authorisations = nil, nil
+
+	if !reflect.DeepEqual(existingSpec, updatedSpec) {
+		err = multierror.Append(err, errors.New("only the spec.suspend and spec.authorisations fields can be updated"))
+	}
+
+	authorisationUpdate := &ConsoleAuthorisationUpdate{
+		existingAuth: &ConsoleAuthorisation{
+			Spec: ConsoleAuthorisationSpec{Authorisations: u.existing.Spec.Authorisations},
+		},
+		updatedAuth: &ConsoleAuthorisation{
+			Spec: ConsoleAuthorisationSpec{Authorisations: u.updated.Spec.Authorisations},
+		},
+		user:  u.user,
+		owner: u.existing.Spec.User,
+	}
+	if authErr := authorisationUpdate.Validate(); authErr != nil {
+		err = multierror.Append(err, authErr)
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+package v1alpha1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Console schedule webhook", func() {
+	var (
+		now      time.Time
+		schedule *ConsoleSchedule
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		schedule = &ConsoleSchedule{
+			Spec: ConsoleScheduleSpec{
+				User:       "owner",
+				Command:    []string{"rake", "cleanup"},
+				Schedule:   "0 4 * * 1",
+				ExpiryTime: metav1.NewTime(now.Add(30 * 24 * time.Hour)),
+			},
+		}
+	})
+
+	Describe("Validate", func() {
+		It("accepts a valid schedule", func() {
+			Expect(schedule.Validate(now)).To(Succeed())
+		})
+
+		It("rejects an invalid cron schedule", func() {
+			schedule.Spec.Schedule = "every monday"
+			Expect(schedule.Validate(now)).To(MatchError(ContainSubstring("invalid spec.schedule")))
+		})
+
+		It("rejects an expiry in the past", func() {
+			schedule.Spec.ExpiryTime = metav1.NewTime(now.Add(-time.Minute))
+			Expect(schedule.Validate(now)).To(MatchError(ContainSubstring("must be in the future")))
+		})
+
+		It("rejects an unbounded expiry", func() {
+			schedule.Spec.ExpiryTime = metav1.NewTime(now.Add(MaxConsoleScheduleAuthorisationPeriod + time.Hour))
+			Expect(schedule.Validate(now)).To(MatchError(ContainSubstring("can be at most")))
+		})
+	})
+
+	Describe("ConsoleScheduleUpdate", func() {
+		var (
+			updated *ConsoleSchedule
+			user    string
+			update  *ConsoleScheduleUpdate
+		)
+
+		BeforeEach(func() {
+			updated = schedule.DeepCopy()
+			user = "authoriser"
+		})
+
+		JustBeforeEach(func() {
+			update = &ConsoleScheduleUpdate{existing: schedule, updated: updated, user: user}
+		})
+
+		Context("when suspending the schedule", func() {
+			BeforeEach(func() {
+				updated.Spec.Suspend = true
+			})
+
+			It("is allowed", func() {
+				Expect(update.Validate()).To(Succeed())
+				Expect(update.IsAuthorisation()).To(BeFalse())
+			})
+		})
+
+		Context("when the user authorises the schedule", func() {
+			BeforeEach(func() {
+				updated.Spec.Authorisations = []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "authoriser"}}
+			})
+
+			It("is allowed", func() {
+				Expect(update.Validate()).To(Succeed())
+				Expect(update.IsAuthorisation()).To(BeTrue())
+			})
+
+			Context("and they own the schedule", func() {
+				BeforeEach(func() {
+					user = "owner"
+					updated.Spec.Authorisations = []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "owner"}}
+				})
+
+				It("is rejected", func() {
+					Expect(update.Validate()).To(MatchError(ContainSubstring("cannot authorise their own console")))
+				})
+			})
+		})
+
+		Context("when authorising on behalf of someone else", func() {
+			BeforeEach(func() {
+				updated.Spec.Authorisations = []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "someone-else"}}
+			})
+
+			It("is rejected", func() {
+				Expect(update.Validate()).To(MatchError(ContainSubstring("only the current user can be added")))
+			})
+		})
+
+		Context("when changing the command", func() {
+			BeforeEach(func() {
+				updated.Spec.Command = []string{"rake", "drop_everything"}
+			})
+
+			It("is rejected", func() {
+				Expect(update.Validate()).To(MatchError(ContainSubstring("only the spec.suspend and spec.authorisations fields can be updated")))
+			})
+		})
+
+		Context("when extending the expiry", func() {
+			BeforeEach(func() {
+				updated.Spec.ExpiryTime = metav1.NewTime(schedule.Spec.ExpiryTime.Add(time.Hour))
+			})
+
+			It("is rejected", func() {
+				Expect(update.Validate()).To(HaveOccurred())
+			})
+		})
+	})
+})
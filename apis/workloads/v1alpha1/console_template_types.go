@@ -37,6 +37,15 @@ type ConsoleAuthorisers struct {
 
 	// List of subjects that can provide authorisation for the console command to run.
 	Subjects []rbacv1.Subject `json:"subjects"`
+
+	// Number of seconds after an authorised console is created during which its
+	// owner can re-run it without being authorised again. The authorisations
+	// are carried forward to the re-run, and are never carried forward if this
+	// is unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=86400
+	RerunAuthorisationWindowSeconds int `json:"rerunAuthorisationWindowSeconds,omitempty"`
 }
 
 // PodTemplatePreserveMetadataSpec describes the data a pod should have when created from a template
@@ -92,6 +101,24 @@ type ConsoleTemplateSpec struct {
 	// Default authorisation rule to use if no authorisation rules are defined or no authorisation rules match.
 	// +optional
 	DefaultAuthorisationRule *ConsoleAuthorisers `json:"defaultAuthorisationRule,omitempty"`
+
+	// Capture the output of non-interactive consoles when they finish, so that
+	// it remains available after the console's pod has been deleted. If not
+	// set, output is not captured.
+	// +optional
+	OutputCapture *ConsoleOutputCapture `json:"outputCapture,omitempty"`
+}
+
+// ConsoleOutputCapture configures how the output of non-interactive consoles is
+// captured
+type ConsoleOutputCapture struct {
+	// Maximum number of bytes of output to store. Output beyond this is
+	// truncated, keeping the most recent output. If not set, this value
+	// defaults to 256KiB.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=524288
+	MaxBytes *int32 `json:"maxBytes,omitempty"`
 }
 
 // ConsoleTemplateStatus defines the observed state of ConsoleTemplate
@@ -2,24 +2,51 @@ package v1alpha1
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+// Policies for changes to a template's authorisation rules while consoles
+// created from it are pending authorisation, as the controller applies the
+// current rules to them
+const (
+	// PendingConsolesAllow allows the change
+	PendingConsolesAllow = "allow"
+	// PendingConsolesWarn allows the change, warning whoever made it
+	PendingConsolesWarn = "warn"
+	// PendingConsolesReject rejects the change until the consoles are
+	// authorised or expire
+	PendingConsolesReject = "reject"
 )
 
 // +kubebuilder:object:generate=false
 type ConsoleTemplateValidationWebhook struct {
-	logger  logr.Logger
-	decoder *admission.Decoder
+	client            client.Client
+	lifecycleRecorder LifecycleEventRecorder
+	logger            logr.Logger
+	decoder           *admission.Decoder
+	pendingPolicy     string
 }
 
-func NewConsoleTemplateValidationWebhook(logger logr.Logger) *ConsoleTemplateValidationWebhook {
+func NewConsoleTemplateValidationWebhook(c client.Client, lifecycleRecorder LifecycleEventRecorder, logger logr.Logger, pendingPolicy string) *ConsoleTemplateValidationWebhook {
 	return &ConsoleTemplateValidationWebhook{
-		logger: logger,
+		client:            c,
+		lifecycleRecorder: lifecycleRecorder,
+		logger:            logger,
+		pendingPolicy:     pendingPolicy,
 	}
 }
 
@@ -36,9 +63,13 @@ func (c *ConsoleTemplateValidationWebhook) Handle(ctx context.Context, req admis
 		logger.Info("request completed", "event", "request.end", "duration", time.Since(start).Seconds())
 	}(time.Now())
 
+	if req.Operation == admissionv1.Delete {
+		return c.handleDelete(ctx, logger, req)
+	}
+
 	template := &ConsoleTemplate{}
 	if err := c.decoder.Decode(req, template); err != nil {
-		admission.Errored(http.StatusBadRequest, err)
+		return admission.Errored(http.StatusBadRequest, err)
 	}
 
 	if err := template.Validate(); err != nil {
@@ -47,5 +78,238 @@ func (c *ConsoleTemplateValidationWebhook) Handle(ctx context.Context, req admis
 	}
 
 	logger.Info("completed validation", "event", "validation.success")
+
+	// The template has already been given the generation it will have once
+	// this change is made, so we can tell whether its spec changed
+	var oldTemplate *ConsoleTemplate
+	if req.Operation == admissionv1.Update {
+		oldTemplate = &ConsoleTemplate{}
+		if err := c.decoder.DecodeRaw(req.OldObject, oldTemplate); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if oldTemplate.Generation == template.Generation {
+			return admission.ValidationResponse(true, "")
+		}
+	}
+
+	changes, err := TemplateChanges(oldTemplate, template)
+	if err != nil {
+		logger.Error(err, "failed to diff console template")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	var (
+		pendingConsoles []string
+		warnings        []string
+	)
+	if oldTemplate != nil && changesAuthorisationRules(changes) {
+		pendingConsoles, err = c.pendingConsoles(ctx, template)
+		if err != nil {
+			logger.Error(err, "failed to list consoles pending authorisation")
+			if c.pendingPolicy == PendingConsolesReject {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+		}
+
+		if len(pendingConsoles) > 0 {
+			msg := fmt.Sprintf(
+				"the authorisation rules will change for consoles pending authorisation: %s",
+				strings.Join(pendingConsoles, ", "),
+			)
+			logger.Info(msg, "event", "validation.pending_consoles", "policy", c.pendingPolicy)
+
+			switch c.pendingPolicy {
+			case PendingConsolesReject:
+				return admission.ValidationResponse(false, msg)
+			case PendingConsolesWarn:
+				warnings = append(warnings, msg)
+			}
+		}
+	}
+
+	if req.DryRun != nil && *req.DryRun {
+		return admission.ValidationResponse(true, "").WithWarnings(warnings...)
+	}
+
+	// The change may yet be rejected by another webhook, but recording it here
+	// is the only way to know who made it
+	if err := c.lifecycleRecorder.ConsoleTemplateChange(ctx, template, string(req.Operation), req.UserInfo.Username, changes, pendingConsoles); err != nil {
+		logger.Error(err, "failed to record event", "event", "consoletemplate.change")
+	}
+
+	return admission.ValidationResponse(true, "").WithWarnings(warnings...)
+}
+
+// handleDelete records the deletion of a template as a change of each of its
+// fields to unset. Deletions are never rejected.
+func (c *ConsoleTemplateValidationWebhook) handleDelete(ctx context.Context, logger logr.Logger, req admission.Request) admission.Response {
+	template := &ConsoleTemplate{}
+	if err := c.decoder.DecodeRaw(req.OldObject, template); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.DryRun != nil && *req.DryRun {
+		return admission.ValidationResponse(true, "")
+	}
+
+	changes, err := TemplateChanges(template, nil)
+	if err != nil {
+		logger.Error(err, "failed to diff console template")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// Consoles pending authorisation can no longer be authorised, so are listed
+	pendingConsoles, err := c.pendingConsoles(ctx, template)
+	if err != nil {
+		logger.Error(err, "failed to list consoles pending authorisation")
+	}
+
+	if err := c.lifecycleRecorder.ConsoleTemplateChange(ctx, template, string(req.Operation), req.UserInfo.Username, changes, pendingConsoles); err != nil {
+		logger.Error(err, "failed to record event", "event", "consoletemplate.change")
+	}
+
 	return admission.ValidationResponse(true, "")
 }
+
+// pendingConsoles returns the names of the consoles created from the template
+// that are still pending authorisation
+func (c *ConsoleTemplateValidationWebhook) pendingConsoles(ctx context.Context, template *ConsoleTemplate) ([]string, error) {
+	consoles := &ConsoleList{}
+	if err := c.client.List(ctx, consoles, client.InNamespace(template.Namespace)); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, csl := range consoles.Items {
+		if csl.Spec.ConsoleTemplateRef.Name == template.Name && csl.PendingAuthorisation() {
+			names = append(names, csl.Name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// changesAuthorisationRules returns whether the changes include either of the
+// template's authorisation rules
+func changesAuthorisationRules(changes []events.TemplateFieldChange) bool {
+	for _, change := range changes {
+		if change.Field == "spec.authorisationRules" || change.Field == "spec.defaultAuthorisationRule" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TemplateChanges returns the changes to the security-relevant fields of a
+// template: who can authorise its consoles, and how long for, who else can
+// attach to them, and what images they run. A nil template is treated as
+// empty, so every field set on a new template, or on one being deleted, is a
+// change.
+func TemplateChanges(old, new *ConsoleTemplate) ([]events.TemplateFieldChange, error) {
+	if old == nil {
+		old = &ConsoleTemplate{}
+	}
+	if new == nil {
+		new = &ConsoleTemplate{}
+	}
+
+	fields := []struct {
+		field    string
+		from, to interface{}
+	}{
+		{"spec.authorisationRules", old.Spec.AuthorisationRules, new.Spec.AuthorisationRules},
+		{"spec.defaultAuthorisationRule", old.Spec.DefaultAuthorisationRule, new.Spec.DefaultAuthorisationRule},
+		{"spec.defaultTimeoutSeconds", old.Spec.DefaultTimeoutSeconds, new.Spec.DefaultTimeoutSeconds},
+		{"spec.maxTimeoutSeconds", old.Spec.MaxTimeoutSeconds, new.Spec.MaxTimeoutSeconds},
+		{"spec.additionalAttachSubjects", old.Spec.AdditionalAttachSubjects, new.Spec.AdditionalAttachSubjects},
+	}
+
+	changes := []events.TemplateFieldChange{}
+	for _, field := range fields {
+		change, err := fieldChange(field.field, field.from, field.to)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	for _, containers := range []struct {
+		field    string
+		from, to []corev1.Container
+	}{
+		{"spec.template.spec.initContainers", old.Spec.Template.Spec.InitContainers, new.Spec.Template.Spec.InitContainers},
+		{"spec.template.spec.containers", old.Spec.Template.Spec.Containers, new.Spec.Template.Spec.Containers},
+	} {
+		from, to := containerImages(containers.from), containerImages(containers.to)
+
+		names := []string{}
+		for name := range from {
+			names = append(names, name)
+		}
+		for name := range to {
+			if _, ok := from[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			change, err := fieldChange(fmt.Sprintf("%s[%s].image", containers.field, name), from[name], to[name])
+			if err != nil {
+				return nil, err
+			}
+			if change != nil {
+				changes = append(changes, *change)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// fieldChange returns the change to a field, or nil if its value is unchanged.
+// Zero values, such as an empty list, are treated as unset.
+func fieldChange(field string, from, to interface{}) (*events.TemplateFieldChange, error) {
+	fromJSON, err := fieldValue(from)
+	if err != nil {
+		return nil, err
+	}
+	toJSON, err := fieldValue(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(fromJSON) == string(toJSON) {
+		return nil, nil
+	}
+
+	return &events.TemplateFieldChange{Field: field, From: fromJSON, To: toJSON}, nil
+}
+
+func fieldValue(value interface{}) (json.RawMessage, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch string(body) {
+	case "null", "[]", `""`:
+		return json.RawMessage("null"), nil
+	}
+
+	return body, nil
+}
+
+// containerImages returns the image of each container, by name
+func containerImages(containers []corev1.Container) map[string]*string {
+	images := map[string]*string{}
+	for idx := range containers {
+		images[containers[idx].Name] = &containers[idx].Image
+	}
+
+	return images
+}
@@ -0,0 +1,132 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+var _ = Describe("Console template validation webhook", func() {
+	Describe("TemplateChanges", func() {
+		var (
+			old, new *ConsoleTemplate
+			changes  []events.TemplateFieldChange
+			err      error
+		)
+
+		newTemplate := func() *ConsoleTemplate {
+			return &ConsoleTemplate{
+				Spec: ConsoleTemplateSpec{
+					DefaultTimeoutSeconds: 600,
+					MaxTimeoutSeconds:     3600,
+					Template: PodTemplatePreserveMetadataSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "console", Image: "app:v1"}},
+						},
+					},
+					DefaultAuthorisationRule: &ConsoleAuthorisers{
+						AuthorisationsRequired: 1,
+						Subjects:               []rbacv1.Subject{{Kind: "User", Name: "alice@example.com"}},
+					},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			old, new = newTemplate(), newTemplate()
+		})
+
+		JustBeforeEach(func() {
+			changes, err = TemplateChanges(old, new)
+		})
+
+		Context("when nothing security-relevant changes", func() {
+			BeforeEach(func() {
+				new.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "DEBUG", Value: "1"}}
+			})
+
+			It("returns no changes", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(changes).To(BeEmpty())
+			})
+		})
+
+		Context("when security-relevant fields change", func() {
+			BeforeEach(func() {
+				new.Spec.MaxTimeoutSeconds = 7200
+				new.Spec.DefaultAuthorisationRule.AuthorisationsRequired = 0
+				new.Spec.AdditionalAttachSubjects = []rbacv1.Subject{{Kind: "Group", Name: "sre"}}
+				new.Spec.Template.Spec.Containers[0].Image = "app:v2"
+				new.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "setup", Image: "setup:v1"}}
+			})
+
+			It("returns the old and new value of each", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				fields := map[string][2]string{}
+				for _, change := range changes {
+					fields[change.Field] = [2]string{string(change.From), string(change.To)}
+				}
+
+				Expect(fields).To(Equal(map[string][2]string{
+					"spec.defaultAuthorisationRule": {
+						`{"authorisationsRequired":1,"subjects":[{"kind":"User","name":"alice@example.com"}]}`,
+						`{"authorisationsRequired":0,"subjects":[{"kind":"User","name":"alice@example.com"}]}`,
+					},
+					"spec.maxTimeoutSeconds":                         {"3600", "7200"},
+					"spec.additionalAttachSubjects":                  {"null", `[{"kind":"Group","name":"sre"}]`},
+					"spec.template.spec.initContainers[setup].image": {"null", `"setup:v1"`},
+					"spec.template.spec.containers[console].image":   {`"app:v1"`, `"app:v2"`},
+				}))
+			})
+		})
+
+		Context("when the template is created", func() {
+			BeforeEach(func() {
+				old = nil
+			})
+
+			It("returns every field that is set", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				fields := []string{}
+				for _, change := range changes {
+					Expect(json.Valid(change.To)).To(BeTrue())
+					fields = append(fields, change.Field)
+				}
+				Expect(fields).To(Equal([]string{
+					"spec.defaultAuthorisationRule",
+					"spec.defaultTimeoutSeconds",
+					"spec.maxTimeoutSeconds",
+					"spec.template.spec.containers[console].image",
+				}))
+			})
+		})
+
+		Context("when the template is deleted", func() {
+			BeforeEach(func() {
+				new = nil
+			})
+
+			It("returns every field that was set, changed to unset", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				fields := map[string]string{}
+				for _, change := range changes {
+					fields[change.Field] = string(change.To)
+				}
+				Expect(fields).To(Equal(map[string]string{
+					"spec.defaultAuthorisationRule":                "null",
+					"spec.defaultTimeoutSeconds":                   "0",
+					"spec.maxTimeoutSeconds":                       "0",
+					"spec.template.spec.containers[console].image": "null",
+				}))
+			})
+		})
+	})
+})
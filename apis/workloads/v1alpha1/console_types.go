@@ -5,6 +5,32 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ConsoleTerminateFinalizer is added to every console by the controller, to
+// ensure a Terminate lifecycle event is published before the console is deleted
+const ConsoleTerminateFinalizer = "workloads.crd.gocardless.com/console-terminate"
+
+// Annotations set on a console's job, recording the generation of the console
+// template it was built from and a hash of its pod spec, so that the console's
+// status reflects what it actually ran regardless of later template changes
+const (
+	ConsoleTemplateGenerationAnnotation = "workloads.crd.gocardless.com/template-generation"
+	ConsoleTemplateHashAnnotation       = "workloads.crd.gocardless.com/template-hash"
+)
+
+// ConsoleRerunOfLabel is set on a console created by re-running another
+// console, to the name of the original console
+const ConsoleRerunOfLabel = "console-rerun-of"
+
+// ConsoleBatchLabel is set on consoles that were created together, across
+// namespaces or clusters, so that they can be authorised together. Only the
+// console authenticator webhook sets it, from ConsoleBatchAnnotation and the
+// user creating the console, so consoles can't be added to another user's
+// batch.
+const ConsoleBatchLabel = "console-batch"
+
+// ConsoleBatchAnnotation requests that a console is added to a batch
+const ConsoleBatchAnnotation = "workloads.crd.gocardless.com/batch"
+
 // ConsoleSpec defines the desired state of Console
 type ConsoleSpec struct {
 	User   string `json:"user"`
@@ -50,6 +76,13 @@ type ConsoleSpec struct {
 	// situations, enabling the TTY on a container in the console causes
 	// breakage - in Tekton steps, for example.
 	Noninteractive bool `json:"noninteractive,omitempty"`
+
+	// Users that the console owner has chosen to share the console with. Once
+	// the console is running (i.e. after any required authorisations have been
+	// given) these users are granted the same attach permissions as the owner.
+	// A collaborator cannot authorise a console that has been shared with them.
+	// +optional
+	SharedWith []string `json:"sharedWith,omitempty"`
 }
 
 // ConsoleStatus defines the observed state of Console
@@ -59,6 +92,56 @@ type ConsoleStatus struct {
 	// Time at which the job completed successfully
 	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 	Phase          ConsolePhase `json:"phase"`
+	// Sessions in which users have attached to the console, oldest first.
+	// +optional
+	AttachSessions []ConsoleAttachSession `json:"attachSessions,omitempty"`
+	// Output of the console's container, captured once it finished. This is
+	// only set for non-interactive consoles created from a template that
+	// enables output capture.
+	// +optional
+	CapturedOutput *ConsoleCapturedOutput `json:"capturedOutput,omitempty"`
+	// Set once a Terminate lifecycle event has been published for the console,
+	// so that another isn't published when it is deleted.
+	// +optional
+	TerminateRecorded bool `json:"terminateRecorded,omitempty"`
+	// Generation of the console template that the console's job was built
+	// from. Until the job is created, this is the generation when the console
+	// was requested.
+	// +optional
+	TemplateGeneration int64 `json:"templateGeneration,omitempty"`
+	// SHA-256 hash of the pod spec of the console's job, in the form
+	// sha256:<hex>, identifying exactly what the console ran. Until the job is
+	// created, this is the hash of the job it would have run when requested.
+	// +optional
+	TemplateHash string `json:"templateHash,omitempty"`
+}
+
+// ConsoleCapturedOutput describes where the output of a console has been
+// stored
+type ConsoleCapturedOutput struct {
+	// Name of the secret, in the console's namespace, holding the output
+	SecretName string `json:"secretName"`
+	// Size of the output, in bytes, before any truncation
+	Bytes int64 `json:"bytes"`
+	// Whether the start of the output was discarded to fit within the
+	// template's limit
+	// +optional
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ConsoleAttachSession records a period in which a user was attached to a
+// console
+type ConsoleAttachSession struct {
+	Username  string `json:"username"`
+	Container string `json:"container,omitempty"`
+	// Time at which the user attached to the console
+	StartTime metav1.Time `json:"startTime"`
+	// Time by which the session was closed: when the container the user
+	// attached to terminated, or the console stopped. Nothing trusted reports
+	// when a user detaches, so they may have detached at any point before
+	// this. This is unset while the session may still be open.
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -1,10 +1,17 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gocardless/theatre/v3/pkg/cron"
 )
 
 // Creating returns true if the console has no status (the console has just been created)
@@ -99,6 +106,45 @@ func (c *Console) TTLSecondsBeforeRunning() time.Duration {
 	return time.Duration(*c.Spec.TTLSecondsBeforeRunning) * time.Second
 }
 
+// IsCollaborator returns true if the console has been shared with the given
+// user
+func (c *Console) IsCollaborator(username string) bool {
+	return containsString(c.Spec.SharedWith, username)
+}
+
+// StartAttachSession records that a user has attached to the given container
+// of the console
+func (c *Console) StartAttachSession(username, container string, startTime time.Time) {
+	c.Status.AttachSessions = append(c.Status.AttachSessions, ConsoleAttachSession{
+		Username:  username,
+		Container: container,
+		StartTime: metav1.NewTime(startTime),
+	})
+}
+
+// EndAttachSessions records that the open sessions attached to the given
+// container, or to any container if empty, ended at the given time. It returns
+// the sessions it closed.
+func (c *Console) EndAttachSessions(container string, endTime time.Time) []ConsoleAttachSession {
+	ended := []ConsoleAttachSession{}
+	for i := range c.Status.AttachSessions {
+		session := &c.Status.AttachSessions[i]
+		if session.EndTime != nil || (container != "" && session.Container != container) {
+			continue
+		}
+
+		// Sessions opened as the container terminated can't end before they
+		// started
+		session.EndTime = &metav1.Time{Time: endTime}
+		if endTime.Before(session.StartTime.Time) {
+			session.EndTime = session.StartTime.DeepCopy()
+		}
+		ended = append(ended, *session)
+	}
+
+	return ended
+}
+
 // GetDefaultCommandWithArgs returns a concatenated list of command and
 // arguments, if defined on the template
 func (ct *ConsoleTemplate) GetDefaultCommandWithArgs() ([]string, error) {
@@ -251,3 +297,123 @@ func (ct *ConsoleTemplate) Validate() error {
 
 	return err
 }
+
+func containsString(ss []string, s string) bool {
+	for _, existing := range ss {
+		if existing == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanReuseAuthorisation returns whether the console re-runs the original
+// console closely enough, and soon enough after it, to be given the original's
+// authorisations under the given rule
+func (c *Console) CanReuseAuthorisation(original *Console, rule ConsoleAuthorisationRule) bool {
+	if rule.RerunAuthorisationWindowSeconds == 0 || c.Labels[ConsoleRerunOfLabel] != original.Name {
+		return false
+	}
+
+	// Only the owner of the original console can reuse its authorisations, to
+	// run exactly the same thing again
+	if c.Namespace != original.Namespace ||
+		c.Spec.User != original.Spec.User ||
+		c.Spec.ConsoleTemplateRef != original.Spec.ConsoleTemplateRef ||
+		c.Spec.Noninteractive != original.Spec.Noninteractive ||
+		!reflect.DeepEqual(c.Spec.Command, original.Spec.Command) {
+		return false
+	}
+
+	// Authorisations that were themselves carried forward can't be carried
+	// forward again, otherwise the window could be extended indefinitely
+	if _, ok := original.Labels[ConsoleRerunOfLabel]; ok {
+		return false
+	}
+
+	window := time.Duration(rule.RerunAuthorisationWindowSeconds) * time.Second
+	elapsed := c.CreationTimestamp.Sub(original.CreationTimestamp.Time)
+	return elapsed >= 0 && elapsed <= window
+}
+
+// ConsoleNameForTick returns the name of the console created by the schedule
+// at the given tick. The name is derived from the tick so that it is only ever
+// created once, and can be mapped back to the tick it was created for.
+func (s *ConsoleSchedule) ConsoleNameForTick(tick time.Time) string {
+	name := s.Name
+	if len(name) > 50 {
+		name = name[:50]
+	}
+
+	return fmt.Sprintf("%s-%d", name, tick.Unix()/60)
+}
+
+// IsAuthorised returns whether the schedule has at least the given number of
+// authorisations
+func (s *ConsoleSchedule) IsAuthorised(required int) bool {
+	return len(s.Spec.Authorisations) >= required
+}
+
+// AuthorisesConsole returns whether the console was created by this schedule
+// for one of its ticks, while the schedule was active and authorised. Only
+// then can the schedule's authorisations be applied to the console. Anyone can
+// set a console's owner references, so it must also have been created by the
+// manager, whose username is given, or the schedule's user.
+func (s *ConsoleSchedule) AuthorisesConsole(csl *Console, required int, managerUsername string) bool {
+	if csl.Labels[ConsoleScheduleLabel] != s.Name || !isOwnedBy(csl, s) {
+		return false
+	}
+
+	if csl.Spec.User != s.Spec.User && (managerUsername == "" || csl.Spec.User != managerUsername) {
+		return false
+	}
+
+	if s.Spec.Suspend || !s.IsAuthorised(required) {
+		return false
+	}
+
+	// The console must run exactly what was authorised
+	if !csl.Spec.Noninteractive ||
+		csl.Spec.ConsoleTemplateRef != s.Spec.ConsoleTemplateRef ||
+		!reflect.DeepEqual(csl.Spec.Command, s.Spec.Command) {
+		return false
+	}
+
+	// Recover the tick from the console name, and check that the schedule
+	// really fired at that time
+	idx := strings.LastIndex(csl.Name, "-")
+	if idx < 0 {
+		return false
+	}
+	minutes, err := strconv.ParseInt(csl.Name[idx+1:], 10, 64)
+	if err != nil {
+		return false
+	}
+	tick := time.Unix(minutes*60, 0).UTC()
+	if csl.Name != s.ConsoleNameForTick(tick) {
+		return false
+	}
+
+	schedule, err := cron.Parse(s.Spec.Schedule)
+	if err != nil || !schedule.Next(tick.Add(-time.Minute)).Equal(tick) {
+		return false
+	}
+
+	if tick.Before(s.CreationTimestamp.Time) || !tick.Before(s.Spec.ExpiryTime.Time) {
+		return false
+	}
+
+	created := csl.CreationTimestamp.Time
+	return !created.Before(tick) && created.Before(tick.Add(ConsoleScheduleStartingDeadline))
+}
+
+func isOwnedBy(obj metav1.Object, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+
+	return false
+}
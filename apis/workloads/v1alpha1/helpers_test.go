@@ -1,8 +1,13 @@
 package v1alpha1
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var _ = Describe("Helpers", func() {
@@ -369,4 +374,226 @@ var _ = Describe("Helpers", func() {
 			})
 		})
 	})
+
+	Describe("Console EndAttachSessions", func() {
+		var (
+			csl       *Console
+			container string
+			ended     []ConsoleAttachSession
+		)
+
+		attachedAt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+		detachedAt := attachedAt.Add(5 * time.Minute)
+		earlierDetachedAt := metav1.NewTime(attachedAt.Add(time.Minute))
+
+		BeforeEach(func() {
+			csl = &Console{}
+			csl.StartAttachSession("alice@example.com", "console-container-0", attachedAt)
+			csl.StartAttachSession("bob@example.com", "console-container-0", attachedAt)
+			csl.StartAttachSession("bob@example.com", "debug", attachedAt)
+			csl.Status.AttachSessions[1].EndTime = &earlierDetachedAt
+		})
+
+		JustBeforeEach(func() {
+			ended = csl.EndAttachSessions(container, detachedAt)
+		})
+
+		Context("given a container", func() {
+			BeforeEach(func() {
+				container = "console-container-0"
+			})
+
+			It("closes the open sessions attached to it", func() {
+				Expect(ended).To(HaveLen(1))
+				Expect(ended[0].Username).To(Equal("alice@example.com"))
+				Expect(csl.Status.AttachSessions[0].EndTime.Time).To(Equal(detachedAt))
+			})
+
+			It("leaves closed sessions and those of other containers alone", func() {
+				Expect(csl.Status.AttachSessions[1].EndTime).To(Equal(&earlierDetachedAt))
+				Expect(csl.Status.AttachSessions[2].EndTime).To(BeNil())
+			})
+		})
+
+		Context("without a container", func() {
+			BeforeEach(func() {
+				container = ""
+			})
+
+			It("closes every open session", func() {
+				Expect(ended).To(HaveLen(2))
+				Expect(csl.Status.AttachSessions[2].EndTime.Time).To(Equal(detachedAt))
+				Expect(csl.Status.AttachSessions[1].EndTime).To(Equal(&earlierDetachedAt))
+			})
+		})
+	})
+
+	Describe("Console CanReuseAuthorisation", func() {
+		var (
+			original *Console
+			rerun    *Console
+			rule     ConsoleAuthorisationRule
+		)
+
+		BeforeEach(func() {
+			created := time.Date(2022, 3, 7, 4, 0, 0, 0, time.UTC)
+			original = &Console{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "app-abcde",
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(created),
+				},
+				Spec: ConsoleSpec{
+					User:               "owner",
+					ConsoleTemplateRef: corev1.LocalObjectReference{Name: "app"},
+					Command:            []string{"rake", "cleanup"},
+				},
+			}
+			rerun = original.DeepCopy()
+			rerun.Name = "app-fghij"
+			rerun.Labels = map[string]string{ConsoleRerunOfLabel: "app-abcde"}
+			rerun.CreationTimestamp = metav1.NewTime(created.Add(5 * time.Minute))
+
+			rule = ConsoleAuthorisationRule{
+				ConsoleAuthorisers: ConsoleAuthorisers{
+					AuthorisationsRequired:          1,
+					RerunAuthorisationWindowSeconds: 900,
+				},
+			}
+		})
+
+		It("allows a re-run within the window", func() {
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeTrue())
+		})
+
+		It("doesn't allow a re-run when the rule has no window", func() {
+			rule.RerunAuthorisationWindowSeconds = 0
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeFalse())
+		})
+
+		It("doesn't allow a re-run after the window", func() {
+			rerun.CreationTimestamp = metav1.NewTime(original.CreationTimestamp.Add(time.Hour))
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeFalse())
+		})
+
+		It("doesn't allow a re-run by another user", func() {
+			rerun.Spec.User = "someone-else"
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeFalse())
+		})
+
+		It("doesn't allow a re-run of a different command", func() {
+			rerun.Spec.Command = []string{"bash"}
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeFalse())
+		})
+
+		It("doesn't allow a re-run that isn't labelled with the original", func() {
+			rerun.Labels = nil
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeFalse())
+		})
+
+		It("doesn't allow authorisations to be carried forward twice", func() {
+			original.Labels = map[string]string{ConsoleRerunOfLabel: "app-older"}
+			Expect(rerun.CanReuseAuthorisation(original, rule)).To(BeFalse())
+		})
+	})
+
+	Describe("ConsoleSchedule AuthorisesConsole", func() {
+		var (
+			schedule *ConsoleSchedule
+			csl      *Console
+			tick     time.Time
+		)
+
+		BeforeEach(func() {
+			tick = time.Date(2022, 3, 7, 4, 0, 0, 0, time.UTC)
+			schedule = &ConsoleSchedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cleanup",
+					UID:               "schedule-uid",
+					CreationTimestamp: metav1.NewTime(tick.Add(-24 * time.Hour)),
+				},
+				Spec: ConsoleScheduleSpec{
+					User:               "owner",
+					ConsoleTemplateRef: corev1.LocalObjectReference{Name: "app"},
+					Command:            []string{"rake", "cleanup"},
+					Schedule:           "0 4 * * 1",
+					ExpiryTime:         metav1.NewTime(tick.Add(30 * 24 * time.Hour)),
+					Authorisations:     []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "authoriser"}},
+				},
+			}
+			csl = &Console{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              schedule.ConsoleNameForTick(tick),
+					Labels:            map[string]string{ConsoleScheduleLabel: "cleanup"},
+					OwnerReferences:   []metav1.OwnerReference{{UID: "schedule-uid"}},
+					CreationTimestamp: metav1.NewTime(tick.Add(time.Second)),
+				},
+				Spec: ConsoleSpec{
+					User:               "manager",
+					ConsoleTemplateRef: corev1.LocalObjectReference{Name: "app"},
+					Command:            []string{"rake", "cleanup"},
+					Noninteractive:     true,
+				},
+			}
+		})
+
+		It("names consoles after their tick", func() {
+			Expect(csl.Name).To(Equal("cleanup-27443760"))
+		})
+
+		It("authorises a console created by the schedule", func() {
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeTrue())
+		})
+
+		It("does not authorise a console when authorisations are missing", func() {
+			Expect(schedule.AuthorisesConsole(csl, 2, "manager")).To(BeFalse())
+		})
+
+		It("authorises a console created by the schedule's user", func() {
+			csl.Spec.User = "owner"
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeTrue())
+		})
+
+		It("does not authorise a console created by anyone else", func() {
+			csl.Spec.User = "mallory"
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+			Expect(schedule.AuthorisesConsole(csl, 1, "")).To(BeFalse())
+		})
+
+		It("does not authorise a console that the schedule doesn't own", func() {
+			csl.OwnerReferences = nil
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+
+		It("does not authorise a console with a different command", func() {
+			csl.Spec.Command = []string{"bash"}
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+
+		It("does not authorise an interactive console", func() {
+			csl.Spec.Noninteractive = false
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+
+		It("does not authorise a console for a time the schedule didn't fire", func() {
+			csl.Name = schedule.ConsoleNameForTick(tick.Add(time.Hour))
+			csl.CreationTimestamp = metav1.NewTime(tick.Add(time.Hour))
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+
+		It("does not authorise a console created long after its tick", func() {
+			csl.CreationTimestamp = metav1.NewTime(tick.Add(2 * ConsoleScheduleStartingDeadline))
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+
+		It("does not authorise a console after the schedule has expired", func() {
+			schedule.Spec.ExpiryTime = metav1.NewTime(tick)
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+
+		It("does not authorise a console while the schedule is suspended", func() {
+			schedule.Spec.Suspend = true
+			Expect(schedule.AuthorisesConsole(csl, 1, "manager")).To(BeFalse())
+		})
+	})
 })
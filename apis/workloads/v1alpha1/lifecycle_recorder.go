@@ -25,8 +25,11 @@ type LifecycleEventRecorder interface {
 	ConsoleRequest(context.Context, *Console, *ConsoleAuthorisationRule) error
 	ConsoleAuthorise(context.Context, *Console, string) error
 	ConsoleStart(context.Context, *Console, string) error
-	ConsoleAttach(context.Context, *Console, string, string) error
-	ConsoleTerminate(context.Context, *Console, bool, *corev1.Pod) error
+	ConsoleAttach(context.Context, *Console, string, string, string, bool) error
+	ConsoleAttachDenied(context.Context, *Console, string, string, string, string) error
+	ConsoleAttachClosed(context.Context, *Console, ConsoleAttachSession, events.AttachClosedReason) error
+	ConsoleTerminate(context.Context, *Console, events.TerminateReason, bool, *corev1.Pod) error
+	ConsoleTemplateChange(context.Context, *ConsoleTemplate, string, string, []events.TemplateFieldChange, []string) error
 }
 
 var _ LifecycleEventRecorder = &lifecycleEventRecorderImpl{}
@@ -95,7 +98,7 @@ func (b *consoleIdBuilderImpl) BuildId(csl *Console) string {
 
 func (l *lifecycleEventRecorderImpl) makeConsoleCommonEvent(eventKind events.EventKind, csl *Console) events.CommonEvent {
 	return events.CommonEvent{
-		Version:     "v1alpha1",
+		Version:     events.Version,
 		Kind:        events.KindConsole,
 		Event:       eventKind,
 		ObservedAt:  time.Now().UTC(),
@@ -125,6 +128,9 @@ func (l *lifecycleEventRecorderImpl) ConsoleRequest(ctx context.Context, csl *Co
 			AuthorisationRuleName:  authRuleName,
 			Timestamp:              csl.CreationTimestamp.Time,
 			Labels:                 csl.Labels,
+			SharedWith:             csl.Spec.SharedWith,
+			TemplateGeneration:     csl.Status.TemplateGeneration,
+			TemplateHash:           csl.Status.TemplateHash,
 		},
 	}
 
@@ -177,13 +183,16 @@ func (l *lifecycleEventRecorderImpl) ConsoleStart(ctx context.Context, csl *Cons
 	return nil
 }
 
-func (l *lifecycleEventRecorderImpl) ConsoleAttach(ctx context.Context, csl *Console, username string, containerName string) error {
+func (l *lifecycleEventRecorderImpl) ConsoleAttach(ctx context.Context, csl *Console, username string, containerName string, subresource string, attachSubject bool) error {
 	event := &events.ConsoleAttachEvent{
 		CommonEvent: l.makeConsoleCommonEvent(events.EventAttach, csl),
 		Spec: events.ConsoleAttachSpec{
-			Username:  username,
-			Pod:       csl.Status.PodName,
-			Container: containerName,
+			Username:      username,
+			Pod:           csl.Status.PodName,
+			Container:     containerName,
+			Subresource:   subresource,
+			Collaborator:  csl.IsCollaborator(username),
+			AttachSubject: attachSubject && username != csl.Spec.User,
 		},
 	}
 
@@ -198,7 +207,60 @@ func (l *lifecycleEventRecorderImpl) ConsoleAttach(ctx context.Context, csl *Con
 	return nil
 }
 
-func (l *lifecycleEventRecorderImpl) ConsoleTerminate(ctx context.Context, csl *Console, timedOut bool, pod *corev1.Pod) error {
+func (l *lifecycleEventRecorderImpl) ConsoleAttachDenied(ctx context.Context, csl *Console, username string, containerName string, subresource string, reason string) error {
+	event := &events.ConsoleAttachDeniedEvent{
+		CommonEvent: l.makeConsoleCommonEvent(events.EventAttachDenied, csl),
+		Spec: events.ConsoleAttachDeniedSpec{
+			Username:    username,
+			Pod:         csl.Status.PodName,
+			Container:   containerName,
+			Subresource: subresource,
+			Reason:      reason,
+		},
+	}
+
+	id, err := l.publisher.Publish(ctx, event)
+	if err != nil {
+		lifecycleEventsPublishErrors.WithLabelValues("console_attach_denied").Inc()
+		return err
+	}
+	lifecycleEventsPublish.WithLabelValues("console_attach_denied").Inc()
+
+	l.logger.Info("event recorded", "id", id, "event", events.EventAttachDenied)
+	return nil
+}
+
+func (l *lifecycleEventRecorderImpl) ConsoleAttachClosed(ctx context.Context, csl *Console, session ConsoleAttachSession, reason events.AttachClosedReason) error {
+	closedAt := time.Now()
+	if session.EndTime != nil {
+		closedAt = session.EndTime.Time
+	}
+
+	event := &events.ConsoleAttachClosedEvent{
+		CommonEvent: l.makeConsoleCommonEvent(events.EventAttachClosed, csl),
+		Spec: events.ConsoleAttachClosedSpec{
+			Username:     session.Username,
+			Pod:          csl.Status.PodName,
+			Container:    session.Container,
+			Collaborator: csl.IsCollaborator(session.Username),
+			AttachedAt:   session.StartTime.Time.UTC(),
+			ClosedAt:     closedAt.UTC(),
+			Reason:       reason,
+		},
+	}
+
+	id, err := l.publisher.Publish(ctx, event)
+	if err != nil {
+		lifecycleEventsPublishErrors.WithLabelValues("console_attach_closed").Inc()
+		return err
+	}
+	lifecycleEventsPublish.WithLabelValues("console_attach_closed").Inc()
+
+	l.logger.Info("event recorded", "id", id, "event", events.EventAttachClosed)
+	return nil
+}
+
+func (l *lifecycleEventRecorderImpl) ConsoleTerminate(ctx context.Context, csl *Console, reason events.TerminateReason, timedOut bool, pod *corev1.Pod) error {
 	containerStatuses := make(map[string]string)
 	exitCodes := make(map[string]int32)
 	if pod != nil {
@@ -210,6 +272,7 @@ func (l *lifecycleEventRecorderImpl) ConsoleTerminate(ctx context.Context, csl *
 	event := &events.ConsoleTerminatedEvent{
 		CommonEvent: l.makeConsoleCommonEvent(events.EventTerminated, csl),
 		Spec: events.ConsoleTerminatedSpec{
+			Reason:            reason,
 			TimedOut:          timedOut,
 			ContainerStatuses: containerStatuses,
 			ExitCodes:         exitCodes,
@@ -227,6 +290,40 @@ func (l *lifecycleEventRecorderImpl) ConsoleTerminate(ctx context.Context, csl *
 	return nil
 }
 
+func (l *lifecycleEventRecorderImpl) ConsoleTemplateChange(ctx context.Context, template *ConsoleTemplate, operation string, username string, changes []events.TemplateFieldChange, pendingConsoles []string) error {
+	event := &events.ConsoleTemplateChangeEvent{
+		CommonEvent: events.CommonEvent{
+			Version:    events.Version,
+			Kind:       events.KindConsoleTemplate,
+			Event:      events.EventTemplateChange,
+			ObservedAt: time.Now().UTC(),
+			// Shared by every change to the template, until it is recreated
+			Id:          events.NewConsoleEventID(l.contextName, template.Namespace, template.Name, template.CreationTimestamp.Time),
+			Annotations: map[string]string{},
+		},
+		Spec: events.ConsoleTemplateChangeSpec{
+			Username:        username,
+			Operation:       operation,
+			Context:         l.contextName,
+			Namespace:       template.Namespace,
+			ConsoleTemplate: template.Name,
+			Generation:      template.Generation,
+			Changes:         changes,
+			PendingConsoles: pendingConsoles,
+		},
+	}
+
+	id, err := l.publisher.Publish(ctx, event)
+	if err != nil {
+		lifecycleEventsPublishErrors.WithLabelValues("console_template_change").Inc()
+		return err
+	}
+	lifecycleEventsPublish.WithLabelValues("console_template_change").Inc()
+
+	l.logger.Info("event recorded", "id", id, "event", events.EventTemplateChange)
+	return nil
+}
+
 func appendStatusMessages(containerStatusResult map[string]string, exitCodeResult map[string]int32, containerStatuses []corev1.ContainerStatus) {
 	if containerStatuses == nil {
 		return
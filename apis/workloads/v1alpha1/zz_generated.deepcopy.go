@@ -36,6 +36,26 @@ func (in *Console) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleAttachSession) DeepCopyInto(out *ConsoleAttachSession) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleAttachSession.
+func (in *ConsoleAttachSession) DeepCopy() *ConsoleAttachSession {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleAttachSession)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsoleAuthorisation) DeepCopyInto(out *ConsoleAuthorisation) {
 	*out = *in
@@ -165,6 +185,11 @@ func (in *ConsoleAuthorisationUpdate) DeepCopyInto(out *ConsoleAuthorisationUpda
 		*out = new(ConsoleAuthorisation)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.collaborators != nil {
+		in, out := &in.collaborators, &out.collaborators
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleAuthorisationUpdate.
@@ -197,6 +222,132 @@ func (in *ConsoleAuthorisers) DeepCopy() *ConsoleAuthorisers {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleCapturedOutput) DeepCopyInto(out *ConsoleCapturedOutput) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleCapturedOutput.
+func (in *ConsoleCapturedOutput) DeepCopy() *ConsoleCapturedOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleCapturedOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleHistory) DeepCopyInto(out *ConsoleHistory) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleHistory.
+func (in *ConsoleHistory) DeepCopy() *ConsoleHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleHistory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConsoleHistory) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleHistoryList) DeepCopyInto(out *ConsoleHistoryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ConsoleHistory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleHistoryList.
+func (in *ConsoleHistoryList) DeepCopy() *ConsoleHistoryList {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleHistoryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConsoleHistoryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleHistorySpec) DeepCopyInto(out *ConsoleHistorySpec) {
+	*out = *in
+	out.ConsoleTemplateRef = in.ConsoleTemplateRef
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SharedWith != nil {
+		in, out := &in.SharedWith, &out.SharedWith
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Authorisers != nil {
+		in, out := &in.Authorisers, &out.Authorisers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.CreationTime.DeepCopyInto(&out.CreationTime)
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AttachSessions != nil {
+		in, out := &in.AttachSessions, &out.AttachSessions
+		*out = make([]ConsoleAttachSession, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.ExpiryTime.DeepCopyInto(&out.ExpiryTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleHistorySpec.
+func (in *ConsoleHistorySpec) DeepCopy() *ConsoleHistorySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleHistorySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsoleList) DeepCopyInto(out *ConsoleList) {
 	*out = *in
@@ -229,6 +380,160 @@ func (in *ConsoleList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleOutputCapture) DeepCopyInto(out *ConsoleOutputCapture) {
+	*out = *in
+	if in.MaxBytes != nil {
+		in, out := &in.MaxBytes, &out.MaxBytes
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleOutputCapture.
+func (in *ConsoleOutputCapture) DeepCopy() *ConsoleOutputCapture {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleOutputCapture)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleSchedule) DeepCopyInto(out *ConsoleSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleSchedule.
+func (in *ConsoleSchedule) DeepCopy() *ConsoleSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConsoleSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleScheduleList) DeepCopyInto(out *ConsoleScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ConsoleSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleScheduleList.
+func (in *ConsoleScheduleList) DeepCopy() *ConsoleScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConsoleScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleScheduleSpec) DeepCopyInto(out *ConsoleScheduleSpec) {
+	*out = *in
+	out.ConsoleTemplateRef = in.ConsoleTemplateRef
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.ExpiryTime.DeepCopyInto(&out.ExpiryTime)
+	if in.Authorisations != nil {
+		in, out := &in.Authorisations, &out.Authorisations
+		*out = make([]v1.Subject, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleScheduleSpec.
+func (in *ConsoleScheduleSpec) DeepCopy() *ConsoleScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleScheduleStatus) DeepCopyInto(out *ConsoleScheduleStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleScheduleStatus.
+func (in *ConsoleScheduleStatus) DeepCopy() *ConsoleScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleScheduleUpdate) DeepCopyInto(out *ConsoleScheduleUpdate) {
+	*out = *in
+	if in.existing != nil {
+		in, out := &in.existing, &out.existing
+		*out = new(ConsoleSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.updated != nil {
+		in, out := &in.updated, &out.updated
+		*out = new(ConsoleSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleScheduleUpdate.
+func (in *ConsoleScheduleUpdate) DeepCopy() *ConsoleScheduleUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleScheduleUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsoleSpec) DeepCopyInto(out *ConsoleSpec) {
 	*out = *in
@@ -248,6 +553,11 @@ func (in *ConsoleSpec) DeepCopyInto(out *ConsoleSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.SharedWith != nil {
+		in, out := &in.SharedWith, &out.SharedWith
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleSpec.
@@ -271,6 +581,18 @@ func (in *ConsoleStatus) DeepCopyInto(out *ConsoleStatus) {
 		in, out := &in.CompletionTime, &out.CompletionTime
 		*out = (*in).DeepCopy()
 	}
+	if in.AttachSessions != nil {
+		in, out := &in.AttachSessions, &out.AttachSessions
+		*out = make([]ConsoleAttachSession, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CapturedOutput != nil {
+		in, out := &in.CapturedOutput, &out.CapturedOutput
+		*out = new(ConsoleCapturedOutput)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleStatus.
@@ -373,6 +695,11 @@ func (in *ConsoleTemplateSpec) DeepCopyInto(out *ConsoleTemplateSpec) {
 		*out = new(ConsoleAuthorisers)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.OutputCapture != nil {
+		in, out := &in.OutputCapture, &out.OutputCapture
+		*out = new(ConsoleOutputCapture)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleTemplateSpec.
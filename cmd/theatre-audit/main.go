@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/go-logr/logr"
+
+	"github.com/gocardless/theatre/v3/cmd"
+	"github.com/gocardless/theatre/v3/pkg/signals"
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/audit"
+)
+
+var (
+	app = kingpin.New("theatre-audit", "Rebuilds console timelines from lifecycle events, flagging anomalies").Version(cmd.VersionStanza())
+
+	commonOpts = cmd.NewCommonOptions(app)
+
+	output        = app.Flag("output", "Output format. One of: table|json").Short('o').Default(audit.OutputTable).Enum(audit.OutputTable, audit.OutputJSON)
+	consoleName   = app.Flag("console", "Only report on consoles with this name").String()
+	anomaliesOnly = app.Flag("anomalies-only", "Only report on consoles with anomalies").Bool()
+
+	file     = app.Command("file", "Read events from a file with one JSON event per line")
+	filePath = file.Arg("path", "Path of the file, or - to read from stdin").Required().String()
+
+	httpCmd           = app.Command("http", "Receive events POSTed by the workloads-manager's HTTP publisher, reporting once done")
+	httpListenAddress = httpCmd.Flag("listen-address", "Address to receive events on").Default(":8080").String()
+	httpSigningSecret = httpCmd.Flag("signing-secret", "Secret that events are signed with, rejecting those with an invalid signature").Envar("THEATRE_AUDIT_SIGNING_SECRET").String()
+	httpFor           = httpCmd.Flag("for", "How long to receive events for. If not given, events are received until interrupted").Duration()
+
+	pubsubCmd          = app.Command("pubsub", "Pull events from a Pub/Sub subscription, reporting once done")
+	pubsubProjectId    = pubsubCmd.Flag("project-id", "ID of the project containing the subscription").Required().String()
+	pubsubSubscription = pubsubCmd.Flag("subscription-id", "ID of a subscription to the lifecycle events topic, dedicated to auditing as messages are acknowledged").Required().String()
+	pubsubDedicated    = pubsubCmd.Flag("dedicated-subscription", "Confirm the subscription is dedicated to auditing. Required, as the messages pulled are acknowledged and so removed from it").Bool()
+	pubsubFor          = pubsubCmd.Flag("for", "How long to pull events for. If not given, events are pulled until interrupted").Duration()
+)
+
+func main() {
+	command := kingpin.MustParse(app.Parse(os.Args[1:]))
+	logger := commonOpts.Logger()
+
+	ctx, cancel := signals.SetupSignalHandler()
+	defer cancel()
+
+	// Messages pulled from Pub/Sub are only acknowledged once reported
+	if command == pubsubCmd.FullCommand() {
+		if err := receivePubSub(ctx, logger); err != nil {
+			app.Fatalf("%v", err)
+		}
+		return
+	}
+
+	evs, err := readEvents(ctx, logger, command)
+	if err != nil {
+		app.Fatalf("failed to read events: %v", err)
+	}
+
+	if err := report(evs); err != nil {
+		app.Fatalf("%v", err)
+	}
+}
+
+// report prints the report of the timelines built from the events
+func report(evs []audit.Event) error {
+	timelines, err := audit.Build(evs)
+	if err != nil {
+		return fmt.Errorf("failed to build timelines: %w", err)
+	}
+
+	timelines = audit.Filter(timelines, *consoleName, *anomaliesOnly)
+	if err := audit.PrintReport(os.Stdout, *output, timelines); err != nil {
+		return fmt.Errorf("failed to print report: %w", err)
+	}
+
+	return nil
+}
+
+func receivePubSub(ctx context.Context, logger logr.Logger) error {
+	if !*pubsubDedicated {
+		return errors.New("messages pulled from the subscription are acknowledged, which removes them for any other consumer: pass --dedicated-subscription to confirm it is dedicated to auditing")
+	}
+
+	ctx, cancel := withOptionalTimeout(ctx, *pubsubFor)
+	defer cancel()
+
+	logger.Info("pulling events", "project", *pubsubProjectId, "subscription", *pubsubSubscription)
+	return audit.ReceivePubSub(ctx, logger, *pubsubProjectId, *pubsubSubscription, report)
+}
+
+func readEvents(ctx context.Context, logger logr.Logger, command string) ([]audit.Event, error) {
+	switch command {
+	case file.FullCommand():
+		var r io.Reader = os.Stdin
+		if *filePath != "-" {
+			f, err := os.Open(*filePath)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		return audit.ReadJSONL(r)
+	case httpCmd.FullCommand():
+		ctx, cancel := withOptionalTimeout(ctx, *httpFor)
+		defer cancel()
+
+		collector := &audit.Collector{}
+		server := &http.Server{
+			Addr: *httpListenAddress,
+			Handler: &audit.Receiver{
+				Collector:     collector,
+				SigningSecret: *httpSigningSecret,
+				Logger:        logger,
+			},
+		}
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+		}()
+
+		logger.Info("receiving events", "address", *httpListenAddress)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return nil, err
+		}
+
+		return collector.Events(), nil
+	}
+
+	return nil, errors.New("unknown command: " + command)
+}
+
+// withOptionalTimeout returns a context that is cancelled after the timeout,
+// if it is given
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
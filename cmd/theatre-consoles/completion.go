@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/alecthomas/kingpin"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/config"
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/runner"
+)
+
+// Each script asks theatre-consoles for completions using kingpin's hidden
+// --completion-bash flag. The word being completed is only passed along when
+// it's a flag, as kingpin would otherwise treat a partial flag value or
+// argument as already given, and offer nothing for it.
+var completionScripts = map[string]string{
+	"bash": bashCompletionScript,
+	"zsh":  zshCompletionScript,
+	"fish": fishCompletionScript,
+}
+
+const bashCompletionScript = `_theatre_consoles() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local words=("${COMP_WORDS[@]:1:COMP_CWORD-1}")
+    if [[ "${cur}" == -* ]]; then
+        words+=("${cur}")
+    fi
+    COMPREPLY=( $(compgen -W "$("${COMP_WORDS[0]}" --completion-bash "${words[@]}" 2>/dev/null)" -- "${cur}") )
+}
+complete -F _theatre_consoles theatre-consoles
+`
+
+const zshCompletionScript = `#compdef theatre-consoles
+autoload -U +X bashcompinit && bashcompinit
+
+` + bashCompletionScript
+
+const fishCompletionScript = `function __theatre_consoles_complete
+    set -l words (commandline -opc)
+    set -l cur (commandline -ct)
+    if string match -q -- '-*' $cur
+        set words $words $cur
+    end
+    $words[1] --completion-bash $words[2..-1] 2>/dev/null
+end
+complete -c theatre-consoles -f -a '(__theatre_consoles_complete)'
+`
+
+func printCompletionScript(out io.Writer, shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell: %s", shell)
+	}
+
+	_, err := fmt.Fprint(out, script)
+	return err
+}
+
+// completeConsoleNames completes the names of consoles in any of the given
+// phases, or all consoles if none are given
+func completeConsoleNames(phases ...workloadsv1alpha1.ConsolePhase) kingpin.HintAction {
+	return func() []string {
+		consoleRunner, namespace, err := completionRunner()
+		if err != nil {
+			return nil
+		}
+
+		names, err := consoleRunner.CompleteConsoleNames(namespace, phases...)
+		if err != nil {
+			return nil
+		}
+
+		return names
+	}
+}
+
+// completeTemplateSelectors completes a selector for each label of the console
+// templates
+func completeTemplateSelectors() []string {
+	consoleRunner, namespace, err := completionRunner()
+	if err != nil {
+		return nil
+	}
+
+	selectors, err := consoleRunner.CompleteTemplateSelectors(namespace)
+	if err != nil {
+		return nil
+	}
+
+	return selectors
+}
+
+// completeAliases completes the names of the aliases in the configuration file
+func completeAliases() []string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+
+	aliases := []string{}
+	for name := range cfg.Aliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+
+	return aliases
+}
+
+// completionRunner builds a runner for the context and namespace that the
+// command being completed would use. Completion shouldn't print errors, so any
+// problem with the configuration file is ignored.
+func completionRunner() (*runner.Runner, string, error) {
+	kctx, namespace := *cliContext, *cliNamespace
+	if cfg, err := loadConfig(); err == nil {
+		if opts, err := cfg.Resolve("", config.Options{Context: kctx, Namespace: namespace}); err == nil {
+			kctx, namespace = opts.Context, opts.Namespace
+		}
+	}
+
+	kubeConfig, err := newKubeConfig(kctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	consoleRunner, err := runner.New(kubeConfig)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return consoleRunner, namespace, nil
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	stdlog "log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	kitlog "github.com/go-kit/kit/log"
@@ -15,10 +20,12 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
+	"k8s.io/kubectl/pkg/util/term"
 
 	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
 	"github.com/gocardless/theatre/v3/cmd"
 	"github.com/gocardless/theatre/v3/pkg/signals"
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/config"
 	"github.com/gocardless/theatre/v3/pkg/workloads/console/runner"
 )
 
@@ -33,11 +40,14 @@ var (
 			Short('n').
 			Envar("KUBERNETES_NAMESPACE").
 			String()
+	cliConfig = cli.Flag("config", "Path to the configuration file. If not provided defaults to ~/.config/theatre/consoles.yaml, if it exists").
+			Envar("THEATRE_CONSOLES_CONFIG").
+			String()
 
 	create         = cli.Command("create", "Creates a new console given a template")
-	createSelector = create.Flag("selector", "Selector to match a console template").
+	createSelector = create.Flag("selector", "Selector to match a console template. Can be omitted if the first argument is an alias from the configuration file").
 			Short('s').
-			Required().
+			HintAction(completeTemplateSelectors).
 			String()
 	createTimeout = create.Flag("timeout", "Timeout for the new console").
 			Duration()
@@ -46,13 +56,66 @@ var (
 	createNoninteractive = create.Flag("noninteractive", "Do not enable TTY and STDIN on console container").
 				Bool()
 	createAttach = create.Flag("attach", "Attach to the console if it starts successfully").
+			Action(func(*kingpin.ParseContext) error { createAttachSet = true; return nil }).
 			Bool()
-	createCommand = create.Arg("command", "Command to run in console").
+	createShareWith = create.Flag("share-with", "Comma separated list of users to share the console with, allowing them to attach").
+			String()
+	createDryRun = create.Flag("dry-run", "Show the authorisation rule, limits and job the console would get, without creating it").
+			Bool()
+	createAllNamespaces = create.Flag("all-namespaces", "Create a console from every template matching the selector, in any namespace, rather than requiring exactly one").
+				Bool()
+	createContexts = create.Flag("contexts", "Comma separated list of Kubernetes contexts to create consoles in, from every template matching the selector").
+			String()
+	createCommand = create.Arg("command", "Command to run in console, optionally preceded by an alias").
+			HintAction(completeAliases).
 			Strings()
 
+	explainRule         = cli.Command("explain-rule", "Show the authorisation rule, limits and job a console would get, without creating it")
+	explainRuleSelector = explainRule.Flag("selector", "Selector to match a console template. Can be omitted if the first argument is an alias from the configuration file").
+				Short('s').
+				HintAction(completeTemplateSelectors).
+				String()
+	explainRuleTimeout = explainRule.Flag("timeout", "Timeout for the console").
+				Duration()
+	explainRuleNoninteractive = explainRule.Flag("noninteractive", "Do not enable TTY and STDIN on console container").
+					Bool()
+	explainRuleCommand = explainRule.Arg("command", "Command to run in console, optionally preceded by an alias").
+				HintAction(completeAliases).
+				Strings()
+
+	run         = cli.Command("run", "Runs a non-interactive console to completion, streaming its output and exiting with its exit code")
+	runSelector = run.Flag("selector", "Selector to match a console template. Can be omitted if the first argument is an alias from the configuration file").
+			Short('s').
+			HintAction(completeTemplateSelectors).
+			String()
+	runTimeout = run.Flag("timeout", "Timeout for the new console").
+			Duration()
+	runStartTimeout = run.Flag("start-timeout", "Maximum time to wait for the console to be authorised and start. Waits indefinitely if not set").
+			Duration()
+	runReason = run.Flag("reason", "Reason for creating console").
+			String()
+	runShareWith = run.Flag("share-with", "Comma separated list of users to share the console with, allowing them to attach").
+			String()
+	runCommand = run.Arg("command", "Command to run in console, optionally preceded by an alias").
+			HintAction(completeAliases).
+			Strings()
+
+	rerun     = cli.Command("rerun", "Creates a new console with the same command, reason, timeout and interactivity as an existing or recently deleted console")
+	rerunName = rerun.Flag("name", "Name of the console to re-run").
+			Required().
+			HintAction(completeConsoleNames()).
+			String()
+	rerunTimeout = rerun.Flag("timeout", "Timeout for the new console, if different from the original").
+			Duration()
+	rerunReason = rerun.Flag("reason", "Reason for creating the console, if different from the original").
+			String()
+	rerunAttach = rerun.Flag("attach", "Attach to the console if it starts successfully").
+			Bool()
+
 	attach     = cli.Command("attach", "Attach to a running console")
 	attachName = attach.Flag("name", "Console name").
 			Required().
+			HintAction(completeConsoleNames(workloadsv1alpha1.ConsoleRunning)).
 			String()
 
 	list         = cli.Command("list", "List currently running consoles")
@@ -62,19 +125,97 @@ var (
 			String()
 	listSelector = list.Flag("selector", "Selector to match the console").
 			Short('s').
+			HintAction(completeTemplateSelectors).
+			Default("").
+			String()
+	listOutput = list.Flag("output", "Output format. One of: json|yaml|wide|name|jsonpath=<template>").
+			Short('o').
+			Default("").
+			String()
+	listAllContexts = list.Flag("all-contexts", "List consoles in every context of your kubeconfig").
+			Bool()
+	listContexts = list.Flag("contexts", "Comma separated list of Kubernetes contexts to list consoles in").
+			String()
+
+	history         = cli.Command("history", "List consoles that have finished and been deleted")
+	historyUsername = history.Flag("user", "Only list consoles belonging to this Kubernetes username").
+			Short('u').
+			Default("").
+			String()
+	historySelector = history.Flag("selector", "Selector to match the console").
+			Short('s').
+			HintAction(completeTemplateSelectors).
+			Default("").
+			String()
+	historySince = history.Flag("since", "Only list consoles created within this duration, such as 7d or 12h").
+			Default("").
+			String()
+	historyOutput = history.Flag("output", "Output format. One of: json|yaml|wide|name|jsonpath=<template>").
+			Short('o').
 			Default("").
 			String()
 
+	get     = cli.Command("get", "Get a console")
+	getName = get.Flag("name", "Console name").
+		Required().
+		HintAction(completeConsoleNames()).
+		String()
+	getOutput = get.Flag("output", "Output format. One of: json|yaml|wide|name|jsonpath=<template>").
+			Short('o').
+			Default("").
+			String()
+
+	logs     = cli.Command("logs", "Show the output of a console, including once its pod has gone if the output was captured")
+	logsName = logs.Flag("name", "Console name").
+			Required().
+			HintAction(completeConsoleNames()).
+			String()
+	logsFollow = logs.Flag("follow", "Follow the output of a running console until it exits").
+			Short('f').
+			Bool()
+
+	describe     = cli.Command("describe", "Show details of a console, including its authorisation and recent events")
+	describeName = describe.Flag("name", "Console name").
+			Required().
+			HintAction(completeConsoleNames()).
+			String()
+
+	pending     = cli.Command("pending", "List consoles awaiting authorisation that you can authorise")
+	pendingUser = pending.Flag("user", "Your username, as the Kubernetes API recognises you. Not usually supplied, as it is asked of the API").
+			Default("").
+			String()
+	pendingNoPrompt = pending.Flag("no-prompt", "Only list the consoles, without offering to authorise one").
+			Bool()
+	pendingAllContexts = pending.Flag("all-contexts", "List consoles in every context of your kubeconfig").
+				Bool()
+	pendingContexts = pending.Flag("contexts", "Comma separated list of Kubernetes contexts to list consoles in").
+			String()
+
 	authorise     = cli.Command("authorise", "Authorise a peer-reviewed console request")
 	authoriseUser = authorise.Flag("user", "Name of the user to attribute to verification. This must match the username that the Kubernetes API recognises you as").
 			String()
 	authoriseName = authorise.Flag("name", "Console to authorise").
-			Required().
+			HintAction(completeConsoleNames(workloadsv1alpha1.ConsolePendingAuthorisation)).
 			String()
+	authoriseBatch = authorise.Flag("batch", "Authorise every console in a batch created with create --all-namespaces or --contexts").
+			String()
+	authoriseContexts = authorise.Flag("contexts", "Comma separated list of Kubernetes contexts to authorise the batch in").
+				String()
+	authoriseYes = authorise.Flag("yes", "Authorise the batch without asking for confirmation").
+			Bool()
 	authoriseAttach = authorise.Flag("attach", "Attach to the console if it starts successfully").
 			Bool()
+
+	completion      = cli.Command("completion", "Output a shell completion script, with completion of console names, template selectors and aliases")
+	completionShell = completion.Arg("shell", "Shell to complete in. One of: bash|zsh|fish").
+			Required().
+			HintOptions("bash", "zsh", "fish").
+			Enum("bash", "zsh", "fish")
 )
 
+// Whether --attach was given to create, which can't be told from its value
+var createAttachSet bool
+
 func main() {
 	// Set up logging
 	logger := kitlog.NewLogfmtLogger(os.Stderr)
@@ -85,7 +226,16 @@ func main() {
 
 	ctx, _ := signals.SetupSignalHandler()
 
-	if err := Run(ctx, logger); err != nil && !errors.Is(err, context.Canceled) {
+	err := Run(ctx, logger)
+
+	// The console has already reported its outcome, so we only need to exit
+	// with its code
+	var exitErr exitCodeError
+	if errors.As(err, &exitErr) {
+		os.Exit(int(exitErr))
+	}
+
+	if err != nil && !errors.Is(err, context.Canceled) {
 		cli.Fatalf("unexpected error: %s", err)
 	}
 }
@@ -97,6 +247,16 @@ func Run(ctx context.Context, logger kitlog.Logger) error {
 	// This is done here to bind the flags without creating multiple global variables.
 	cmd := kingpin.MustParse(cli.Parse(os.Args[1:]))
 
+	// Completion scripts are static, so don't need a cluster
+	if cmd == completion.FullCommand() {
+		return printCompletionScript(os.Stdout, *completionShell)
+	}
+
+	// Fill in any options that weren't given from the configuration file
+	if err := applyConfig(cmd); err != nil {
+		return err
+	}
+
 	config, err := newKubeConfig(*cliContext)
 	if err != nil {
 		return err
@@ -110,6 +270,34 @@ func Run(ctx context.Context, logger kitlog.Logger) error {
 	// Match on the kingpin command and enter the main command
 	switch cmd {
 	case create.FullCommand():
+		if *createAllNamespaces || *createContexts != "" {
+			if *createDryRun || *createAttach {
+				return errors.New("--dry-run and --attach can't be used when creating consoles across namespaces or contexts")
+			}
+
+			return fanOutConsoles(ctx, logger, parseContextList(*createContexts), runner.FanOutOptions{
+				Namespace:      *cliNamespace,
+				Selector:       *createSelector,
+				Timeout:        *createTimeout,
+				Reason:         *createReason,
+				Command:        *createCommand,
+				Noninteractive: *createNoninteractive,
+				SharedWith:     parseUserList(*createShareWith),
+			})
+		}
+
+		if *createDryRun {
+			return explainConsole(ctx, consoleRunner, runner.ExplainOptions{
+				Namespace:      *cliNamespace,
+				Selector:       *createSelector,
+				Timeout:        *createTimeout,
+				Reason:         *createReason,
+				Command:        *createCommand,
+				Noninteractive: *createNoninteractive,
+				SharedWith:     parseUserList(*createShareWith),
+			})
+		}
+
 		_, err = consoleRunner.Create(
 			ctx,
 			runner.CreateOptions{
@@ -120,6 +308,7 @@ func Run(ctx context.Context, logger kitlog.Logger) error {
 				Command:        *createCommand,
 				Attach:         *createAttach,
 				Noninteractive: *createNoninteractive,
+				SharedWith:     parseUserList(*createShareWith),
 				KubeConfig:     config,
 				IO: runner.IOStreams{
 					In:     os.Stdin,
@@ -130,6 +319,54 @@ func Run(ctx context.Context, logger kitlog.Logger) error {
 			},
 		)
 		return err
+	case run.FullCommand():
+		result, err := consoleRunner.Run(
+			ctx,
+			runner.RunOptions{
+				Namespace:    *cliNamespace,
+				Selector:     *runSelector,
+				Timeout:      *runTimeout,
+				StartTimeout: *runStartTimeout,
+				Reason:       *runReason,
+				Command:      *runCommand,
+				SharedWith:   parseUserList(*runShareWith),
+				IO: runner.IOStreams{
+					Out:    os.Stdout,
+					ErrOut: os.Stderr,
+				},
+			},
+		)
+		if result == nil || result.ExitCode == 0 {
+			return err
+		}
+		return exitCodeError(result.ExitCode)
+	case rerun.FullCommand():
+		_, err = consoleRunner.Rerun(
+			ctx,
+			runner.RerunOptions{
+				Namespace:  *cliNamespace,
+				Name:       *rerunName,
+				Timeout:    *rerunTimeout,
+				Reason:     *rerunReason,
+				Attach:     *rerunAttach,
+				KubeConfig: config,
+				IO: runner.IOStreams{
+					In:     os.Stdin,
+					Out:    os.Stdout,
+					ErrOut: os.Stderr,
+				},
+				Hook: LifecyclePrinter(logger),
+			},
+		)
+		return err
+	case explainRule.FullCommand():
+		return explainConsole(ctx, consoleRunner, runner.ExplainOptions{
+			Namespace:      *cliNamespace,
+			Selector:       *explainRuleSelector,
+			Timeout:        *explainRuleTimeout,
+			Command:        *explainRuleCommand,
+			Noninteractive: *explainRuleNoninteractive,
+		})
 	case attach.FullCommand():
 		return consoleRunner.Attach(
 			ctx,
@@ -146,17 +383,169 @@ func Run(ctx context.Context, logger kitlog.Logger) error {
 			},
 		)
 	case list.FullCommand():
+		contexts, err := selectContexts(*listAllContexts, *listContexts)
+		if err != nil {
+			return err
+		}
+		if len(contexts) > 0 {
+			return listAcrossContexts(contexts, runner.ListOptions{
+				Namespace:    *cliNamespace,
+				Username:     *listUsername,
+				Selector:     *listSelector,
+				Output:       os.Stdout,
+				OutputFormat: *listOutput,
+			})
+		}
+
 		_, err = consoleRunner.List(
 			ctx,
 			runner.ListOptions{
+				Namespace:    *cliNamespace,
+				Username:     *listUsername,
+				Selector:     *listSelector,
+				Output:       os.Stdout,
+				OutputFormat: *listOutput,
+			},
+		)
+		return err
+	case history.FullCommand():
+		var since time.Duration
+		if *historySince != "" {
+			since, err = runner.ParseSince(*historySince)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = consoleRunner.History(
+			ctx,
+			runner.HistoryOptions{
+				Namespace:    *cliNamespace,
+				Username:     *historyUsername,
+				Selector:     *historySelector,
+				Since:        since,
+				Output:       os.Stdout,
+				OutputFormat: *historyOutput,
+			},
+		)
+		return err
+	case get.FullCommand():
+		csl, err := consoleRunner.FindConsoleByName(*cliNamespace, *getName)
+		if err != nil {
+			return err
+		}
+		return runner.PrintConsole(os.Stdout, *getOutput, csl)
+	case logs.FullCommand():
+		return consoleRunner.Logs(
+			ctx,
+			runner.LogsOptions{
 				Namespace: *cliNamespace,
-				Username:  *listUsername,
-				Selector:  *listSelector,
+				Name:      *logsName,
+				Follow:    *logsFollow,
+				Output:    os.Stdout,
+			},
+		)
+	case describe.FullCommand():
+		_, err = consoleRunner.Describe(
+			ctx,
+			runner.DescribeOptions{
+				Namespace: *cliNamespace,
+				Name:      *describeName,
 				Output:    os.Stdout,
 			},
 		)
 		return err
+	case pending.FullCommand():
+		contexts, err := selectContexts(*pendingAllContexts, *pendingContexts)
+		if err != nil {
+			return err
+		}
+
+		if *pendingUser == "" {
+			username, err := consoleRunner.CurrentUser(ctx)
+			if err != nil {
+				return fmt.Errorf("%w: supply --user instead", err)
+			}
+			*pendingUser = username
+		}
+
+		// Each pending console is authorised in the context it was found in
+		runners := map[string]*runner.Runner{"": consoleRunner}
+		var pendingConsoles runner.PendingConsoleSlice
+		if len(contexts) > 0 {
+			targets, targetErrs := newTargets(contexts, *cliContext)
+			for _, target := range targets {
+				runners[target.Context] = target.Runner
+			}
+
+			var listErrs []runner.ContextError
+			pendingConsoles, listErrs = runner.ListPendingAcrossTargets(
+				ctx,
+				targets,
+				runner.PendingOptions{
+					Namespace: *cliNamespace,
+					Username:  *pendingUser,
+				},
+			)
+			if err := warnContextErrors(len(contexts), append(targetErrs, listErrs...)); err != nil {
+				return err
+			}
+		} else {
+			pendingConsoles, err = consoleRunner.ListPendingAuthorisation(
+				ctx,
+				runner.PendingOptions{
+					Namespace: *cliNamespace,
+					Username:  *pendingUser,
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+		if len(pendingConsoles) == 0 {
+			fmt.Fprintln(os.Stderr, "No consoles are awaiting your authorisation")
+			return nil
+		}
+		if err := pendingConsoles.Print(os.Stdout); err != nil {
+			return err
+		}
+
+		// Only offer to authorise a console when someone is there to answer
+		if *pendingNoPrompt || !(term.TTY{In: os.Stdin}).IsTerminalIn() {
+			return nil
+		}
+
+		selected, err := promptForPendingConsole(os.Stdin, os.Stdout, pendingConsoles)
+		if err != nil || selected == nil {
+			return err
+		}
+
+		return runners[selected.Context].Authorise(
+			ctx,
+			runner.AuthoriseOptions{
+				Namespace:   selected.Console.Namespace,
+				ConsoleName: selected.Console.Name,
+				Username:    *pendingUser,
+				KubeConfig:  config,
+				IO: runner.IOStreams{
+					In:     os.Stdin,
+					Out:    os.Stdout,
+					ErrOut: os.Stderr,
+				},
+			},
+		)
 	case authorise.FullCommand():
+		if *authoriseBatch != "" {
+			return authoriseBatchConsoles(ctx, parseContextList(*authoriseContexts), runner.AuthoriseBatchOptions{
+				Namespace: *cliNamespace,
+				Batch:     *authoriseBatch,
+				Username:  *authoriseUser,
+			}, *authoriseYes)
+		}
+		if *authoriseName == "" {
+			return errors.New("either --name or --batch must be provided")
+		}
+
 		err = consoleRunner.Authorise(
 			ctx,
 			runner.AuthoriseOptions{
@@ -244,3 +633,358 @@ func newKubeConfig(kctx string) (*rest.Config, error) {
 
 	return config, err
 }
+
+// applyConfig loads the configuration file, and uses it to fill in the
+// options of the command that weren't given by flags or environment variables.
+// Commands that create consoles accept an alias from the file as their first
+// argument, in place of a selector.
+func applyConfig(cmd string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case create.FullCommand():
+		return resolveConsoleOptions(cfg, createSelector, createCommand, createReason, createTimeout, createAttach, createAttachSet)
+	case run.FullCommand():
+		return resolveConsoleOptions(cfg, runSelector, runCommand, runReason, runTimeout, nil, false)
+	case explainRule.FullCommand():
+		return resolveConsoleOptions(cfg, explainRuleSelector, explainRuleCommand, nil, explainRuleTimeout, nil, false)
+	}
+
+	opts, err := cfg.Resolve("", config.Options{Context: *cliContext, Namespace: *cliNamespace})
+	if err != nil {
+		return err
+	}
+	*cliContext, *cliNamespace = opts.Context, opts.Namespace
+
+	return nil
+}
+
+// loadConfig loads the configuration file given by --config, or the default one
+// if it exists
+func loadConfig() (*config.Config, error) {
+	path, optional := *cliConfig, false
+	if path == "" {
+		defaultPath, err := config.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path, optional = defaultPath, true
+	}
+
+	cfg, err := config.Load(path, optional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration file: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// resolveConsoleOptions fills in the options of a command that creates a
+// console from the alias, if it is given as the first argument in place of a
+// selector, and the configuration file's defaults. The reason and attach
+// options are nil for commands that don't take them.
+func resolveConsoleOptions(cfg *config.Config, selector *string, args *[]string, reason *string, timeout *time.Duration, attach *bool, attachSet bool) error {
+	aliasName := ""
+	if *selector == "" {
+		if len(*args) == 0 {
+			return errors.New("either --selector or an alias from the configuration file must be provided")
+		}
+		if !cfg.HasAlias((*args)[0]) {
+			return fmt.Errorf("no --selector was provided, and %s is not an alias in the configuration file", (*args)[0])
+		}
+		aliasName, *args = (*args)[0], (*args)[1:]
+	}
+
+	given := config.Options{
+		Context:   *cliContext,
+		Namespace: *cliNamespace,
+		Selector:  *selector,
+		Timeout:   *timeout,
+	}
+	if reason != nil {
+		given.Reason = *reason
+	}
+	if attach != nil && attachSet {
+		given.Attach = attach
+	}
+
+	opts, err := cfg.Resolve(aliasName, given)
+	if err != nil {
+		return err
+	}
+
+	*cliContext, *cliNamespace, *selector, *timeout = opts.Context, opts.Namespace, opts.Selector, opts.Timeout
+	if reason != nil {
+		*reason = opts.Reason
+	}
+	if attach != nil && opts.Attach != nil {
+		*attach = *opts.Attach
+	}
+
+	return nil
+}
+
+// exitCodeError is returned when a command should exit with a specific code,
+// having already reported why
+type exitCodeError int32
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", int32(e))
+}
+
+// explainConsole prints the authorisation rule, limits and job that a console
+// would get, without creating it
+func explainConsole(ctx context.Context, consoleRunner *runner.Runner, opts runner.ExplainOptions) error {
+	explanation, err := consoleRunner.Explain(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return explanation.Print(os.Stdout)
+}
+
+// fanOutConsoles creates a console from every matching template in each of
+// the contexts, and reports the outcome for each of them
+func fanOutConsoles(ctx context.Context, logger kitlog.Logger, contexts []string, opts runner.FanOutOptions) error {
+	targets, targetErrs := newTargets(contexts, *cliContext)
+	if len(targetErrs) > 0 {
+		return targetErrs[0]
+	}
+
+	opts.Created = func(batch string, results runner.FanOutResultSlice) {
+		authoriseCmd := fmt.Sprintf("theatre-consoles authorise --batch %s", batch)
+		if len(contexts) > 0 {
+			authoriseCmd += fmt.Sprintf(" --contexts %s", strings.Join(contexts, ","))
+		}
+
+		logger.Log(
+			"msg", "Consoles have been requested",
+			"batch", batch,
+			"consoles", len(results)-len(results.Failed()),
+			"prompt", fmt.Sprintf("If the consoles require authorisation, they can all be authorised by running `%s --user {THEIR_USERNAME}`", authoriseCmd),
+		)
+	}
+
+	_, results, err := runner.FanOut(ctx, targets, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := results.Print(os.Stdout); err != nil {
+		return err
+	}
+
+	if failed := results.Failed(); len(failed) > 0 {
+		return fmt.Errorf("%d of %d consoles failed", len(failed), len(results))
+	}
+
+	return nil
+}
+
+// authoriseBatchConsoles authorises every console in the batch, in each of the
+// contexts, once the user has confirmed what they will run
+func authoriseBatchConsoles(ctx context.Context, contexts []string, opts runner.AuthoriseBatchOptions, confirmed bool) error {
+	targets, targetErrs := newTargets(contexts, *cliContext)
+	if len(targetErrs) > 0 {
+		return targetErrs[0]
+	}
+
+	// Every console must be checked before any are authorised, so don't
+	// continue with only part of the batch
+	consoles, listErrs := runner.ListBatchAcrossTargets(ctx, targets, opts)
+	if len(listErrs) > 0 {
+		return listErrs[0]
+	}
+
+	if err := runner.ValidateBatch(consoles); err != nil {
+		return err
+	}
+
+	if err := runner.PrintBatch(os.Stdout, consoles); err != nil {
+		return err
+	}
+
+	if !confirmed {
+		if !(term.TTY{In: os.Stdin}).IsTerminalIn() {
+			return errors.New("refusing to authorise the batch without confirmation, use --yes to confirm")
+		}
+
+		ok, err := promptForConfirmation(os.Stdin, os.Stdout, fmt.Sprintf("Authorise these %d consoles?", len(consoles)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("batch not authorised")
+		}
+	}
+
+	authorised, err := runner.AuthoriseBatch(ctx, targets, consoles, opts.Username)
+	for _, csl := range authorised {
+		fmt.Fprintf(os.Stdout, "Authorised %s/%s in %s\n", csl.Namespace, csl.Name, csl.Annotations[runner.ContextAnnotation])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to authorise consoles: %s\n", err)
+		return errors.New("failed to authorise some consoles in the batch")
+	}
+
+	return nil
+}
+
+// promptForConfirmation asks the user a yes or no question, returning whether
+// they answered yes
+func promptForConfirmation(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "\n%s [y/N]: ", question)
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// promptForPendingConsole asks the user which of the listed consoles they want
+// to authorise, returning nil if they don't choose one
+func promptForPendingConsole(in io.Reader, out io.Writer, pendingConsoles runner.PendingConsoleSlice) (*runner.PendingConsole, error) {
+	fmt.Fprintf(out, "\nEnter the number of a console to authorise, or press enter to skip: ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+
+	ix, err := strconv.Atoi(line)
+	if err != nil || ix < 1 || ix > len(pendingConsoles) {
+		return nil, fmt.Errorf("invalid selection: %s", line)
+	}
+
+	return &pendingConsoles[ix-1], nil
+}
+
+// parseUserList splits a comma separated list of users, ignoring any empty
+// entries and surrounding whitespace
+func parseUserList(list string) []string {
+	users := []string{}
+	for _, user := range strings.Split(list, ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			users = append(users, user)
+		}
+	}
+
+	return users
+}
+
+// parseContextList splits a comma separated list of kubernetes contexts,
+// ignoring any empty entries and surrounding whitespace
+func parseContextList(list string) []string {
+	contexts := []string{}
+	for _, kctx := range strings.Split(list, ",") {
+		if kctx = strings.TrimSpace(kctx); kctx != "" {
+			contexts = append(contexts, kctx)
+		}
+	}
+
+	return contexts
+}
+
+// newTargets builds a runner for each of the given kubernetes contexts, or for
+// the default context if none are given. Contexts that can't be configured are
+// returned as errors, alongside the targets for the others.
+func newTargets(contexts []string, defaultContext string) ([]runner.Target, []runner.ContextError) {
+	if len(contexts) == 0 {
+		contexts = []string{defaultContext}
+	}
+
+	targets := []runner.Target{}
+	var errs []runner.ContextError
+	for _, kctx := range contexts {
+		config, err := newKubeConfig(kctx)
+		if err == nil {
+			var consoleRunner *runner.Runner
+			if consoleRunner, err = runner.New(config); err == nil {
+				targets = append(targets, runner.Target{Context: contextName(kctx), Runner: consoleRunner})
+				continue
+			}
+		}
+
+		errs = append(errs, runner.ContextError{Context: contextName(kctx), Err: err})
+	}
+
+	return targets, errs
+}
+
+// selectContexts returns the contexts chosen by the --all-contexts or
+// --contexts flags, or none if neither was given
+func selectContexts(all bool, list string) ([]string, error) {
+	if !all {
+		return parseContextList(list), nil
+	}
+
+	rawConfig, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	contexts := []string{}
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+
+	return contexts, nil
+}
+
+// warnContextErrors reports the contexts that couldn't be queried, only
+// failing if none of them could be
+func warnContextErrors(contexts int, errs []runner.ContextError) error {
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+
+	if contexts > 0 && len(errs) >= contexts {
+		return errors.New("failed to query every context")
+	}
+
+	return nil
+}
+
+// listAcrossContexts lists the consoles in each of the contexts, with a
+// column showing the context of each
+func listAcrossContexts(contexts []string, opts runner.ListOptions) error {
+	// Check the output format before making any requests
+	if _, err := runner.NewConsolePrinter(opts.OutputFormat); err != nil {
+		return err
+	}
+
+	targets, targetErrs := newTargets(contexts, *cliContext)
+	consoles, listErrs := runner.ListAcrossTargets(targets, opts)
+	if err := warnContextErrors(len(contexts), append(targetErrs, listErrs...)); err != nil {
+		return err
+	}
+
+	return consoles.PrintAs(opts.Output, opts.OutputFormat)
+}
+
+// contextName returns the name of the given kubernetes context, resolving the
+// current context if it is empty
+func contextName(kctx string) string {
+	if kctx != "" {
+		return kctx
+	}
+
+	rawConfig, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return ""
+	}
+
+	return rawConfig.CurrentContext
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp" // this is required to auth against GCP
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -17,6 +19,8 @@ import (
 	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
 	"github.com/gocardless/theatre/v3/cmd"
 	consolecontroller "github.com/gocardless/theatre/v3/controllers/workloads/console"
+	consolehistorycontroller "github.com/gocardless/theatre/v3/controllers/workloads/consolehistory"
+	consoleschedulecontroller "github.com/gocardless/theatre/v3/controllers/workloads/consoleschedule"
 	"github.com/gocardless/theatre/v3/pkg/signals"
 	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
 )
@@ -25,13 +29,47 @@ var (
 	scheme = runtime.NewScheme()
 
 	app                    = kingpin.New("workloads-manager", "Manages workloads.crd.gocardless.com resources").Version(cmd.VersionStanza())
+	managerUsername        = app.Flag("manager-username", "Username the manager authenticates to the API server as, usually system:serviceaccount:<namespace>:<name>. Only the manager may change what it records on consoles").Envar("MANAGER_USERNAME").String()
 	contextName            = app.Flag("context-name", "Distinct name for the context this controller runs within. Usually the user-facing name of the kubernetes context for the cluster").Envar("CONTEXT_NAME").String()
 	pubsubProjectId        = app.Flag("pubsub-project-id", "ID for the project containing the Pub/Sub topic for console event publishing").Envar("PUBSUB_PROJECT_ID").String()
 	pubsubTopicId          = app.Flag("pubsub-topic-id", "ID of the topic to publish lifecycle event messages").Envar("PUBSUB_TOPIC_ID").String()
+	httpPublisherURL       = app.Flag("http-publisher-url", "URL to POST lifecycle event messages to as JSON, as an alternative to Pub/Sub").Envar("HTTP_PUBLISHER_URL").String()
+	httpPublisherHeaders   = app.Flag("http-publisher-header", "Header to add to each lifecycle event request, as Name=value. Can be given multiple times").Envar("HTTP_PUBLISHER_HEADERS").StringMap()
+	httpPublisherSecret    = app.Flag("http-publisher-signing-secret", "Secret used to sign each lifecycle event request with HMAC-SHA256").Envar("HTTP_PUBLISHER_SIGNING_SECRET").String()
+	httpPublisherTimeout   = app.Flag("http-publisher-timeout", "Timeout of each attempt to send a lifecycle event").Envar("HTTP_PUBLISHER_TIMEOUT").Default("10s").Duration()
+	httpPublisherRetries   = app.Flag("http-publisher-max-retries", "Number of times to retry sending a lifecycle event").Envar("HTTP_PUBLISHER_MAX_RETRIES").Default("5").Int()
+	httpPublisherBackoff   = app.Flag("http-publisher-backoff", "Delay before the first retry, doubling for each retry").Envar("HTTP_PUBLISHER_BACKOFF").Default("500ms").Duration()
+	httpPublisherElapsed   = app.Flag("http-publisher-max-elapsed", "Total time to spend sending each lifecycle event, which must be less than the webhook timeout as events are sent during admission requests").Envar("HTTP_PUBLISHER_MAX_ELAPSED").Default("8s").Duration()
+	httpPublisherCAFile    = app.Flag("http-publisher-ca-file", "PEM bundle used to verify the lifecycle event endpoint, in place of the system roots").Envar("HTTP_PUBLISHER_CA_FILE").String()
+	httpPublisherCertFile  = app.Flag("http-publisher-cert-file", "PEM client certificate to present to the lifecycle event endpoint").Envar("HTTP_PUBLISHER_CERT_FILE").String()
+	httpPublisherKeyFile   = app.Flag("http-publisher-key-file", "PEM key of the client certificate").Envar("HTTP_PUBLISHER_KEY_FILE").String()
+	httpPublisherInsecure  = app.Flag("http-publisher-insecure-skip-verify", "Don't verify the certificate of the lifecycle event endpoint").Envar("HTTP_PUBLISHER_INSECURE_SKIP_VERIFY").Default("false").Bool()
+	kafkaBrokers           = app.Flag("kafka-brokers", "Comma separated list of Kafka brokers to publish lifecycle event messages to, as an alternative to Pub/Sub").Envar("KAFKA_BROKERS").String()
+	kafkaTopic             = app.Flag("kafka-topic", "Kafka topic to publish lifecycle event messages to").Envar("KAFKA_TOPIC").String()
+	kafkaSASLMechanism     = app.Flag("kafka-sasl-mechanism", "SASL mechanism used to authenticate with Kafka. One of: plain|scram-sha-256|scram-sha-512").Envar("KAFKA_SASL_MECHANISM").Enum("", events.KafkaSASLPlain, events.KafkaSASLSCRAMSHA256, events.KafkaSASLSCRAMSHA512)
+	kafkaSASLUsername      = app.Flag("kafka-sasl-username", "Username used to authenticate with Kafka").Envar("KAFKA_SASL_USERNAME").String()
+	kafkaSASLPassword      = app.Flag("kafka-sasl-password", "Password used to authenticate with Kafka").Envar("KAFKA_SASL_PASSWORD").String()
+	kafkaTLS               = app.Flag("kafka-tls", "Connect to the Kafka brokers with TLS").Envar("KAFKA_TLS").Default("false").Bool()
+	kafkaCAFile            = app.Flag("kafka-ca-file", "PEM bundle used to verify the Kafka brokers, in place of the system roots").Envar("KAFKA_CA_FILE").String()
+	kafkaCertFile          = app.Flag("kafka-cert-file", "PEM client certificate to present to the Kafka brokers").Envar("KAFKA_CERT_FILE").String()
+	kafkaKeyFile           = app.Flag("kafka-key-file", "PEM key of the client certificate").Envar("KAFKA_KEY_FILE").String()
+	kafkaInsecure          = app.Flag("kafka-insecure-skip-verify", "Don't verify the certificates of the Kafka brokers").Envar("KAFKA_INSECURE_SKIP_VERIFY").Default("false").Bool()
+	kafkaTimeout           = app.Flag("kafka-timeout", "Timeout of each attempt to write a lifecycle event to Kafka").Envar("KAFKA_TIMEOUT").Default("10s").Duration()
+	kafkaMaxAttempts       = app.Flag("kafka-max-attempts", "Number of attempts to write a lifecycle event to Kafka").Envar("KAFKA_MAX_ATTEMPTS").Default("10").Int()
+	eventFormat            = app.Flag("event-format", "Format of published lifecycle event messages. One of: theatre|cloudevents-structured|cloudevents-binary").Envar("EVENT_FORMAT").Default(events.FormatTheatre).Enum(events.FormatTheatre, events.FormatCloudEventsStructured, events.FormatCloudEventsBinary)
+	eventOutbox            = app.Flag("event-outbox", "Write lifecycle events to ConfigMaps before publishing them, retrying those that fail to publish").Envar("EVENT_OUTBOX").Default("false").Bool()
+	eventOutboxNamespace   = app.Flag("event-outbox-namespace", "Namespace of the ConfigMaps holding lifecycle events waiting to be published").Envar("POD_NAMESPACE").String()
+	eventOutboxMaxAttempts = app.Flag("event-outbox-max-attempts", "Number of times an event may fail to publish from the outbox, while others succeed, before it is dead-lettered").Envar("EVENT_OUTBOX_MAX_ATTEMPTS").Default("10").Int()
+	eventValidation        = app.Flag("event-validation", "Validate lifecycle events against their JSON Schema before publishing them. One of: off|warn|reject").Envar("EVENT_VALIDATION").Default(events.ValidationOff).Enum(events.ValidationOff, events.ValidationWarn, events.ValidationReject)
+	pendingTemplateEdits   = app.Flag("pending-console-template-edits", "How to handle changes to a console template's authorisation rules while consoles created from it are pending authorisation. One of: allow|warn|reject").Envar("PENDING_CONSOLE_TEMPLATE_EDITS").Default(workloadsv1alpha1.PendingConsolesAllow).Enum(workloadsv1alpha1.PendingConsolesAllow, workloadsv1alpha1.PendingConsolesWarn, workloadsv1alpha1.PendingConsolesReject)
+	consoleHistory         = app.Flag("console-history", "Record the history of each console before it is deleted, as a ConsoleHistory").Envar("CONSOLE_HISTORY").Default("true").Bool()
+	historyRetention       = app.Flag("console-history-retention", "How long console history is kept for").Envar("CONSOLE_HISTORY_RETENTION").Default("720h").Duration()
 	enableSessionRecording = app.Flag("session-recording", "Enable session recording features").Envar("ENABLE_SESSION_RECORDING").Default("false").Bool()
 	sessionSidecarImage    = app.Flag("session-sidecar-image", "Container image to use for the session recording sidecar container").Envar("SESSION_SIDECAR_IMAGE").Default("").String()
 	sessionPubsubProjectId = app.Flag("session-pubsub-project-id", "ID for the project containing the Pub/Sub topic for session recording").Envar("SESSION_PUBSUB_PROJECT_ID").Default("").String()
 	sessionPubsubTopicId   = app.Flag("session-pubsub-topic-id", "ID of the topic to publish session recording data to").Envar("SESSION_PUBSUB_TOPIC_ID").Default("").String()
+	enforceConsoleAttach   = app.Flag("enforce-console-attach", "Deny attach and exec to console pods by users who have not been granted access to the console").Envar("ENFORCE_CONSOLE_ATTACH").Default("false").Bool()
+	breakGlassGroups       = app.Flag("console-attach-break-glass-group", "Kubernetes group whose members may always attach to console pods, when enforcing console attach").Envar("CONSOLE_ATTACH_BREAK_GLASS_GROUPS").Strings()
 
 	commonOpts = cmd.NewCommonOptions(app).WithMetrics(app)
 )
@@ -47,6 +85,12 @@ func init() {
 
 func main() {
 	kingpin.MustParse(app.Parse(os.Args[1:]))
+	if *managerUsername == "" {
+		app.Fatalf("Manager username must be set")
+	}
+	if *eventFormat != events.FormatTheatre && *contextName == "" {
+		app.Fatalf("Context name must be set to publish CloudEvents, as it is their source")
+	}
 	logger := commonOpts.Logger()
 
 	ctx, cancel := signals.SetupSignalHandler()
@@ -65,30 +109,119 @@ func main() {
 		}
 	}
 
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		MetricsBindAddress: fmt.Sprintf("%s:%d", commonOpts.MetricAddress, commonOpts.MetricPort),
+		Port:               443,
+		LeaderElection:     commonOpts.ManagerLeaderElection,
+		LeaderElectionID:   "workloads.crds.gocardless.com",
+		Scheme:             scheme,
+	})
+	if err != nil {
+		app.Fatalf("failed to create manager: %v", err)
+	}
+
 	// Create publisher sink for console lifecycle events
 	var publisher events.Publisher
-	var err error
+	publishers := 0
+	for _, configured := range []bool{len(*pubsubProjectId) > 0 && len(*pubsubTopicId) > 0, len(*httpPublisherURL) > 0, len(*kafkaBrokers) > 0} {
+		if configured {
+			publishers++
+		}
+	}
+	if publishers > 1 {
+		app.Fatalf("only one of Pub/Sub, HTTP or Kafka publishing can be configured")
+	}
 	if len(*pubsubProjectId) > 0 && len(*pubsubTopicId) > 0 {
 		publisher, err = events.NewGooglePubSubPublisher(ctx, *pubsubProjectId, *pubsubTopicId)
 		if err != nil {
 			app.Fatalf("failed to create publisher for %s/%s", *pubsubProjectId, *pubsubTopicId)
 		}
 		defer publisher.(*events.GooglePubSubPublisher).Stop()
+	} else if len(*httpPublisherURL) > 0 {
+		// The outbox retries events that fail to publish, without holding up
+		// the request that recorded them
+		maxRetries := *httpPublisherRetries
+		if *eventOutbox {
+			maxRetries = 0
+		}
+
+		publisher, err = events.NewHTTPPublisher(events.HTTPPublisherOptions{
+			URL:           *httpPublisherURL,
+			Headers:       *httpPublisherHeaders,
+			SigningSecret: *httpPublisherSecret,
+			Timeout:       *httpPublisherTimeout,
+			MaxRetries:    maxRetries,
+			Backoff:       *httpPublisherBackoff,
+			MaxBackoff:    time.Minute,
+			MaxElapsed:    *httpPublisherElapsed,
+			TLS: events.TLSOptions{
+				CAFile:             *httpPublisherCAFile,
+				CertFile:           *httpPublisherCertFile,
+				KeyFile:            *httpPublisherKeyFile,
+				InsecureSkipVerify: *httpPublisherInsecure,
+			},
+		})
+		if err != nil {
+			app.Fatalf("failed to create publisher for %s: %v", *httpPublisherURL, err)
+		}
+	} else if len(*kafkaBrokers) > 0 {
+		publisher, err = events.NewKafkaPublisher(events.KafkaPublisherOptions{
+			Brokers:       strings.Split(*kafkaBrokers, ","),
+			Topic:         *kafkaTopic,
+			SASLMechanism: *kafkaSASLMechanism,
+			SASLUsername:  *kafkaSASLUsername,
+			SASLPassword:  *kafkaSASLPassword,
+			TLS:           *kafkaTLS,
+			TLSOptions: events.TLSOptions{
+				CAFile:             *kafkaCAFile,
+				CertFile:           *kafkaCertFile,
+				KeyFile:            *kafkaKeyFile,
+				InsecureSkipVerify: *kafkaInsecure,
+			},
+			Timeout:     *kafkaTimeout,
+			MaxAttempts: *kafkaMaxAttempts,
+		})
+		if err != nil {
+			app.Fatalf("failed to create publisher for %s: %v", *kafkaTopic, err)
+		}
+		defer publisher.(*events.KafkaPublisher).Stop()
 	} else { // Default to a nop publisher
 		publisher = events.NewNopPublisher()
 	}
+	if *eventFormat != events.FormatTheatre {
+		publisher, err = events.NewCloudEventsPublisher(publisher, *contextName, *eventFormat)
+		if err != nil {
+			app.Fatalf("failed to create publisher: %v", err)
+		}
+	}
+	if *eventOutbox {
+		if len(*eventOutboxNamespace) == 0 {
+			app.Fatalf("Event outbox namespace must be set")
+		}
+		outbox := events.NewOutbox(mgr.GetClient(), mgr.GetAPIReader(), publisher, logger.WithName("outbox"), events.OutboxOptions{
+			Namespace:   *eventOutboxNamespace,
+			Interval:    10 * time.Second,
+			MaxInterval: 5 * time.Minute,
+			Grace:       time.Minute,
+			MaxAttempts: *eventOutboxMaxAttempts,
+		})
+		if err := mgr.Add(outbox); err != nil {
+			app.Fatalf("failed to add outbox to manager: %v", err)
+		}
+		publisher = outbox
+	}
+	if *eventValidation != events.ValidationOff {
+		publisher, err = events.NewValidatingPublisher(publisher, logger.WithName("event-validation"), *eventValidation)
+		if err != nil {
+			app.Fatalf("failed to create publisher: %v", err)
+		}
+	}
 	idBuilder := workloadsv1alpha1.NewConsoleIdBuilder(*contextName)
 	lifecycleRecorder := workloadsv1alpha1.NewLifecycleEventRecorder(*contextName, logger, publisher, idBuilder)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		MetricsBindAddress: fmt.Sprintf("%s:%d", commonOpts.MetricAddress, commonOpts.MetricPort),
-		Port:               443,
-		LeaderElection:     commonOpts.ManagerLeaderElection,
-		LeaderElectionID:   "workloads.crds.gocardless.com",
-		Scheme:             scheme,
-	})
-	if err != nil {
-		app.Fatalf("failed to create manager: %v", err)
+	var historySink consolecontroller.HistorySink
+	if *consoleHistory {
+		historySink = consolecontroller.NewConsoleHistorySink(mgr.GetClient())
 	}
 
 	// controller
@@ -102,6 +235,28 @@ func main() {
 		SessionSidecarImage:    *sessionSidecarImage,
 		SessionPubsubProjectId: *sessionPubsubProjectId,
 		SessionPubsubTopicId:   *sessionPubsubTopicId,
+		PodLogs:                kubernetes.NewForConfigOrDie(mgr.GetConfig()).CoreV1(),
+		APIReader:              mgr.GetAPIReader(),
+		History:                historySink,
+		HistoryRetention:       *historyRetention,
+		ManagerUsername:        *managerUsername,
+	}).SetupWithManager(ctx, mgr); err != nil {
+		app.Fatalf("failed to create controller: %v", err)
+	}
+
+	if err = (&consolehistorycontroller.ConsoleHistoryReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("consolehistory"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(ctx, mgr); err != nil {
+		app.Fatalf("failed to create controller: %v", err)
+	}
+
+	if err = (&consoleschedulecontroller.ConsoleScheduleReconciler{
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("consoleschedule"),
+		Scheme:          mgr.GetScheme(),
+		ManagerUsername: *managerUsername,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		app.Fatalf("failed to create controller: %v", err)
 	}
@@ -111,6 +266,7 @@ func main() {
 		Handler: workloadsv1alpha1.NewConsoleAuthenticatorWebhook(
 			lifecycleRecorder,
 			logger.WithName("webhooks").WithName("console-authenticator"),
+			*managerUsername,
 		),
 	})
 
@@ -126,7 +282,17 @@ func main() {
 	// console template webhook
 	mgr.GetWebhookServer().Register("/validate-consoletemplates", &admission.Webhook{
 		Handler: workloadsv1alpha1.NewConsoleTemplateValidationWebhook(
+			mgr.GetClient(),
+			lifecycleRecorder,
 			logger.WithName("webhooks").WithName("console-template"),
+			*pendingTemplateEdits,
+		),
+	})
+
+	// console schedule webhook
+	mgr.GetWebhookServer().Register("/mutate-consoleschedules", &admission.Webhook{
+		Handler: workloadsv1alpha1.NewConsoleScheduleWebhook(
+			logger.WithName("webhooks").WithName("console-schedule"),
 		),
 	})
 
@@ -138,6 +304,10 @@ func main() {
 			lifecycleRecorder,
 			logger.WithName("webhooks").WithName("console-attach-observer"),
 			10*time.Second,
+			workloadsv1alpha1.ConsoleAttachPolicy{
+				Enforce:          *enforceConsoleAttach,
+				BreakGlassGroups: *breakGlassGroups,
+			},
 		),
 	})
 
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// endAttachSessions closes the console's attach sessions that can no longer be
+// open: those attached to a container that has terminated, or all of them once
+// the console has stopped. Nothing that can be trusted reports when a user
+// detaches, so these are the latest each session could have ended.
+func endAttachSessions(csl *workloadsv1alpha1.Console, pod *corev1.Pod, now time.Time) []workloadsv1alpha1.ConsoleAttachSession {
+	if csl.PostRunning() {
+		endTime := now
+		if csl.Status.CompletionTime != nil {
+			endTime = csl.Status.CompletionTime.Time
+		}
+		return csl.EndAttachSessions("", endTime)
+	}
+
+	if pod == nil {
+		return nil
+	}
+
+	ended := []workloadsv1alpha1.ConsoleAttachSession{}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			ended = append(ended, csl.EndAttachSessions(status.Name, status.State.Terminated.FinishedAt.Time)...)
+		}
+	}
+
+	return ended
+}
+
+// mergeAttachSessions returns the stored attach sessions with the ends of any
+// that the controller has since closed. The attach webhook opens sessions, so
+// those stored may include some opened since the console was fetched.
+func mergeAttachSessions(stored, expected []workloadsv1alpha1.ConsoleAttachSession) []workloadsv1alpha1.ConsoleAttachSession {
+	if stored == nil {
+		return nil
+	}
+
+	merged := make([]workloadsv1alpha1.ConsoleAttachSession, len(stored))
+	for i, session := range stored {
+		merged[i] = *session.DeepCopy()
+		if session.EndTime != nil {
+			continue
+		}
+
+		for _, closed := range expected {
+			if closed.EndTime != nil && closed.Username == session.Username &&
+				closed.Container == session.Container && closed.StartTime.Equal(&session.StartTime) {
+				merged[i].EndTime = closed.EndTime.DeepCopy()
+				break
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("Attach sessions", func() {
+	attachedAt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	terminatedAt := attachedAt.Add(10 * time.Minute)
+	now := attachedAt.Add(time.Hour)
+
+	Describe("endAttachSessions", func() {
+		var (
+			csl   *workloadsv1alpha1.Console
+			pod   *corev1.Pod
+			ended []workloadsv1alpha1.ConsoleAttachSession
+		)
+
+		BeforeEach(func() {
+			csl = &workloadsv1alpha1.Console{
+				Status: workloadsv1alpha1.ConsoleStatus{Phase: workloadsv1alpha1.ConsoleRunning},
+			}
+			csl.StartAttachSession("alice@example.com", "console", attachedAt)
+			csl.StartAttachSession("bob@example.com", "debug", attachedAt)
+
+			pod = &corev1.Pod{
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{
+						{Name: "console", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+						{Name: "debug", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+					},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			ended = endAttachSessions(csl, pod, now)
+		})
+
+		Context("while the containers are running", func() {
+			It("leaves the sessions open", func() {
+				Expect(ended).To(BeEmpty())
+				Expect(csl.Status.AttachSessions[0].EndTime).To(BeNil())
+			})
+		})
+
+		Context("when a container has terminated", func() {
+			BeforeEach(func() {
+				pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(terminatedAt)},
+				}
+			})
+
+			It("ends the sessions attached to it when it terminated", func() {
+				Expect(ended).To(HaveLen(1))
+				Expect(ended[0].Username).To(Equal("alice@example.com"))
+				Expect(ended[0].EndTime.Time).To(Equal(terminatedAt))
+				Expect(csl.Status.AttachSessions[1].EndTime).To(BeNil())
+			})
+		})
+
+		Context("when the console has stopped", func() {
+			BeforeEach(func() {
+				completedAt := metav1.NewTime(terminatedAt)
+				csl.Status.Phase = workloadsv1alpha1.ConsoleStopped
+				csl.Status.CompletionTime = &completedAt
+			})
+
+			It("ends every session when it completed", func() {
+				Expect(ended).To(HaveLen(2))
+				Expect(ended[1].EndTime.Time).To(Equal(terminatedAt))
+			})
+		})
+
+		Context("when the console has been destroyed", func() {
+			BeforeEach(func() {
+				csl.Status.Phase = workloadsv1alpha1.ConsoleDestroyed
+				pod = nil
+			})
+
+			It("ends every session now", func() {
+				Expect(ended).To(HaveLen(2))
+				Expect(ended[0].EndTime.Time).To(Equal(now))
+			})
+		})
+	})
+
+	Describe("mergeAttachSessions", func() {
+		It("keeps sessions opened since the console was fetched, applying ends", func() {
+			endTime := metav1.NewTime(terminatedAt)
+			stored := []workloadsv1alpha1.ConsoleAttachSession{
+				{Username: "alice@example.com", Container: "console", StartTime: metav1.NewTime(attachedAt)},
+				{Username: "bob@example.com", Container: "console", StartTime: metav1.NewTime(terminatedAt)},
+			}
+			expected := []workloadsv1alpha1.ConsoleAttachSession{
+				{Username: "alice@example.com", Container: "console", StartTime: metav1.NewTime(attachedAt), EndTime: &endTime},
+			}
+
+			merged := mergeAttachSessions(stored, expected)
+			Expect(merged).To(HaveLen(2))
+			Expect(merged[0].EndTime).To(Equal(&endTime))
+			Expect(merged[1].EndTime).To(BeNil())
+			Expect(stored[0].EndTime).To(BeNil())
+		})
+	})
+})
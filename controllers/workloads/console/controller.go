@@ -2,29 +2,33 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
@@ -33,6 +37,7 @@ import (
 	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
 	"github.com/gocardless/theatre/v3/pkg/logging"
 	"github.com/gocardless/theatre/v3/pkg/recutil"
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
 )
 
 const (
@@ -64,16 +69,32 @@ const (
 	Role                 = "role"
 	DirectoryRoleBinding = "directoryrolebinding"
 
-	DefaultTTLBeforeRunning = 1 * time.Hour
-	DefaultTTLAfterFinished = 24 * time.Hour
-
 	// Console session recording
 	SessionRecVolMount    = "/var/log/session"
 	SessionRecVolName     = "session-data"
 	SidewrapShutdownDelay = 60
 	SidewrapGracePeriod   = 30
+
+	// TerminateEventRetryPeriod is how long the finalizer of a deleted console
+	// retries publishing its Terminate event before giving up on it, so that an
+	// unavailable publisher doesn't prevent consoles from being deleted
+	TerminateEventRetryPeriod = 5 * time.Minute
+)
+
+var (
+	terminateEventsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "theatre_console_terminate_events_dropped_total",
+			Help: "Count of Terminate events of deleted consoles given up on after failing to publish",
+		},
+	)
 )
 
+func init() {
+	// Register custom metrics with the global controller runtime prometheus registry
+	metrics.Registry.MustRegister(terminateEventsDropped)
+}
+
 type IgnoreCreatePredicate struct {
 	predicate.Funcs
 }
@@ -98,6 +119,19 @@ type ConsoleReconciler struct {
 	SessionPubsubProjectId string
 	// The Pub/Sub topic ID that the session recording data should be sent to
 	SessionPubsubTopicId string
+	// Used to retrieve the logs of non-interactive consoles, when their
+	// template enables output capture. Output is not captured if unset.
+	PodLogs corev1client.PodsGetter
+	// Reads objects that the manager doesn't cache, such as output secrets.
+	// Defaults to the client, which would start caching them.
+	APIReader client.Reader
+	// Records the history of each console before it is deleted, which is kept
+	// for HistoryRetention. History is not recorded if unset.
+	History          HistorySink
+	HistoryRetention time.Duration
+	// The username the manager authenticates as, which the consoles created by
+	// console schedules belong to
+	ManagerUsername string
 }
 
 func (r *ConsoleReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
@@ -126,11 +160,14 @@ func (r *ConsoleReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manag
 			},
 		).
 		Complete(
-			recutil.ResolveAndReconcile(
-				ctx, logger, mgr, &workloadsv1alpha1.Console{},
+			recutil.ResolveAndReconcileWithFinalizer(
+				ctx, logger, mgr, &workloadsv1alpha1.Console{}, workloadsv1alpha1.ConsoleTerminateFinalizer,
 				func(logger logr.Logger, request reconcile.Request, obj runtime.Object) (reconcile.Result, error) {
 					return r.Reconcile(logger, ctx, request, obj.(*workloadsv1alpha1.Console))
 				},
+				func(logger logr.Logger, request reconcile.Request, obj runtime.Object) error {
+					return r.Finalize(logger, ctx, request, obj.(*workloadsv1alpha1.Console))
+				},
 			),
 		)
 }
@@ -138,20 +175,33 @@ func (r *ConsoleReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manag
 func (r *ConsoleReconciler) createOrUpdateUserRbac(logger logr.Logger, ctx context.Context, tpl *workloadsv1alpha1.ConsoleTemplate, req ctrl.Request, csl *workloadsv1alpha1.Console, authorisation *workloadsv1alpha1.ConsoleAuthorisation) error {
 
 	// Create or update the user role
-	role := buildUserRole(req.NamespacedName, csl.Status.PodName)
+	// Allow users to retrieve the console's output once its pod has gone, if
+	// it will be captured
+	outputSecretName := ""
+	if tpl.Spec.OutputCapture != nil && csl.Spec.Noninteractive {
+		outputSecretName = getOutputSecretName(csl.Name)
+	}
+
+	role := buildUserRole(req.NamespacedName, csl.Status.PodName, outputSecretName)
 	if err := r.createOrUpdate(ctx, logger, csl, role, Role, recutil.RoleDiff); err != nil {
 		return err
 	}
 
 	// Create or update the directory role binding
 	subjects := append(
-		tpl.Spec.AdditionalAttachSubjects,
+		append([]rbacv1.Subject{}, tpl.Spec.AdditionalAttachSubjects...),
 		rbacv1.Subject{Kind: "User", Name: csl.Spec.User},
 	)
 	// Append all the authorising users to allow them to attach
 	if authorisation != nil {
 		subjects = append(subjects, authorisation.Spec.Authorisations...)
 	}
+	// Append the users that the owner has shared the console with. We only get
+	// here once the console is running, so any authorisation required by the
+	// matching rule has already been given.
+	for _, collaborator := range csl.Spec.SharedWith {
+		subjects = append(subjects, rbacv1.Subject{Kind: "User", Name: collaborator})
+	}
 
 	drb := buildUserDirectoryRoleBinding(req.NamespacedName, role, subjects)
 	if err := r.createOrUpdate(ctx, logger, csl, drb, DirectoryRoleBinding, recutil.DirectoryRoleBindingDiff); err != nil {
@@ -194,8 +244,10 @@ func (r *ConsoleReconciler) createOrUpdateServiceRbac(logger logr.Logger, ctx co
 func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, req ctrl.Request, csl *workloadsv1alpha1.Console) (ctrl.Result, error) {
 	logger = logger.WithValues("console", req.NamespacedName)
 
-	// If we have yet to set the owner reference then this is a new console request
-	isNewConsole := len(csl.OwnerReferences) == 0
+	// If we have yet to set the controller reference then this is a new console
+	// request. Consoles created by a schedule are already owned by it, but not
+	// as their controller.
+	isNewConsole := metav1.GetControllerOf(csl) == nil
 
 	// Fetch console template
 	tpl, err := r.getConsoleTemplate(ctx, csl, req.NamespacedName)
@@ -210,8 +262,16 @@ func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, r
 		return ctrl.Result{}, errors.Wrap(err, "failed to set controller reference on console object")
 	}
 
-	csl = setConsoleTTLs(csl, tpl)
-	csl = r.setConsoleTimeout(logger, csl, tpl)
+	csl = csl.WithTemplateTTLs(tpl)
+	csl, reduced := csl.WithTemplateTimeout(tpl)
+	if reduced {
+		msg := fmt.Sprintf("Specified timeout exceeded the template maximum; reduced to %ds", tpl.Spec.MaxTimeoutSeconds)
+		logger.Info(
+			msg,
+			"event", EventInvalidSpecification,
+			"error", msg,
+		)
+	}
 
 	// We call this function here to ensure that we perform an update on the
 	// console object *if* one is needed; i.e. it defends against not correctly
@@ -239,8 +299,9 @@ func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, r
 
 	// Create an authorisation object, if required.
 	var (
-		authRule      *workloadsv1alpha1.ConsoleAuthorisationRule
-		authorisation *workloadsv1alpha1.ConsoleAuthorisation
+		authRule              *workloadsv1alpha1.ConsoleAuthorisationRule
+		authorisation         *workloadsv1alpha1.ConsoleAuthorisation
+		initialAuthorisations []rbacv1.Subject
 	)
 
 	if tpl.HasAuthorisationRules() {
@@ -250,7 +311,21 @@ func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, r
 		}
 
 		authRule = &rule
-		if err := r.createAuthorisationObjects(ctx, logger, csl, req.NamespacedName, authRule.Subjects); err != nil {
+
+		// Consoles created by an authorised schedule start with the schedule's
+		// authorisations, and re-runs may start with those of the original
+		initialAuthorisations, err = r.getScheduleAuthorisations(ctx, csl, authRule)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to retrieve console schedule")
+		}
+		if initialAuthorisations == nil {
+			initialAuthorisations, err = r.getRerunAuthorisations(ctx, csl, authRule)
+			if err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "failed to retrieve re-run console")
+			}
+		}
+
+		if err := r.createAuthorisationObjects(ctx, logger, csl, req.NamespacedName, authRule.Subjects, initialAuthorisations); err != nil {
 			return ctrl.Result{}, err
 		}
 
@@ -261,10 +336,26 @@ func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, r
 	}
 
 	if isNewConsole {
+		// Record the template the console was requested with, which is replaced
+		// by that of its job once created. The job is built again later, so we
+		// don't want it to log twice.
+		csl.Status.TemplateGeneration, csl.Status.TemplateHash = jobTemplateVersion(
+			r.buildJob(logr.Discard(), csl, tpl),
+		)
+
 		err := r.LifecycleRecorder.ConsoleRequest(ctx, csl, authRule)
 		if err != nil {
 			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.request")
 		}
+
+		// Record any authorisations the console starts with, so that it has the
+		// same event trail as a console authorised directly
+		for _, subject := range initialAuthorisations {
+			err := r.LifecycleRecorder.ConsoleAuthorise(ctx, csl, subject.Name)
+			if err != nil {
+				logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.authorise")
+			}
+		}
 	}
 
 	var (
@@ -284,7 +375,7 @@ func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, r
 	// to this controller) then don't recreate it.
 	authorised := isConsoleAuthorised(authRule, authorisation)
 	if (authorised && csl.PendingJob()) || job != nil {
-		job = r.buildJob(logger, req.NamespacedName, csl, tpl)
+		job = r.buildJob(logger, csl, tpl)
 		if err := r.createOrUpdate(ctx, logger, csl, job, Job, jobDiff); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -345,6 +436,18 @@ func (r *ConsoleReconciler) Reconcile(logger logr.Logger, ctx context.Context, r
 		return ctrl.Result{}, errors.Wrap(err, "failed to generate console status or audit events")
 	}
 
+	// Capture the output of non-interactive consoles once they finish, while
+	// the pod is still around. A failure shouldn't prevent the console from
+	// progressing, so we log it and try again when next reconciled.
+	if r.shouldCaptureOutput(csl, tpl, pod) {
+		capturedOutput, err := r.captureOutput(ctx, logger, csl, tpl, pod)
+		if err != nil {
+			logger.Error(err, "failed to capture console output")
+		} else {
+			csl.Status.CapturedOutput = capturedOutput
+		}
+	}
+
 	if err := r.createOrUpdate(ctx, logger, csl, csl, Console, consoleDiff); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -419,9 +522,60 @@ func (r *ConsoleReconciler) getConsoleAuthorisation(ctx context.Context, name ty
 	return auth, r.Get(ctx, name, auth)
 }
 
+// getScheduleAuthorisations returns the authorisations of the console schedule
+// that created the console, if it has one and its authorisations apply to
+// this console
+func (r *ConsoleReconciler) getScheduleAuthorisations(ctx context.Context, csl *workloadsv1alpha1.Console, rule *workloadsv1alpha1.ConsoleAuthorisationRule) ([]rbacv1.Subject, error) {
+	scheduleName, ok := csl.Labels[workloadsv1alpha1.ConsoleScheduleLabel]
+	if !ok {
+		return nil, nil
+	}
+
+	schedule := &workloadsv1alpha1.ConsoleSchedule{}
+	if err := r.Get(ctx, types.NamespacedName{Name: scheduleName, Namespace: csl.Namespace}, schedule); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !schedule.AuthorisesConsole(csl, rule.AuthorisationsRequired, r.ManagerUsername) {
+		return nil, nil
+	}
+
+	return schedule.Spec.Authorisations, nil
+}
+
+// getRerunAuthorisations returns the authorisations of the console that this
+// console re-runs, if the authorisation rule allows them to be reused
+func (r *ConsoleReconciler) getRerunAuthorisations(ctx context.Context, csl *workloadsv1alpha1.Console, rule *workloadsv1alpha1.ConsoleAuthorisationRule) ([]rbacv1.Subject, error) {
+	originalName, ok := csl.Labels[workloadsv1alpha1.ConsoleRerunOfLabel]
+	if !ok || rule.RerunAuthorisationWindowSeconds == 0 {
+		return nil, nil
+	}
+
+	original := &workloadsv1alpha1.Console{}
+	originalAuth := &workloadsv1alpha1.ConsoleAuthorisation{}
+	name := types.NamespacedName{Name: originalName, Namespace: csl.Namespace}
+	for _, obj := range []client.Object{original, originalAuth} {
+		if err := r.Get(ctx, name, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	if !csl.CanReuseAuthorisation(original, *rule) || !isConsoleAuthorised(rule, originalAuth) {
+		return nil, nil
+	}
+
+	return originalAuth.Spec.Authorisations, nil
+}
+
 func (r *ConsoleReconciler) getJob(ctx context.Context, name types.NamespacedName) (*batchv1.Job, error) {
 	jobName := types.NamespacedName{
-		Name:      getJobName(name.Name),
+		Name:      workloadsv1alpha1.ConsoleJobName(name.Name),
 		Namespace: name.Namespace,
 	}
 
@@ -438,31 +592,6 @@ func setConsoleOwner(console *workloadsv1alpha1.Console, consoleTemplate *worklo
 	return updatedCsl, nil
 }
 
-func setConsoleTTLs(console *workloadsv1alpha1.Console, consoleTemplate *workloadsv1alpha1.ConsoleTemplate) *workloadsv1alpha1.Console {
-	defaultTTLSecondsBeforeRunning := int32(DefaultTTLBeforeRunning.Seconds())
-	defaultTTLSecondsAfterFinished := int32(DefaultTTLAfterFinished.Seconds())
-
-	updatedCsl := console.DeepCopy()
-
-	if console.Spec.TTLSecondsBeforeRunning != nil {
-		updatedCsl.Spec.TTLSecondsBeforeRunning = console.Spec.TTLSecondsBeforeRunning
-	} else if consoleTemplate.Spec.DefaultTTLSecondsBeforeRunning != nil {
-		updatedCsl.Spec.TTLSecondsBeforeRunning = consoleTemplate.Spec.DefaultTTLSecondsBeforeRunning
-	} else {
-		updatedCsl.Spec.TTLSecondsBeforeRunning = &defaultTTLSecondsBeforeRunning
-	}
-
-	if console.Spec.TTLSecondsAfterFinished != nil {
-		updatedCsl.Spec.TTLSecondsAfterFinished = console.Spec.TTLSecondsAfterFinished
-	} else if consoleTemplate.Spec.DefaultTTLSecondsAfterFinished != nil {
-		updatedCsl.Spec.TTLSecondsAfterFinished = consoleTemplate.Spec.DefaultTTLSecondsAfterFinished
-	} else {
-		updatedCsl.Spec.TTLSecondsAfterFinished = &defaultTTLSecondsAfterFinished
-	}
-
-	return updatedCsl
-}
-
 func (r *ConsoleReconciler) createOrUpdate(ctx context.Context, logger logr.Logger, csl *workloadsv1alpha1.Console, expected recutil.ObjWithMeta, kind string, diffFunc recutil.DiffFunc) error {
 	// If operating on the console itself, don't attempt to set the controller
 	// reference, as this isn't valid.
@@ -505,32 +634,6 @@ func (r *ConsoleReconciler) createOrUpdate(ctx context.Context, logger logr.Logg
 	return nil
 }
 
-// Ensure the console timeout is between [0, template.MaxTimeoutSeconds]
-func (r *ConsoleReconciler) setConsoleTimeout(logger logr.Logger, console *workloadsv1alpha1.Console, template *workloadsv1alpha1.ConsoleTemplate) *workloadsv1alpha1.Console {
-	var timeout int
-	max := template.Spec.MaxTimeoutSeconds
-
-	switch {
-	case console.Spec.TimeoutSeconds < 1:
-		timeout = template.Spec.DefaultTimeoutSeconds
-	case console.Spec.TimeoutSeconds > max:
-		msg := fmt.Sprintf("Specified timeout exceeded the template maximum; reduced to %ds", max)
-		logger.Info(
-			msg,
-			"event", EventInvalidSpecification,
-			"error", msg,
-		)
-		timeout = max
-	default:
-		timeout = console.Spec.TimeoutSeconds
-	}
-
-	updatedCsl := console.DeepCopy()
-	updatedCsl.Spec.TimeoutSeconds = timeout
-
-	return updatedCsl
-}
-
 func isConsoleAuthorised(rule *workloadsv1alpha1.ConsoleAuthorisationRule, auth *workloadsv1alpha1.ConsoleAuthorisation) bool {
 	if rule == nil {
 		return true
@@ -576,15 +679,24 @@ func (r *ConsoleReconciler) generateStatusAndAuditEvents(ctx context.Context, lo
 		logger.Info("Console started", "event", ConsoleStarted)
 	}
 
+	// recordTerminate publishes the console's Terminate event, noting in its
+	// status that it has been published so that the finalizer doesn't publish
+	// another when the console is deleted
+	recordTerminate := func(reason events.TerminateReason, timedOut bool) {
+		if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, reason, timedOut, statusCtx.Pod); err != nil {
+			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.terminate")
+			return
+		}
+		newStatus.TerminateRecorded = true
+	}
+
 	// Console phase from Running to Stopped, with a CompletionTime: the job
 	// completed successfully
 	if csl.Running() && newStatus.Phase == workloadsv1alpha1.ConsoleStopped &&
 		newStatus.CompletionTime != nil {
 		duration := statusCtx.Job.Status.CompletionTime.Sub(statusCtx.Job.Status.StartTime.Time).Seconds()
 		logger.Info("Console ended", "event", ConsoleEnded, "duration", duration)
-		if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, false, statusCtx.Pod); err != nil {
-			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.terminate")
-		}
+		recordTerminate(events.TerminateCompleted, false)
 	}
 
 	// Console phase from Running to Stopped without CompletionTime.
@@ -596,9 +708,7 @@ func (r *ConsoleReconciler) generateStatusAndAuditEvents(ctx context.Context, lo
 		newStatus.CompletionTime == nil {
 		duration := csl.Status.ExpiryTime.Sub(statusCtx.Job.Status.StartTime.Time).Seconds()
 		logger.Info("Console ended due to expiration", "event", ConsoleEnded, "duration", duration)
-		if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, true, statusCtx.Pod); err != nil {
-			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.terminate")
-		}
+		recordTerminate(events.TerminateFailed, true)
 	}
 
 	// Console phase transitioned to Stopped, but wasn't Running or Stopped beforehand.
@@ -606,27 +716,39 @@ func (r *ConsoleReconciler) generateStatusAndAuditEvents(ctx context.Context, lo
 	// more than one phase in between reconciliation loops.
 	if !csl.Running() && !csl.Stopped() && newStatus.Phase == workloadsv1alpha1.ConsoleStopped {
 		logger.Info("Console ended: duration unknown", "event", ConsoleEnded)
-		if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, false, statusCtx.Pod); err != nil {
-			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.terminate")
-		}
+		recordTerminate(events.TerminateStopped, false)
 	}
 
 	// Console was in PendingAuthorisation phase, but is about to be deleted.
 	if csl.PendingAuthorisation() && csl.EligibleForGC() {
 		logger.Info("Console expired due to lack of authorisation", "event", ConsoleEnded)
-		if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, true, statusCtx.Pod); err != nil {
-			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.terminate")
-		}
+		recordTerminate(events.TerminateExpired, true)
 	}
 
 	// Console phase has changed to destroyed (i.e. the job has been removed)
 	if !csl.Destroyed() && newStatus.Phase == workloadsv1alpha1.ConsoleDestroyed {
 		logger.Info("Console destroyed", "event", ConsoleDestroyed)
+
+		// The job was removed from under a console that hadn't stopped, so it
+		// will never be observed ending
+		if (csl.Pending() || csl.Running()) && !newStatus.TerminateRecorded {
+			recordTerminate(events.TerminateDeleted, false)
+		}
 	}
 
 	updatedCsl := csl.DeepCopy()
 	updatedCsl.Status = newStatus
 
+	closedReason := events.AttachClosedContainerTerminated
+	if updatedCsl.PostRunning() {
+		closedReason = events.AttachClosedConsoleStopped
+	}
+	for _, session := range endAttachSessions(updatedCsl, statusCtx.Pod, time.Now()) {
+		if err := r.LifecycleRecorder.ConsoleAttachClosed(ctx, updatedCsl, session, closedReason); err != nil {
+			logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.attach_closed")
+		}
+	}
+
 	return updatedCsl, nil
 }
 
@@ -650,6 +772,10 @@ func calculateStatus(csl *workloadsv1alpha1.Console, statusCtx consoleStatusCont
 	if statusCtx.Pod != nil {
 		newStatus.PodName = statusCtx.Pod.ObjectMeta.Name
 	}
+	// Jobs created before the template was recorded don't say what they ran
+	if generation, hash := jobTemplateVersion(statusCtx.Job); hash != "" {
+		newStatus.TemplateGeneration, newStatus.TemplateHash = generation, hash
+	}
 
 	newStatus.Phase = calculatePhase(statusCtx)
 
@@ -675,19 +801,132 @@ func (r *ConsoleReconciler) abort(ctx context.Context, logger logr.Logger, csl *
 		return errors.Wrap(podDeleteError, "failed to delete pod(s)")
 	}
 
-	// Update console status
-	newStatus := csl.DeepCopy().Status
-	newStatus.Phase = workloadsv1alpha1.ConsoleStopped
+	// Stop the console before publishing its Terminate event, so that failing
+	// to update its status, which is retried, doesn't publish the event again
 	updatedCsl := csl.DeepCopy()
-	updatedCsl.Status = newStatus
-
-	if err := r.createOrUpdate(ctx, logger, csl, csl, Console, consoleDiff); err != nil {
+	updatedCsl.Status.Phase = workloadsv1alpha1.ConsoleStopped
+	if err := r.createOrUpdate(ctx, logger, csl, updatedCsl, Console, consoleDiff); err != nil {
 		return err
 	}
 
-	// Publish termination event
-	if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, false, nil); err != nil {
+	if err := r.LifecycleRecorder.ConsoleTerminate(ctx, updatedCsl, events.TerminateAborted, false, nil); err != nil {
 		logging.WithNoRecord(logger).Error(err, "failed to record event", "event", "console.terminate")
+		return nil
+	}
+
+	// The event has been published, so isn't retried if this fails, at worst
+	// leaving the finalizer to publish another when the console is deleted
+	recordedCsl := updatedCsl.DeepCopy()
+	recordedCsl.Status.TerminateRecorded = true
+	if err := r.createOrUpdate(ctx, logger, csl, recordedCsl, Console, consoleDiff); err != nil {
+		logging.WithNoRecord(logger).Error(err, "failed to note that the terminate event was recorded")
+	}
+
+	return nil
+}
+
+// Finalize is called when a console is deleted. It removes the RBAC objects
+// granting access to the console, confirming that they have gone, attempts to
+// record its history, then publishes the console's Terminate event if one hasn't been
+// already: consoles can be deleted before they are observed to end, and
+// deleting one removes its job with it. Publishing the event is retried for
+// TerminateEventRetryPeriod after the console was deleted, after which it is
+// given up on so the console can go.
+// completedWithoutTerminateRecorded returns whether the console's job completed
+// without TerminateRecorded being set. Consoles that stopped before the status
+// recorded Terminate events still had one published as they stopped, so must
+// not have another published when deleted. The same applies after failing to
+// publish the event as the console stopped, which is logged at the time.
+func completedWithoutTerminateRecorded(csl *workloadsv1alpha1.Console) bool {
+	return !csl.Status.TerminateRecorded && csl.PostRunning() && csl.Status.CompletionTime != nil
+}
+
+func (r *ConsoleReconciler) Finalize(logger logr.Logger, ctx context.Context, req ctrl.Request, csl *workloadsv1alpha1.Console) error {
+	logger = logger.WithValues("console", req.NamespacedName)
+
+	if err := r.deleteRbac(ctx, logger, req.NamespacedName); err != nil {
+		return err
+	}
+
+	// The pod may already have gone, in which case the history and event are
+	// recorded without it
+	var pod *corev1.Pod
+	if csl.Status.PodName != "" {
+		existing := &corev1.Pod{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: csl.Namespace, Name: csl.Status.PodName}, existing); err == nil {
+			pod = existing
+		}
+	}
+
+	// History is best effort, so never holds up the console's deletion. It is
+	// recorded in the console's namespace, so is skipped once that is being
+	// deleted, as it couldn't be created and would be removed with it anyway.
+	if r.History != nil {
+		terminating, err := r.namespaceTerminating(ctx, csl.Namespace)
+		switch {
+		case err != nil:
+			logging.WithNoRecord(logger).Error(err, "failed to record console history")
+		case terminating:
+			logger.Info("Skipped console history, as the namespace is being deleted", "kind", ConsoleHistory)
+		default:
+			if err := r.recordHistory(ctx, csl, pod); err != nil {
+				logging.WithNoRecord(logger).Error(err, "failed to record console history")
+			} else {
+				logger.Info("Recorded console history", "event", EventSuccessfulCreate, "kind", ConsoleHistory)
+			}
+		}
+	}
+
+	if csl.Status.TerminateRecorded || completedWithoutTerminateRecorded(csl) {
+		return nil
+	}
+
+	logger.Info("Console deleted", "event", ConsoleDestroyed)
+	if err := r.LifecycleRecorder.ConsoleTerminate(ctx, csl, events.TerminateDeleted, false, pod); err != nil {
+		if csl.DeletionTimestamp == nil || time.Since(csl.DeletionTimestamp.Time) < TerminateEventRetryPeriod {
+			return errors.Wrap(err, "failed to record terminate event")
+		}
+
+		terminateEventsDropped.Inc()
+		logging.WithNoRecord(logger).Error(err, "giving up on recording terminate event", "event", "console.terminate")
+	}
+
+	return nil
+}
+
+// deleteRbac deletes the roles and directory rolebindings created for the
+// console, returning an error until they no longer exist. They are owned by
+// the console, but garbage collection only removes them after the console has
+// gone, and access to it should be revoked first.
+func (r *ConsoleReconciler) deleteRbac(ctx context.Context, logger logr.Logger, name types.NamespacedName) error {
+	remaining := []string{}
+	for _, rbacName := range []string{name.Name, name.Name + "-svc", name.Name + "-authorisation"} {
+		key := types.NamespacedName{Namespace: name.Namespace, Name: rbacName}
+
+		for kind, obj := range map[string]client.Object{
+			Role:                 &rbacv1.Role{},
+			DirectoryRoleBinding: &rbacv1alpha1.DirectoryRoleBinding{},
+		} {
+			if err := r.Get(ctx, key, obj); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return errors.Wrapf(err, "failed to get %s", kind)
+			}
+
+			if obj.GetDeletionTimestamp().IsZero() {
+				if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+					return errors.Wrapf(err, "failed to delete %s", kind)
+				}
+				logger.Info(fmt.Sprintf("Deleted %s: %s", kind, rbacName), "event", EventDelete)
+			}
+
+			remaining = append(remaining, fmt.Sprintf("%s: %s", kind, rbacName))
+		}
+	}
+
+	if len(remaining) > 0 {
+		return fmt.Errorf("waiting for deletion of %s", strings.Join(remaining, ", "))
 	}
 
 	return nil
@@ -862,34 +1101,10 @@ func (r *ConsoleReconciler) addSessionRecordingToPodTemplate(logger logr.Logger,
 	return mutatedTemplate
 }
 
-func (r *ConsoleReconciler) buildJob(logger logr.Logger, name types.NamespacedName, csl *workloadsv1alpha1.Console, template *workloadsv1alpha1.ConsoleTemplate) *batchv1.Job {
-	timeout := int64(csl.Spec.TimeoutSeconds)
+func (r *ConsoleReconciler) buildJob(logger logr.Logger, csl *workloadsv1alpha1.Console, template *workloadsv1alpha1.ConsoleTemplate) *batchv1.Job {
+	job := csl.BuildJob(template)
 
-	username := strings.SplitN(csl.Spec.User, "@", 2)[0]
-	jobTemplate := template.Spec.Template.DeepCopy()
-
-	numContainers := len(jobTemplate.Spec.Containers)
-
-	// If there are no containers in the spec then the controller will be emitting
-	// warnings anyway, as the job will be rejected
-	if numContainers > 0 {
-		container := &jobTemplate.Spec.Containers[0]
-
-		// Only replace the template command if one is specified
-		if len(csl.Spec.Command) > 0 {
-			container.Command = csl.Spec.Command[:1]
-			container.Args = csl.Spec.Command[1:]
-		}
-
-		if !csl.Spec.Noninteractive {
-			// Set these properties to ensure that it's possible to send input to the
-			// container when attaching
-			container.Stdin = true
-			container.TTY = true
-		}
-	}
-
-	if numContainers > 1 {
+	if len(template.Spec.Template.Spec.Containers) > 1 {
 		msg := "A console template can only contain a single container"
 		logger.Info(
 			msg,
@@ -898,58 +1113,42 @@ func (r *ConsoleReconciler) buildJob(logger logr.Logger, name types.NamespacedNa
 		)
 	}
 
-	// Job API SetDefaults_Job
-	// https://github.com/kubernetes/kubernetes/blob/master/pkg/apis/batch/v1/defaults.go#L28
-	completions := int32(1)
-	parallelism := int32(1)
-
-	// Do not retry console jobs if they fail. There is no guarantee that the
-	// command that the user submits will be idempotent.
-	// This also prevents multiple pods from being spawned by a job, which is
-	// important as other parts of the controller assume there will only ever be
-	// 1 pod per job.
-	backoffLimit := int32(0)
-	jobTemplate.Spec.RestartPolicy = corev1.RestartPolicyNever
-
-	jobName := getJobName(name.Name)
-
-	// Merged labels from the console template and console. In case of
-	// conflicts second label set wins.
-	// The labels on the console can be user-defined, so we do not want to allow a
-	// user to create a console with a label that implies that it's for an application
-	// different to the console.
-	jobLabels := labels.Merge(csl.Labels, template.Labels)
-	jobLabels = labels.Merge(jobLabels,
-		map[string]string{
-			"console-name": sanitiseLabel(csl.Name),
-			"user":         sanitiseLabel(username),
-		})
-
-	jobTemplate.ObjectMeta.Labels = labels.Merge(
-		jobLabels,
-		jobTemplate.ObjectMeta.Labels,
-	)
-
-	podTemplate := (*corev1.PodTemplateSpec)(jobTemplate)
 	if r.EnableSessionRecording {
 		consoleId := r.ConsoleIdBuilder.BuildId(csl)
-		podTemplate = r.addSessionRecordingToPodTemplate(logger, podTemplate, consoleId)
+		job.Spec.Template = *r.addSessionRecordingToPodTemplate(logger, &job.Spec.Template, consoleId)
 	}
 
-	return &batchv1.Job{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      jobName,
-			Namespace: name.Namespace,
-			Labels:    jobLabels,
-		},
-		Spec: batchv1.JobSpec{
-			Template:              *podTemplate,
-			Completions:           &completions,
-			Parallelism:           &parallelism,
-			ActiveDeadlineSeconds: &timeout,
-			BackoffLimit:          &backoffLimit,
-		},
+	// The annotations are only set when the job is created, as jobDiff doesn't
+	// update them, so keep recording what the job actually runs
+	job.ObjectMeta.Annotations = map[string]string{
+		workloadsv1alpha1.ConsoleTemplateGenerationAnnotation: strconv.FormatInt(template.Generation, 10),
+		workloadsv1alpha1.ConsoleTemplateHashAnnotation:       podSpecHash(&job.Spec.Template.Spec),
 	}
+
+	return job
+}
+
+// podSpecHash returns the SHA-256 hash of the pod spec, marshalled to JSON
+func podSpecHash(spec *corev1.PodSpec) string {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		// A pod spec can always be marshalled
+		panic(err)
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+}
+
+// jobTemplateVersion returns the template generation and pod spec hash that
+// buildJob recorded on the job, or zero values if it has none
+func jobTemplateVersion(job *batchv1.Job) (int64, string) {
+	if job == nil {
+		return 0, ""
+	}
+
+	generation, _ := strconv.ParseInt(job.Annotations[workloadsv1alpha1.ConsoleTemplateGenerationAnnotation], 10, 64)
+
+	return generation, job.Annotations[workloadsv1alpha1.ConsoleTemplateHashAnnotation]
 }
 
 func buildServiceRole(name types.NamespacedName, podName string) *rbacv1.Role {
@@ -997,8 +1196,8 @@ func buildServiceRoleBinding(name types.NamespacedName, role *rbacv1.Role, servi
 	}
 }
 
-func buildUserRole(name types.NamespacedName, podName string) *rbacv1.Role {
-	return &rbacv1.Role{
+func buildUserRole(name types.NamespacedName, podName, outputSecretName string) *rbacv1.Role {
+	role := &rbacv1.Role{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name.Name,
 			Namespace: name.Namespace,
@@ -1024,6 +1223,17 @@ func buildUserRole(name types.NamespacedName, podName string) *rbacv1.Role {
 			},
 		},
 	}
+
+	if outputSecretName != "" {
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			Verbs:         []string{"get"},
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{outputSecretName},
+		})
+	}
+
+	return role
 }
 
 func buildUserDirectoryRoleBinding(name types.NamespacedName, role *rbacv1.Role, subjects []rbacv1.Subject) *rbacv1alpha1.DirectoryRoleBinding {
@@ -1043,7 +1253,12 @@ func buildUserDirectoryRoleBinding(name types.NamespacedName, role *rbacv1.Role,
 	}
 }
 
-func (r *ConsoleReconciler) createAuthorisationObjects(ctx context.Context, logger logr.Logger, csl *workloadsv1alpha1.Console, name types.NamespacedName, subjects []rbacv1.Subject) error {
+func (r *ConsoleReconciler) createAuthorisationObjects(ctx context.Context, logger logr.Logger, csl *workloadsv1alpha1.Console, name types.NamespacedName, subjects []rbacv1.Subject, authorisations []rbacv1.Subject) error {
+	if authorisations == nil {
+		authorisations = []rbacv1.Subject{}
+	}
+
+	// The authorisations are only set when the object is first created
 	authorisation := &workloadsv1alpha1.ConsoleAuthorisation{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name.Name,
@@ -1052,7 +1267,7 @@ func (r *ConsoleReconciler) createAuthorisationObjects(ctx context.Context, logg
 		},
 		Spec: workloadsv1alpha1.ConsoleAuthorisationSpec{
 			ConsoleRef:     corev1.LocalObjectReference{Name: name.Name},
-			Authorisations: []rbacv1.Subject{},
+			Authorisations: authorisations,
 		},
 	}
 
@@ -1140,8 +1355,15 @@ func consoleDiff(expectedObj runtime.Object, existingObj runtime.Object) recutil
 		operation = recutil.Update
 	}
 
-	if !reflect.DeepEqual(expected.Status, existing.Status) {
-		existing.Status = expected.Status
+	// Attach sessions are opened by the attach webhook rather than this
+	// controller, so preserve whatever is currently stored to avoid
+	// overwriting a session opened since the console was fetched, only
+	// applying the ends of sessions the controller has closed.
+	expectedStatus := expected.Status.DeepCopy()
+	expectedStatus.AttachSessions = mergeAttachSessions(existing.Status.AttachSessions, expected.Status.AttachSessions)
+
+	if !reflect.DeepEqual(*expectedStatus, existing.Status) {
+		existing.Status = *expectedStatus
 		operation = recutil.Update
 	}
 
@@ -1215,6 +1437,11 @@ func getAuditLogger(logger logr.Logger, consoleId string, c *workloadsv1alpha1.C
 		"reason", c.Spec.Reason,
 	)
 
+	if len(c.Spec.SharedWith) > 0 {
+		sharedWith, _ := json.Marshal(c.Spec.SharedWith)
+		loggerCtx = loggerCtx.WithValues("console_shared_with", string(sharedWith))
+	}
+
 	if statusCtx.Pod != nil {
 		loggerCtx = loggerCtx.WithValues("console_pod_name", statusCtx.Pod.Name)
 	}
@@ -1238,26 +1465,3 @@ func getAuditLogger(logger logr.Logger, consoleId string, c *workloadsv1alpha1.C
 
 	return loggerCtx
 }
-
-// Ensure that the job name (after suffixing with `-console`) does not exceed 63
-// characters. This is the string length limit on labels and the job name is added
-// as a label to the pods it creates.
-func getJobName(consoleName string) string {
-	return fmt.Sprintf("%s-%s", truncateString(consoleName, 55), "console")
-}
-
-// Kubernetes labels must satisfy (([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])? and not
-// exceed 63 characters in length.
-// We don't bother with the first and last character sanitisation here - just anything
-// dodgy in the middle.
-// This is mostly so that, in tests, we correctly handle the system:unsecured user.
-func sanitiseLabel(l string) string {
-	return truncateString(regexp.MustCompile(`[^A-z0-9\-_.]`).ReplaceAllString(l, "-"), 63)
-}
-
-func truncateString(str string, length int) string {
-	if len(str) > length {
-		return str[0:length]
-	}
-	return str
-}
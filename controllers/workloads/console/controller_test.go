@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	rbacv1alpha1 "github.com/gocardless/theatre/v3/apis/rbac/v1alpha1"
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+// terminateRecorder counts the Terminate events it records, failing while err
+// is set
+type terminateRecorder struct {
+	workloadsv1alpha1.LifecycleEventRecorder
+	terminated int
+	err        error
+}
+
+func (r *terminateRecorder) ConsoleTerminate(context.Context, *workloadsv1alpha1.Console, events.TerminateReason, bool, *corev1.Pod) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.terminated++
+	return nil
+}
+
+// historyRecorder collects the history it records, failing while err is set
+type historyRecorder struct {
+	recorded []*workloadsv1alpha1.ConsoleHistory
+	err      error
+}
+
+func (h *historyRecorder) RecordHistory(_ context.Context, history *workloadsv1alpha1.ConsoleHistory) error {
+	if h.err != nil {
+		return h.err
+	}
+
+	h.recorded = append(h.recorded, history)
+	return nil
+}
+
+var _ = Describe("Finalize", func() {
+	var (
+		ctx        context.Context
+		csl        *workloadsv1alpha1.Console
+		ns         *corev1.Namespace
+		recorder   *terminateRecorder
+		history    *historyRecorder
+		reconciler *ConsoleReconciler
+		req        ctrl.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		deletedAt := metav1.NewTime(time.Now())
+		csl = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "console-0",
+				Namespace:         "default",
+				DeletionTimestamp: &deletedAt,
+			},
+		}
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "console-0"}}
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(rbacv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(workloadsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+		recorder = &terminateRecorder{}
+		history = &historyRecorder{}
+		reconciler = &ConsoleReconciler{
+			Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns).Build(),
+			LifecycleRecorder: recorder,
+			History:           history,
+		}
+	})
+
+	It("records the console's history", func() {
+		Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		Expect(history.recorded).To(HaveLen(1))
+		Expect(history.recorded[0].Spec.ConsoleName).To(Equal("console-0"))
+	})
+
+	It("doesn't wait for history that fails to record", func() {
+		history.err = errors.New("forbidden")
+
+		Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		Expect(recorder.terminated).To(Equal(1))
+	})
+
+	It("skips history when the namespace is being deleted", func() {
+		ns.Status.Phase = corev1.NamespaceTerminating
+		Expect(reconciler.Update(ctx, ns)).To(Succeed())
+
+		Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		Expect(history.recorded).To(BeEmpty())
+		Expect(recorder.terminated).To(Equal(1))
+	})
+
+	It("records the Terminate event", func() {
+		Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		Expect(recorder.terminated).To(Equal(1))
+	})
+
+	It("doesn't record the Terminate event again", func() {
+		csl.Status.TerminateRecorded = true
+
+		Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		Expect(recorder.terminated).To(BeZero())
+	})
+
+	It("doesn't record the Terminate event for a console that completed before it was noted", func() {
+		completedAt := metav1.NewTime(time.Now().Add(-time.Hour))
+		csl.Status.Phase = workloadsv1alpha1.ConsoleStopped
+		csl.Status.CompletionTime = &completedAt
+
+		Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		Expect(recorder.terminated).To(BeZero())
+	})
+
+	Context("when the event fails to publish", func() {
+		BeforeEach(func() {
+			recorder.err = errors.New("unavailable")
+		})
+
+		It("retries shortly after the console was deleted", func() {
+			Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(MatchError(ContainSubstring("unavailable")))
+		})
+
+		It("gives up after the retry period", func() {
+			deletedAt := metav1.NewTime(time.Now().Add(-TerminateEventRetryPeriod))
+			csl.DeletionTimestamp = &deletedAt
+
+			Expect(reconciler.Finalize(logr.Discard(), ctx, req, csl)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+const (
+	ConsoleHistory = "consolehistory"
+
+	// DefaultHistoryRetention is how long console history is kept by default
+	DefaultHistoryRetention = 30 * 24 * time.Hour
+)
+
+// HistorySink records the history of consoles before they are deleted
+type HistorySink interface {
+	RecordHistory(ctx context.Context, history *workloadsv1alpha1.ConsoleHistory) error
+}
+
+// ConsoleHistorySink records history as ConsoleHistory objects in the
+// console's namespace
+type ConsoleHistorySink struct {
+	client client.Client
+}
+
+// Test we implement the HistorySink interface
+var _ HistorySink = &ConsoleHistorySink{}
+
+func NewConsoleHistorySink(c client.Client) *ConsoleHistorySink {
+	return &ConsoleHistorySink{client: c}
+}
+
+// RecordHistory creates the ConsoleHistory, succeeding if it has already been
+// created, as happens when a console's finalizer is retried
+func (s *ConsoleHistorySink) RecordHistory(ctx context.Context, history *workloadsv1alpha1.ConsoleHistory) error {
+	if err := s.client.Create(ctx, history); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
+// recordHistory builds the history of a console that is being deleted from it
+// and its children, which remain until the console has gone, and records it
+func (r *ConsoleReconciler) recordHistory(ctx context.Context, csl *workloadsv1alpha1.Console, pod *corev1.Pod) error {
+	name := types.NamespacedName{Namespace: csl.Namespace, Name: csl.Name}
+
+	job, err := r.getJob(ctx, name)
+	if apierrors.IsNotFound(err) {
+		job = nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to retrieve job")
+	}
+
+	authorisation, err := r.getConsoleAuthorisation(ctx, name)
+	if apierrors.IsNotFound(err) {
+		authorisation = nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to retrieve consoleauthorisation")
+	}
+
+	retention := r.HistoryRetention
+	if retention == 0 {
+		retention = DefaultHistoryRetention
+	}
+
+	return r.History.RecordHistory(ctx, buildConsoleHistory(csl, job, pod, authorisation, time.Now(), retention))
+}
+
+// namespaceTerminating returns whether the namespace is being deleted, in which
+// case nothing more can be created in it
+func (r *ConsoleReconciler) namespaceTerminating(ctx context.Context, name string) (bool, error) {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		return false, errors.Wrap(err, "failed to retrieve namespace")
+	}
+
+	return !ns.DeletionTimestamp.IsZero() || ns.Status.Phase == corev1.NamespaceTerminating, nil
+}
+
+// buildConsoleHistory builds a compact record of the console. The job, pod and
+// authorisation are each nil if they don't exist.
+func buildConsoleHistory(csl *workloadsv1alpha1.Console, job *batchv1.Job, pod *corev1.Pod, authorisation *workloadsv1alpha1.ConsoleAuthorisation, now time.Time, retention time.Duration) *workloadsv1alpha1.ConsoleHistory {
+	spec := workloadsv1alpha1.ConsoleHistorySpec{
+		ConsoleName:        csl.Name,
+		User:               csl.Spec.User,
+		Reason:             csl.Spec.Reason,
+		ConsoleTemplateRef: csl.Spec.ConsoleTemplateRef,
+		TemplateGeneration: csl.Status.TemplateGeneration,
+		TemplateHash:       csl.Status.TemplateHash,
+		Command:            csl.Spec.Command,
+		Noninteractive:     csl.Spec.Noninteractive,
+		TimeoutSeconds:     csl.Spec.TimeoutSeconds,
+		SharedWith:         csl.Spec.SharedWith,
+		Phase:              csl.Status.Phase,
+		CreationTime:       csl.CreationTimestamp,
+		AttachSessions:     csl.Status.AttachSessions,
+		ExpiryTime:         metav1.NewTime(now.Add(retention)),
+	}
+
+	if authorisation != nil {
+		for _, subject := range authorisation.Spec.Authorisations {
+			spec.Authorisers = append(spec.Authorisers, subject.Name)
+		}
+	}
+
+	if job != nil {
+		spec.StartTime = job.Status.StartTime
+		spec.EndTime = job.Status.CompletionTime
+		for _, c := range job.Status.Conditions {
+			if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+				spec.EndTime = c.LastTransitionTime.DeepCopy()
+			}
+		}
+	}
+
+	// The console is being deleted while it runs
+	if spec.StartTime != nil && spec.EndTime == nil {
+		spec.EndTime = csl.DeletionTimestamp
+	}
+
+	// The console's command runs in the first container of its pod
+	if pod != nil && len(pod.Spec.Containers) > 0 {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == pod.Spec.Containers[0].Name && status.State.Terminated != nil {
+				exitCode := status.State.Terminated.ExitCode
+				spec.ExitCode = &exitCode
+			}
+		}
+	}
+
+	labels := map[string]string{}
+	for key, value := range csl.Labels {
+		labels[key] = value
+	}
+
+	return &workloadsv1alpha1.ConsoleHistory{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      historyName(csl),
+			Namespace: csl.Namespace,
+			Labels:    labels,
+		},
+		Spec: *spec.DeepCopy(),
+	}
+}
+
+// historyName is unique to each console, as console names can be reused once
+// they have been deleted
+func historyName(csl *workloadsv1alpha1.Console) string {
+	uid := string(csl.UID)
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+	if uid == "" {
+		return csl.Name
+	}
+
+	return fmt.Sprintf("%s-%s", csl.Name, uid)
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("buildConsoleHistory", func() {
+	var (
+		csl           *workloadsv1alpha1.Console
+		job           *batchv1.Job
+		pod           *corev1.Pod
+		authorisation *workloadsv1alpha1.ConsoleAuthorisation
+		now           time.Time
+		history       *workloadsv1alpha1.ConsoleHistory
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+		csl = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "console-0",
+				Namespace:         "default",
+				UID:               "0123456789abcdef",
+				Labels:            map[string]string{"repo": "myapp"},
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			},
+			Spec: workloadsv1alpha1.ConsoleSpec{
+				User:               "alice@example.com",
+				Reason:             "debugging",
+				Command:            []string{"bin/rails", "console"},
+				ConsoleTemplateRef: corev1.LocalObjectReference{Name: "template"},
+			},
+			Status: workloadsv1alpha1.ConsoleStatus{
+				Phase:              workloadsv1alpha1.ConsoleStopped,
+				TemplateGeneration: 2,
+				TemplateHash:       "sha256:abc",
+				AttachSessions: []workloadsv1alpha1.ConsoleAttachSession{
+					{Username: "bob@example.com", StartTime: metav1.NewTime(now.Add(-50 * time.Minute))},
+				},
+			},
+		}
+		job, pod, authorisation = nil, nil, nil
+	})
+
+	JustBeforeEach(func() {
+		history = buildConsoleHistory(csl, job, pod, authorisation, now, time.Hour)
+	})
+
+	It("records the console", func() {
+		Expect(history.Name).To(Equal("console-0-01234567"))
+		Expect(history.Namespace).To(Equal("default"))
+		Expect(history.Labels).To(Equal(map[string]string{"repo": "myapp"}))
+		Expect(history.Spec.ConsoleName).To(Equal("console-0"))
+		Expect(history.Spec.User).To(Equal("alice@example.com"))
+		Expect(history.Spec.Command).To(Equal([]string{"bin/rails", "console"}))
+		Expect(history.Spec.TemplateGeneration).To(BeEquivalentTo(2))
+		Expect(history.Spec.TemplateHash).To(Equal("sha256:abc"))
+		Expect(history.Spec.Phase).To(Equal(workloadsv1alpha1.ConsoleStopped))
+		Expect(history.Spec.CreationTime).To(Equal(csl.CreationTimestamp))
+		Expect(history.Spec.AttachSessions).To(HaveLen(1))
+		Expect(history.Spec.ExpiryTime.Time).To(Equal(now.Add(time.Hour)))
+	})
+
+	It("leaves the times and exit code unset without a job or pod", func() {
+		Expect(history.Spec.StartTime).To(BeNil())
+		Expect(history.Spec.EndTime).To(BeNil())
+		Expect(history.Spec.ExitCode).To(BeNil())
+		Expect(history.Spec.Authorisers).To(BeEmpty())
+	})
+
+	Context("with an authorisation", func() {
+		BeforeEach(func() {
+			authorisation = &workloadsv1alpha1.ConsoleAuthorisation{
+				Spec: workloadsv1alpha1.ConsoleAuthorisationSpec{
+					Authorisations: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol@example.com"}},
+				},
+			}
+		})
+
+		It("records the authorisers", func() {
+			Expect(history.Spec.Authorisers).To(Equal([]string{"carol@example.com"}))
+		})
+	})
+
+	Context("with a completed job and pod", func() {
+		BeforeEach(func() {
+			started := metav1.NewTime(now.Add(-55 * time.Minute))
+			completed := metav1.NewTime(now.Add(-10 * time.Minute))
+			job = &batchv1.Job{
+				Status: batchv1.JobStatus{StartTime: &started, CompletionTime: &completed},
+			}
+			pod = &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "console"}, {Name: "sidecar"}},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{
+						{Name: "sidecar", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 2}}},
+						{Name: "console", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+					},
+				},
+			}
+		})
+
+		It("records the job's times and the console container's exit code", func() {
+			Expect(history.Spec.StartTime).To(Equal(job.Status.StartTime))
+			Expect(history.Spec.EndTime).To(Equal(job.Status.CompletionTime))
+			Expect(history.Spec.ExitCode).NotTo(BeNil())
+			Expect(*history.Spec.ExitCode).To(BeEquivalentTo(0))
+		})
+	})
+
+	Context("with a job that is still running", func() {
+		BeforeEach(func() {
+			started := metav1.NewTime(now.Add(-55 * time.Minute))
+			deleted := metav1.NewTime(now)
+			job = &batchv1.Job{Status: batchv1.JobStatus{StartTime: &started}}
+			csl.DeletionTimestamp = &deleted
+		})
+
+		It("ends the console when it was deleted", func() {
+			Expect(history.Spec.EndTime).To(Equal(csl.DeletionTimestamp))
+		})
+	})
+})
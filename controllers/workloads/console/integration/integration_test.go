@@ -439,6 +439,50 @@ var _ = Describe("Console", func() {
 			Expect(drb.ObjectMeta.OwnerReferences[0].Name).To(Equal(csl.ObjectMeta.Name))
 		})
 
+		Context("with SharedWith set", func() {
+			BeforeEach(func() {
+				csl.Spec.SharedWith = []string{"alice@example.com", "bob@example.com"}
+			})
+
+			It("Adds the collaborators to the directory role binding", func() {
+				By("Create a fake running pod (to simulate a real job controller)")
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-console-abcde", consoleName),
+						Namespace: namespaceName,
+						Labels:    labels.Set{"job-name": fmt.Sprintf("%s-console", consoleName)},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Image: "alpine:latest",
+								Name:  "console-container-0",
+							},
+						},
+					},
+				}
+				Expect(mgr.GetClient().Create(context.TODO(), pod)).NotTo(HaveOccurred(), "failed to create fake pod")
+				pod.Status.Phase = corev1.PodRunning
+				Expect(mgr.GetClient().Status().Update(context.TODO(), pod)).NotTo(HaveOccurred(), "failed to update fake pod status")
+
+				By("Expect directory role binding contains the collaborators")
+				drb := &rbacv1alpha1.DirectoryRoleBinding{}
+				Eventually(func() []rbacv1.Subject {
+					identifier := client.ObjectKeyFromObject(csl)
+					mgr.GetClient().Get(context.TODO(), identifier, drb)
+					return drb.Spec.Subjects
+				}).Should(
+					ConsistOf([]rbacv1.Subject{
+						{Kind: "User", Name: csl.Spec.User},
+						{Kind: "User", Name: "add-user@example.com"},
+						{Kind: "GoogleGroup", Name: "group@example.com"},
+						{Kind: "User", Name: "alice@example.com"},
+						{Kind: "User", Name: "bob@example.com"},
+					}),
+				)
+			})
+		})
+
 		It("Updates the status with expiry time", func() {
 			updatedCsl := &workloadsv1alpha1.Console{}
 			identifier := client.ObjectKeyFromObject(csl)
@@ -460,6 +504,26 @@ var _ = Describe("Console", func() {
 			)
 		})
 
+		It("Records the template the console's job was built from", func() {
+			job := &batchv1.Job{}
+			Eventually(func() error {
+				identifier := client.ObjectKeyFromObject(csl)
+				identifier.Name += "-console"
+				return mgr.GetClient().Get(context.TODO(), identifier, job)
+			}).ShouldNot(HaveOccurred(), "failed to find associated Job for Console")
+
+			Expect(job.Annotations).To(HaveKeyWithValue(workloadsv1alpha1.ConsoleTemplateGenerationAnnotation, "1"))
+			Expect(job.Annotations).To(HaveKeyWithValue(workloadsv1alpha1.ConsoleTemplateHashAnnotation, HavePrefix("sha256:")))
+
+			updatedCsl := &workloadsv1alpha1.Console{}
+			Eventually(func() string {
+				mgr.GetClient().Get(context.TODO(), client.ObjectKeyFromObject(csl), updatedCsl)
+				return updatedCsl.Status.TemplateHash
+			}).Should(Equal(job.Annotations[workloadsv1alpha1.ConsoleTemplateHashAnnotation]),
+				"the console status should record the job's template hash")
+			Expect(updatedCsl.Status.TemplateGeneration).To(BeEquivalentTo(1))
+		})
+
 		It("Updates the status with completion time", func() {
 			updatedCsl := &workloadsv1alpha1.Console{}
 			identifier := client.ObjectKeyFromObject(csl)
@@ -527,6 +591,25 @@ var _ = Describe("Console", func() {
 			Expect(csl.ObjectMeta.OwnerReferences[0].Name).To(Equal(consoleTemplate.ObjectMeta.Name))
 		})
 
+		It("Adds a finalizer that is removed when the console is deleted", func() {
+			identifier := client.ObjectKeyFromObject(csl)
+
+			By("Expect console to have the terminate finalizer")
+			Eventually(func() []string {
+				mgr.GetClient().Get(context.TODO(), identifier, csl)
+				return csl.ObjectMeta.Finalizers
+			}).Should(ContainElement(workloadsv1alpha1.ConsoleTerminateFinalizer))
+
+			By("Deleting the console")
+			Expect(mgr.GetClient().Delete(context.TODO(), csl)).NotTo(HaveOccurred())
+
+			By("Expect console to be deleted")
+			Eventually(func() metav1.StatusReason {
+				err := mgr.GetClient().Get(context.TODO(), identifier, &workloadsv1alpha1.Console{})
+				return apierrors.ReasonForError(err)
+			}).Should(Equal(metav1.StatusReasonNotFound), "expected not to find console, but did")
+		})
+
 		Describe("With an authorised console", func() {
 			BeforeEach(func() {
 				consoleTemplate.Spec.DefaultAuthorisationRule = &workloadsv1alpha1.ConsoleAuthorisers{
@@ -79,6 +79,7 @@ var _ = BeforeSuite(func() {
 		Handler: workloadsv1alpha1.NewConsoleAuthenticatorWebhook(
 			lifecycleRecorder,
 			ctrl.Log.WithName("webhooks").WithName("console-authenticator"),
+			"",
 		),
 	})
 
@@ -94,7 +95,17 @@ var _ = BeforeSuite(func() {
 	// console template webhook
 	mgr.GetWebhookServer().Register("/validate-consoletemplates", &admission.Webhook{
 		Handler: workloadsv1alpha1.NewConsoleTemplateValidationWebhook(
+			mgr.GetClient(),
+			lifecycleRecorder,
 			ctrl.Log.WithName("webhooks").WithName("console-template"),
+			workloadsv1alpha1.PendingConsolesAllow,
+		),
+	})
+
+	// console schedule webhook
+	mgr.GetWebhookServer().Register("/mutate-consoleschedules", &admission.Webhook{
+		Handler: workloadsv1alpha1.NewConsoleScheduleWebhook(
+			ctrl.Log.WithName("webhooks").WithName("console-schedule"),
 		),
 	})
 
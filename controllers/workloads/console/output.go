@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+const (
+	// OutputSecretType is the type of the secrets holding captured output
+	OutputSecretType corev1.SecretType = "workloads.crd.gocardless.com/console-output"
+
+	DefaultOutputCaptureMaxBytes = 256 * 1024
+
+	EventOutputCaptured = "OutputCaptured"
+)
+
+// shouldCaptureOutput determines whether the console's output should now be
+// captured: only once a non-interactive console has finished, and only once
+func (r *ConsoleReconciler) shouldCaptureOutput(csl *workloadsv1alpha1.Console, tpl *workloadsv1alpha1.ConsoleTemplate, pod *corev1.Pod) bool {
+	return r.PodLogs != nil &&
+		tpl.Spec.OutputCapture != nil &&
+		csl.Spec.Noninteractive &&
+		csl.Stopped() &&
+		csl.Status.CapturedOutput == nil &&
+		pod != nil
+}
+
+// captureOutput stores the logs of the console's container in a secret owned by
+// the console, truncating them to the limit set by the template
+func (r *ConsoleReconciler) captureOutput(ctx context.Context, logger logr.Logger, csl *workloadsv1alpha1.Console, tpl *workloadsv1alpha1.ConsoleTemplate, pod *corev1.Pod) (*workloadsv1alpha1.ConsoleCapturedOutput, error) {
+	maxBytes := DefaultOutputCaptureMaxBytes
+	if tpl.Spec.OutputCapture.MaxBytes != nil {
+		maxBytes = int(*tpl.Spec.OutputCapture.MaxBytes)
+	}
+
+	if len(pod.Spec.Containers) == 0 {
+		return nil, errors.New("console pod has no containers")
+	}
+
+	// Non-interactive consoles run their command in the first container
+	logs, err := r.PodLogs.Pods(pod.Namespace).GetLogs(
+		pod.Name, &corev1.PodLogOptions{Container: pod.Spec.Containers[0].Name},
+	).Stream(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get console logs")
+	}
+	defer logs.Close()
+
+	output := &tailBuffer{max: maxBytes}
+	if _, err := io.Copy(output, logs); err != nil {
+		return nil, errors.Wrap(err, "failed to read console logs")
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getOutputSecretName(csl.Name),
+			Namespace: csl.Namespace,
+			Labels: map[string]string{
+				"console-name": csl.Name,
+			},
+		},
+		Type: OutputSecretType,
+		Data: map[string][]byte{
+			workloadsv1alpha1.ConsoleOutputSecretKey: output.Bytes(),
+		},
+	}
+	if err := controllerutil.SetControllerReference(csl, secret, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	if err := r.createOutputSecret(ctx, csl, secret); err != nil {
+		return nil, err
+	}
+
+	logger.Info(
+		fmt.Sprintf("Captured %d bytes of console output", output.total),
+		"event", EventOutputCaptured,
+		"secret", secret.Name,
+		"truncated", output.Truncated(),
+	)
+
+	return &workloadsv1alpha1.ConsoleCapturedOutput{
+		SecretName: secret.Name,
+		Bytes:      output.total,
+		Truncated:  output.Truncated(),
+	}, nil
+}
+
+// createOutputSecret creates the secret directly, rather than through
+// CreateOrUpdate, to avoid caching every secret in the cluster. The output
+// doesn't change once the console has stopped, so an existing secret is from a
+// previous attempt that failed to update the console, but only if this console
+// controls it: anyone able to create secrets in the namespace could have
+// created one of the same name first, to have their contents shown as the
+// console's output.
+func (r *ConsoleReconciler) createOutputSecret(ctx context.Context, csl *workloadsv1alpha1.Console, secret *corev1.Secret) error {
+	err := r.Create(ctx, secret)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "failed to create output secret")
+	}
+
+	existing := &corev1.Secret{}
+	if err := r.apiReader().Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+		return errors.Wrap(err, "failed to get existing output secret")
+	}
+	if !metav1.IsControlledBy(existing, csl) || existing.Type != OutputSecretType {
+		return fmt.Errorf("output secret %s already exists, but was not created for this console", secret.Name)
+	}
+
+	return nil
+}
+
+// apiReader returns the reader for objects that the manager doesn't cache
+func (r *ConsoleReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+
+	return r.Client
+}
+
+func getOutputSecretName(consoleName string) string {
+	return fmt.Sprintf("%s-%s", consoleName, "output")
+}
+
+// tailBuffer keeps the last max bytes written to it, discarding anything
+// earlier
+type tailBuffer struct {
+	max   int
+	buf   []byte
+	total int64
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+
+	return len(p), nil
+}
+
+// Truncated returns whether any bytes have been discarded
+func (b *tailBuffer) Truncated() bool {
+	return b.total > int64(len(b.buf))
+}
+
+// Bytes returns the bytes kept, preceded by a marker if any were discarded
+func (b *tailBuffer) Bytes() []byte {
+	if !b.Truncated() {
+		return b.buf
+	}
+
+	marker := fmt.Sprintf("[output truncated: the first %d bytes were discarded]\n", b.total-int64(len(b.buf)))
+	return append([]byte(marker), b.buf...)
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("tailBuffer", func() {
+	var buffer *tailBuffer
+
+	BeforeEach(func() {
+		buffer = &tailBuffer{max: 10}
+	})
+
+	Context("when the output fits", func() {
+		It("keeps all of it", func() {
+			buffer.Write([]byte("hello"))
+			buffer.Write([]byte("!"))
+
+			Expect(buffer.Truncated()).To(BeFalse())
+			Expect(string(buffer.Bytes())).To(Equal("hello!"))
+		})
+	})
+
+	Context("when the output exceeds the limit", func() {
+		It("keeps the most recent output, preceded by a marker", func() {
+			buffer.Write([]byte("first line\n"))
+			buffer.Write([]byte(strings.Repeat("x", 4)))
+			buffer.Write([]byte("last\n"))
+
+			Expect(buffer.Truncated()).To(BeTrue())
+			Expect(buffer.total).To(BeEquivalentTo(20))
+			Expect(string(buffer.Bytes())).To(Equal(
+				"[output truncated: the first 10 bytes were discarded]\n\nxxxxlast\n",
+			))
+		})
+	})
+})
+
+var _ = Describe("createOutputSecret", func() {
+	var (
+		scheme *runtime.Scheme
+		csl    *workloadsv1alpha1.Console
+		secret *corev1.Secret
+	)
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(workloadsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		csl = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "default", UID: "console-uid"},
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: getOutputSecretName(csl.Name), Namespace: csl.Namespace},
+			Type:       OutputSecretType,
+		}
+		Expect(controllerutil.SetControllerReference(csl, secret, scheme)).To(Succeed())
+	})
+
+	createWithExisting := func(existing *corev1.Secret) error {
+		r := &ConsoleReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build(),
+			Scheme: scheme,
+		}
+		return r.createOutputSecret(context.Background(), csl, secret.DeepCopy())
+	}
+
+	It("accepts a secret from a previous attempt", func() {
+		Expect(createWithExisting(secret.DeepCopy())).To(Succeed())
+	})
+
+	It("rejects a secret not controlled by the console", func() {
+		existing := secret.DeepCopy()
+		existing.OwnerReferences = nil
+		Expect(createWithExisting(existing)).To(MatchError(ContainSubstring("was not created for this console")))
+	})
+
+	It("rejects a secret of another type", func() {
+		existing := secret.DeepCopy()
+		existing.Type = corev1.SecretTypeOpaque
+		Expect(createWithExisting(existing)).To(MatchError(ContainSubstring("was not created for this console")))
+	})
+})
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("Template version", func() {
+	var (
+		r   *ConsoleReconciler
+		csl *workloadsv1alpha1.Console
+		tpl *workloadsv1alpha1.ConsoleTemplate
+	)
+
+	name := types.NamespacedName{Namespace: "default", Name: "console-0"}
+
+	BeforeEach(func() {
+		r = &ConsoleReconciler{}
+		csl = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+			Spec: workloadsv1alpha1.ConsoleSpec{
+				User:           "alice@example.com",
+				Command:        []string{"bin/rails", "console"},
+				TimeoutSeconds: 3600,
+			},
+			Status: workloadsv1alpha1.ConsoleStatus{
+				TemplateGeneration: 1,
+				TemplateHash:       "sha256:requested",
+			},
+		}
+		tpl = &workloadsv1alpha1.ConsoleTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "template", Namespace: name.Namespace, Generation: 3},
+			Spec: workloadsv1alpha1.ConsoleTemplateSpec{
+				Template: workloadsv1alpha1.PodTemplatePreserveMetadataSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "console", Image: "app:v1"}},
+					},
+				},
+			},
+		}
+	})
+
+	It("records the template generation and pod spec hash on the job", func() {
+		job := r.buildJob(logr.Discard(), csl, tpl)
+
+		generation, hash := jobTemplateVersion(job)
+		Expect(generation).To(BeEquivalentTo(3))
+		Expect(hash).To(Equal(podSpecHash(&job.Spec.Template.Spec)))
+		Expect(hash).To(MatchRegexp("^sha256:[0-9a-f]{64}$"))
+	})
+
+	It("hashes the effective pod spec, including the console's command", func() {
+		hash := podSpecHash(&r.buildJob(logr.Discard(), csl, tpl).Spec.Template.Spec)
+
+		csl.Spec.Command = []string{"bin/rails", "runner", "true"}
+		Expect(podSpecHash(&r.buildJob(logr.Discard(), csl, tpl).Spec.Template.Spec)).NotTo(Equal(hash))
+	})
+
+	Describe("calculateStatus", func() {
+		It("takes the template version from the job", func() {
+			job := r.buildJob(logr.Discard(), csl, tpl)
+
+			status := calculateStatus(csl, consoleStatusContext{Job: job})
+			Expect(status.TemplateGeneration).To(BeEquivalentTo(3))
+			Expect(status.TemplateHash).To(Equal(job.Annotations[workloadsv1alpha1.ConsoleTemplateHashAnnotation]))
+		})
+
+		It("keeps the requested version until there is a job", func() {
+			status := calculateStatus(csl, consoleStatusContext{})
+			Expect(status.TemplateGeneration).To(BeEquivalentTo(1))
+			Expect(status.TemplateHash).To(Equal("sha256:requested"))
+		})
+
+		It("keeps the requested version for jobs that don't record one", func() {
+			job := r.buildJob(logr.Discard(), csl, tpl)
+			job.Annotations = nil
+
+			status := calculateStatus(csl, consoleStatusContext{Job: job})
+			Expect(status.TemplateHash).To(Equal("sha256:requested"))
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package consolehistory
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+	"github.com/gocardless/theatre/v3/pkg/recutil"
+)
+
+const (
+	EventDelete = "Delete"
+
+	ConsoleHistory = "consolehistory"
+)
+
+// ConsoleHistoryReconciler deletes console history once it has expired
+type ConsoleHistoryReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func (r *ConsoleHistoryReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	logger := r.Log.WithValues("component", "ConsoleHistory")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&workloadsv1alpha1.ConsoleHistory{}).
+		Complete(
+			recutil.ResolveAndReconcile(
+				ctx, logger, mgr, &workloadsv1alpha1.ConsoleHistory{},
+				func(logger logr.Logger, request reconcile.Request, obj runtime.Object) (reconcile.Result, error) {
+					return r.Reconcile(logger, ctx, request, obj.(*workloadsv1alpha1.ConsoleHistory))
+				},
+			),
+		)
+}
+
+func (r *ConsoleHistoryReconciler) Reconcile(logger logr.Logger, ctx context.Context, req ctrl.Request, history *workloadsv1alpha1.ConsoleHistory) (ctrl.Result, error) {
+	logger = logger.WithValues("consolehistory", req.NamespacedName)
+
+	// Requeue for when the history expires
+	if remaining := time.Until(history.Spec.ExpiryTime.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	logger.Info("Deleting expired console history", "event", EventDelete, "kind", ConsoleHistory)
+	if err := r.Delete(ctx, history); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
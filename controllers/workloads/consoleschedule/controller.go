@@ -0,0 +1,356 @@
+package consoleschedule
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	rbacv1alpha1 "github.com/gocardless/theatre/v3/apis/rbac/v1alpha1"
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+	"github.com/gocardless/theatre/v3/pkg/cron"
+	"github.com/gocardless/theatre/v3/pkg/logging"
+	"github.com/gocardless/theatre/v3/pkg/recutil"
+)
+
+const (
+	EventSuccessfulCreate     = "SuccessfulCreate"
+	EventSuccessfulUpdate     = "SuccessfulUpdate"
+	EventInvalidSpecification = "InvalidSpecification"
+	EventScheduled            = "Scheduled"
+	EventSkipped              = "Skipped"
+	EventPreempted            = "Preempted"
+
+	Console              = "console"
+	ConsoleSchedule      = "consoleschedule"
+	Role                 = "role"
+	DirectoryRoleBinding = "directoryrolebinding"
+)
+
+// ConsoleScheduleReconciler creates consoles at each tick of a console
+// schedule, while the schedule is active and authorised
+type ConsoleScheduleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// The username the manager authenticates as, which the consoles it creates
+	// belong to
+	ManagerUsername string
+}
+
+func (r *ConsoleScheduleReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	logger := r.Log.WithValues("component", "ConsoleSchedule")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&workloadsv1alpha1.ConsoleSchedule{}).
+		Complete(
+			recutil.ResolveAndReconcile(
+				ctx, logger, mgr, &workloadsv1alpha1.ConsoleSchedule{},
+				func(logger logr.Logger, request reconcile.Request, obj runtime.Object) (reconcile.Result, error) {
+					return r.Reconcile(logger, ctx, request, obj.(*workloadsv1alpha1.ConsoleSchedule))
+				},
+			),
+		)
+}
+
+func (r *ConsoleScheduleReconciler) Reconcile(logger logr.Logger, ctx context.Context, req ctrl.Request, schedule *workloadsv1alpha1.ConsoleSchedule) (ctrl.Result, error) {
+	logger = logger.WithValues("consoleschedule", req.NamespacedName)
+	now := time.Now()
+
+	tpl := &workloadsv1alpha1.ConsoleTemplate{}
+	tplName := types.NamespacedName{Name: schedule.Spec.ConsoleTemplateRef.Name, Namespace: schedule.Namespace}
+	if err := r.Get(ctx, tplName, tpl); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to retrieve console template")
+	}
+
+	status := schedule.Status.DeepCopy()
+	status.AuthorisationRuleName = ""
+
+	// Allow the members of the command's authorisation rule to authorise the
+	// schedule, in the same way as they would a console
+	required := 0
+	if tpl.HasAuthorisationRules() {
+		rule, err := tpl.GetAuthorisationRuleForCommand(schedule.Spec.Command)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to determine authorisation rule for schedule command")
+		}
+
+		required = rule.AuthorisationsRequired
+		status.AuthorisationRuleName = rule.Name
+		if err := r.createAuthorisationObjects(ctx, logger, schedule, rule.Subjects); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	cronSchedule, err := cron.Parse(schedule.Spec.Schedule)
+	if err != nil {
+		// The webhook should prevent this, and there's nothing to be gained by
+		// retrying
+		logger.Info("Invalid console schedule", "event", EventInvalidSpecification, "error", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	status.Phase = calculatePhase(schedule, required, now)
+
+	since := schedule.CreationTimestamp.Time
+	if status.LastScheduleTime != nil {
+		since = status.LastScheduleTime.Time
+	}
+	latest, next := scheduleTicks(cronSchedule, since, now)
+
+	if !latest.IsZero() {
+		status.LastScheduleTime = &metav1.Time{Time: latest}
+
+		switch {
+		case status.Phase != workloadsv1alpha1.ConsoleScheduleActive || !latest.Before(schedule.Spec.ExpiryTime.Time):
+			logger.Info(
+				fmt.Sprintf("Skipped console for tick at %s: schedule is %s", latest.Format(time.RFC3339), status.Phase),
+				"event", EventSkipped,
+			)
+		case now.Sub(latest) > workloadsv1alpha1.ConsoleScheduleStartingDeadline:
+			logger.Info(
+				fmt.Sprintf("Skipped console for tick at %s: missed the starting deadline", latest.Format(time.RFC3339)),
+				"event", EventSkipped,
+			)
+		default:
+			csl, err := r.createConsole(ctx, logger, schedule, latest)
+			switch {
+			case err == errPreempted:
+				// Retrying won't help, and the console isn't authorised by the
+				// schedule, so record the tick as skipped
+				logger.Info(
+					fmt.Sprintf("Skipped console for tick at %s: %s", latest.Format(time.RFC3339), err),
+					"event", EventPreempted,
+					"console", schedule.ConsoleNameForTick(latest),
+				)
+			case err != nil:
+				return ctrl.Result{}, err
+			default:
+				status.LastConsoleName = csl.Name
+			}
+		}
+	}
+
+	status.NextScheduleTime = nil
+	if !next.IsZero() && next.Before(schedule.Spec.ExpiryTime.Time) {
+		status.NextScheduleTime = &metav1.Time{Time: next}
+	}
+
+	if !reflect.DeepEqual(schedule.Status, *status) {
+		updated := schedule.DeepCopy()
+		updated.Status = *status
+		if err := r.Update(ctx, updated); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to update console schedule status")
+		}
+	}
+
+	if status.Phase == workloadsv1alpha1.ConsoleScheduleExpired {
+		return ctrl.Result{}, nil
+	}
+
+	// Wake up for the next tick, or when the schedule expires so that its phase
+	// can be updated
+	wakeAt := schedule.Spec.ExpiryTime.Time
+	if status.NextScheduleTime != nil {
+		wakeAt = status.NextScheduleTime.Time
+	}
+
+	return requeueAfterInterval(logger, time.Until(wakeAt)), nil
+}
+
+// createConsole creates the console for the given tick. Consoles are named
+// after their tick, so if we've already created it, e.g. because we failed to
+// update the schedule's status afterwards, this is a no-op. Anyone could have
+// created a console of that name first, which is refused.
+func (r *ConsoleScheduleReconciler) createConsole(ctx context.Context, logger logr.Logger, schedule *workloadsv1alpha1.ConsoleSchedule, tick time.Time) (*workloadsv1alpha1.Console, error) {
+	csl := buildConsole(schedule, tick)
+
+	// The template remains the console's controller, so the console isn't
+	// deleted along with the schedule
+	if err := controllerutil.SetOwnerReference(schedule, csl, r.Scheme); err != nil {
+		return nil, errors.Wrap(err, "failed to set owner reference on console")
+	}
+
+	if err := r.Create(ctx, csl); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, errors.Wrap(err, "failed to create console")
+		}
+
+		existing := &workloadsv1alpha1.Console{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(csl), existing); err != nil {
+			return nil, errors.Wrap(err, "failed to get existing console")
+		}
+		if !r.createdConsole(schedule, existing) {
+			return nil, errPreempted
+		}
+
+		return existing, nil
+	}
+
+	logger.Info(
+		fmt.Sprintf("Created %s: %s for tick at %s", Console, csl.Name, tick.Format(time.RFC3339)),
+		"event", EventScheduled, "console", csl.Name,
+	)
+
+	return csl, nil
+}
+
+// errPreempted is returned when a console for the tick exists that the
+// schedule didn't create
+var errPreempted = errors.New("a console for the tick already exists that the schedule didn't create")
+
+// createdConsole returns whether the schedule created the console, as consoles
+// created by the manager belong to it
+func (r *ConsoleScheduleReconciler) createdConsole(schedule *workloadsv1alpha1.ConsoleSchedule, csl *workloadsv1alpha1.Console) bool {
+	if r.ManagerUsername == "" || csl.Spec.User != r.ManagerUsername {
+		return false
+	}
+
+	for _, ref := range csl.GetOwnerReferences() {
+		if ref.UID == schedule.UID {
+			return csl.Labels[workloadsv1alpha1.ConsoleScheduleLabel] == schedule.Name
+		}
+	}
+
+	return false
+}
+
+// createAuthorisationObjects creates a role and directory role binding that
+// allows the subjects of the authorisation rule to authorise the schedule
+func (r *ConsoleScheduleReconciler) createAuthorisationObjects(ctx context.Context, logger logr.Logger, schedule *workloadsv1alpha1.ConsoleSchedule, subjects []rbacv1.Subject) error {
+	name := fmt.Sprintf("%s-authorisation", schedule.Name)
+
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: schedule.Namespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:         []string{"get", "patch", "update"},
+				APIGroups:     []string{"workloads.crd.gocardless.com"},
+				Resources:     []string{"consoleschedules"},
+				ResourceNames: []string{schedule.Name},
+			},
+		},
+	}
+
+	if err := r.createOrUpdate(ctx, logger, schedule, role, Role, recutil.RoleDiff); err != nil {
+		return errors.Wrap(err, "failed to create role for consoleschedule")
+	}
+
+	drb := &rbacv1alpha1.DirectoryRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: schedule.Namespace,
+		},
+		Spec: rbacv1alpha1.DirectoryRoleBindingSpec{
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "Role",
+				Name:     role.Name,
+			},
+			Subjects: subjects,
+		},
+	}
+
+	if err := r.createOrUpdate(ctx, logger, schedule, drb, DirectoryRoleBinding, recutil.DirectoryRoleBindingDiff); err != nil {
+		return errors.Wrap(err, "failed to create directory rolebinding for consoleschedule")
+	}
+
+	return nil
+}
+
+func (r *ConsoleScheduleReconciler) createOrUpdate(ctx context.Context, logger logr.Logger, schedule *workloadsv1alpha1.ConsoleSchedule, expected recutil.ObjWithMeta, kind string, diffFunc recutil.DiffFunc) error {
+	if err := controllerutil.SetControllerReference(schedule, expected, r.Scheme); err != nil {
+		return err
+	}
+
+	outcome, err := recutil.CreateOrUpdate(ctx, r.Client, expected, diffFunc)
+	if err != nil {
+		return errors.Wrap(err, "CreateOrUpdate failed")
+	}
+
+	objDesc := fmt.Sprintf("%s: %s", kind, expected.GetName())
+	switch outcome {
+	case recutil.Create:
+		logger.Info("Created "+objDesc, "event", EventSuccessfulCreate)
+	case recutil.Update:
+		logger.Info("Updated "+objDesc, "event", EventSuccessfulUpdate)
+	}
+
+	return nil
+}
+
+// buildConsole returns the console to create for the given tick. It runs the
+// schedule's command non-interactively, and is shared with the schedule's
+// owner so that they can follow its output.
+func buildConsole(schedule *workloadsv1alpha1.ConsoleSchedule, tick time.Time) *workloadsv1alpha1.Console {
+	reason := fmt.Sprintf("Scheduled by %s %s", ConsoleSchedule, schedule.Name)
+	if schedule.Spec.Reason != "" {
+		reason = fmt.Sprintf("%s: %s", reason, schedule.Spec.Reason)
+	}
+
+	return &workloadsv1alpha1.Console{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      schedule.ConsoleNameForTick(tick),
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				workloadsv1alpha1.ConsoleScheduleLabel: schedule.Name,
+			},
+		},
+		Spec: workloadsv1alpha1.ConsoleSpec{
+			Reason:             reason,
+			ConsoleTemplateRef: schedule.Spec.ConsoleTemplateRef,
+			Command:            schedule.Spec.Command,
+			TimeoutSeconds:     schedule.Spec.TimeoutSeconds,
+			Noninteractive:     true,
+			SharedWith:         []string{schedule.Spec.User},
+		},
+	}
+}
+
+// calculatePhase returns the phase of the schedule, given the number of
+// authorisations that its command requires
+func calculatePhase(schedule *workloadsv1alpha1.ConsoleSchedule, required int, now time.Time) workloadsv1alpha1.ConsoleSchedulePhase {
+	switch {
+	case !now.Before(schedule.Spec.ExpiryTime.Time):
+		return workloadsv1alpha1.ConsoleScheduleExpired
+	case schedule.Spec.Suspend:
+		return workloadsv1alpha1.ConsoleScheduleSuspended
+	case !schedule.IsAuthorised(required):
+		return workloadsv1alpha1.ConsoleSchedulePendingAuthorisation
+	default:
+		return workloadsv1alpha1.ConsoleScheduleActive
+	}
+}
+
+// scheduleTicks returns the latest tick after since and no later than now, or
+// the zero time if there are none, along with the first tick after now
+func scheduleTicks(schedule *cron.Schedule, since, now time.Time) (latest time.Time, next time.Time) {
+	for t := schedule.Next(since); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		latest = t
+	}
+
+	return latest, schedule.Next(now)
+}
+
+func requeueAfterInterval(logger logr.Logger, interval time.Duration) reconcile.Result {
+	logging.WithNoRecord(logger).Info(
+		"Reconciliation requeued",
+		"event", recutil.EventRequeued,
+		"reconcile_after", interval,
+	)
+	return reconcile.Result{Requeue: true, RequeueAfter: interval}
+}
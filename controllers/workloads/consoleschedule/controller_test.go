@@ -0,0 +1,150 @@
+package consoleschedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+	"github.com/gocardless/theatre/v3/pkg/cron"
+)
+
+var _ = Describe("ConsoleSchedule controller", func() {
+	var (
+		now      time.Time
+		schedule *workloadsv1alpha1.ConsoleSchedule
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2022, 3, 7, 4, 30, 0, 0, time.UTC)
+		schedule = &workloadsv1alpha1.ConsoleSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cleanup",
+				Namespace: "default",
+			},
+			Spec: workloadsv1alpha1.ConsoleScheduleSpec{
+				User:               "owner",
+				Reason:             "Weekly cleanup",
+				ConsoleTemplateRef: corev1.LocalObjectReference{Name: "app"},
+				Command:            []string{"rake", "cleanup"},
+				Schedule:           "0 4 * * 1",
+				TimeoutSeconds:     600,
+				ExpiryTime:         metav1.NewTime(now.Add(7 * 24 * time.Hour)),
+			},
+		}
+	})
+
+	Describe("scheduleTicks", func() {
+		var cronSchedule *cron.Schedule
+
+		BeforeEach(func() {
+			var err error
+			cronSchedule, err = cron.Parse("0 * * * *")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns the latest missed tick, and the next one", func() {
+			latest, next := scheduleTicks(cronSchedule, now.Add(-3*time.Hour), now)
+			Expect(latest).To(Equal(time.Date(2022, 3, 7, 4, 0, 0, 0, time.UTC)))
+			Expect(next).To(Equal(time.Date(2022, 3, 7, 5, 0, 0, 0, time.UTC)))
+		})
+
+		It("returns no latest tick when none have been missed", func() {
+			latest, _ := scheduleTicks(cronSchedule, now.Add(-10*time.Minute), now)
+			Expect(latest.IsZero()).To(BeTrue())
+		})
+	})
+
+	Describe("calculatePhase", func() {
+		It("is active when sufficiently authorised", func() {
+			schedule.Spec.Authorisations = []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "authoriser"}}
+			Expect(calculatePhase(schedule, 1, now)).To(Equal(workloadsv1alpha1.ConsoleScheduleActive))
+		})
+
+		It("is pending authorisation without enough authorisations", func() {
+			Expect(calculatePhase(schedule, 1, now)).To(Equal(workloadsv1alpha1.ConsoleSchedulePendingAuthorisation))
+		})
+
+		It("is suspended when suspended", func() {
+			schedule.Spec.Suspend = true
+			Expect(calculatePhase(schedule, 0, now)).To(Equal(workloadsv1alpha1.ConsoleScheduleSuspended))
+		})
+
+		It("is expired after the expiry time", func() {
+			schedule.Spec.Suspend = true
+			Expect(calculatePhase(schedule, 0, schedule.Spec.ExpiryTime.Time)).To(Equal(workloadsv1alpha1.ConsoleScheduleExpired))
+		})
+	})
+
+	Describe("buildConsole", func() {
+		It("builds a non-interactive console for the tick, linked to the schedule", func() {
+			tick := time.Date(2022, 3, 7, 4, 0, 0, 0, time.UTC)
+			csl := buildConsole(schedule, tick)
+
+			Expect(csl.Name).To(Equal(schedule.ConsoleNameForTick(tick)))
+			Expect(csl.Namespace).To(Equal("default"))
+			Expect(csl.Labels).To(HaveKeyWithValue(workloadsv1alpha1.ConsoleScheduleLabel, "cleanup"))
+			Expect(csl.Spec.Reason).To(Equal("Scheduled by consoleschedule cleanup: Weekly cleanup"))
+			Expect(csl.Spec.Command).To(Equal([]string{"rake", "cleanup"}))
+			Expect(csl.Spec.ConsoleTemplateRef.Name).To(Equal("app"))
+			Expect(csl.Spec.TimeoutSeconds).To(Equal(600))
+			Expect(csl.Spec.Noninteractive).To(BeTrue())
+			Expect(csl.Spec.SharedWith).To(ConsistOf("owner"))
+		})
+	})
+
+	Describe("createConsole", func() {
+		var (
+			scheme   *runtime.Scheme
+			tick     time.Time
+			existing *workloadsv1alpha1.Console
+		)
+
+		BeforeEach(func() {
+			scheme = runtime.NewScheme()
+			Expect(workloadsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+			schedule.UID = "schedule-uid"
+			tick = time.Date(2022, 3, 7, 4, 0, 0, 0, time.UTC)
+			existing = buildConsole(schedule, tick)
+			existing.Spec.User = "manager"
+			Expect(controllerutil.SetOwnerReference(schedule, existing, scheme)).To(Succeed())
+		})
+
+		createConsole := func() (*workloadsv1alpha1.Console, error) {
+			r := &ConsoleScheduleReconciler{
+				Client:          fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build(),
+				Scheme:          scheme,
+				ManagerUsername: "manager",
+			}
+			return r.createConsole(context.Background(), logr.Discard(), schedule, tick)
+		}
+
+		It("accepts the console it created for the tick before", func() {
+			csl, err := createConsole()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(csl.Name).To(Equal(existing.Name))
+		})
+
+		It("refuses a console someone else created for the tick", func() {
+			existing.Spec.User = "mallory"
+			_, err := createConsole()
+			Expect(err).To(Equal(errPreempted))
+		})
+
+		It("refuses a console for the tick that the schedule doesn't own", func() {
+			existing.OwnerReferences = nil
+			_, err := createConsole()
+			Expect(err).To(Equal(errPreempted))
+		})
+	})
+})
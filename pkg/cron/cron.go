@@ -0,0 +1,205 @@
+// Package cron parses standard five field cron schedules, e.g. "30 4 * * 1",
+// and calculates when they next fire. Schedules are evaluated in UTC.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron schedule
+type Schedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+
+	// Whether the day of month and day of week fields were unrestricted. When
+	// both are restricted a day matches if either field matches, as in
+	// traditional cron.
+	dayOfMonthStar, dayOfWeekStar bool
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minuteBounds     = bounds{0, 59, nil}
+	hourBounds       = bounds{0, 23, nil}
+	dayOfMonthBounds = bounds{1, 31, nil}
+	monthBounds      = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are accepted for Sunday
+	dayOfWeekBounds = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// maxSearch bounds how far ahead we look for the next time a schedule fires,
+// which is only reached by schedules that can never fire, e.g. "0 0 30 2 *"
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Parse parses a five field cron schedule, or one of the @yearly, @monthly,
+// @weekly, @daily or @hourly descriptors
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := descriptors[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron schedule, found %d: %q", len(fields), spec)
+	}
+
+	var (
+		s   = &Schedule{}
+		err error
+	)
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if s.dayOfMonth, err = parseField(fields[2], dayOfMonthBounds); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if s.dayOfWeek, err = parseField(fields[4], dayOfWeekBounds); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %w", err)
+	}
+
+	// Treat 7 as Sunday
+	if s.dayOfWeek&(1<<7) != 0 {
+		s.dayOfWeek |= 1
+	}
+
+	s.dayOfMonthStar = strings.HasPrefix(fields[2], "*")
+	s.dayOfWeekStar = strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into a
+// bitset of the matching values
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangeAndStep := strings.SplitN(part, "/", 2)
+
+		start, end := b.min, b.max
+		switch {
+		case rangeAndStep[0] == "*":
+		case strings.Contains(rangeAndStep[0], "-"):
+			startAndEnd := strings.SplitN(rangeAndStep[0], "-", 2)
+			var err error
+			if start, err = parseValue(startAndEnd[0], b); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(startAndEnd[1], b); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("range start %d is after its end %d", start, end)
+			}
+		default:
+			value, err := parseValue(rangeAndStep[0], b)
+			if err != nil {
+				return 0, err
+			}
+			start, end = value, value
+			// A step from a single value runs to the maximum, e.g. 5/15
+			if len(rangeAndStep) == 2 {
+				end = b.max
+			}
+		}
+
+		step := uint(1)
+		if len(rangeAndStep) == 2 {
+			parsed, err := strconv.ParseUint(rangeAndStep[1], 10, 8)
+			if err != nil || parsed == 0 {
+				return 0, fmt.Errorf("invalid step %q", rangeAndStep[1])
+			}
+			step = uint(parsed)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(value string, b bounds) (uint, error) {
+	if named, ok := b.names[strings.ToLower(value)]; ok {
+		return named, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if uint(parsed) < b.min || uint(parsed) > b.max {
+		return 0, fmt.Errorf("value %d outside of range %d-%d", parsed, b.min, b.max)
+	}
+
+	return uint(parsed), nil
+}
+
+// Next returns the first time after t at which the schedule fires, or the zero
+// time if it never does
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.dayOfMonthStar || s.dayOfWeekStar {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
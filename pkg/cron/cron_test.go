@@ -0,0 +1,57 @@
+package cron
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schedule", func() {
+	mustParseTime := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+
+	DescribeTable("Next",
+		func(spec, from, expected string) {
+			schedule, err := Parse(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(schedule.Next(mustParseTime(from))).To(Equal(mustParseTime(expected)))
+		},
+		Entry("every minute", "* * * * *", "2022-03-01T10:15:30Z", "2022-03-01T10:16:00Z"),
+		Entry("hourly descriptor", "@hourly", "2022-03-01T10:15:00Z", "2022-03-01T11:00:00Z"),
+		Entry("daily at a time", "30 4 * * *", "2022-03-01T10:15:00Z", "2022-03-02T04:30:00Z"),
+		Entry("weekly on Monday", "0 9 * * 1", "2022-03-01T10:15:00Z", "2022-03-07T09:00:00Z"),
+		Entry("Sunday as 7", "0 9 * * 7", "2022-03-01T10:15:00Z", "2022-03-06T09:00:00Z"),
+		Entry("named days", "0 9 * * mon-fri", "2022-03-04T10:15:00Z", "2022-03-07T09:00:00Z"),
+		Entry("steps", "*/20 * * * *", "2022-03-01T10:15:00Z", "2022-03-01T10:20:00Z"),
+		Entry("step from a value", "5/30 * * * *", "2022-03-01T10:15:00Z", "2022-03-01T10:35:00Z"),
+		Entry("lists", "0 1,13 * * *", "2022-03-01T10:15:00Z", "2022-03-01T13:00:00Z"),
+		Entry("monthly, crossing a year", "0 0 1 * *", "2022-12-15T00:00:00Z", "2023-01-01T00:00:00Z"),
+		Entry("day of month or week", "0 0 15 * 1", "2022-03-01T10:15:00Z", "2022-03-07T00:00:00Z"),
+		Entry("a non-UTC time", "0 * * * *", "2022-03-01T10:15:00+01:00", "2022-03-01T10:00:00Z"),
+	)
+
+	It("returns the zero time for schedules that never fire", func() {
+		schedule, err := Parse("0 0 30 2 *")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(schedule.Next(time.Now()).IsZero()).To(BeTrue())
+	})
+
+	DescribeTable("Parse errors",
+		func(spec string) {
+			_, err := Parse(spec)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("too few fields", "* * * *"),
+		Entry("out of range", "60 * * * *"),
+		Entry("inverted range", "* 5-1 * * *"),
+		Entry("zero step", "*/0 * * * *"),
+		Entry("unknown name", "* * * * someday"),
+	)
+})
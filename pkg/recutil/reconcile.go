@@ -13,6 +13,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -28,6 +29,7 @@ const (
 	EventRequeued     = "ReconcileRequeued"
 	EventError        = "ReconcileError"
 	EventComplete     = "ReconcileComplete"
+	EventFinalized    = "ReconcileFinalized"
 )
 
 var (
@@ -49,10 +51,27 @@ func init() {
 // at the start of traditional reconciliation loops.
 type ObjectReconcileFunc func(logger logr.Logger, request reconcile.Request, obj runtime.Object) (reconcile.Result, error)
 
+// ObjectFinalizeFunc performs the actions of a finalizer on an object that is being
+// deleted. Once it returns without error the finalizer is removed, allowing the object
+// to be deleted.
+type ObjectFinalizeFunc func(logger logr.Logger, request reconcile.Request, obj runtime.Object) error
+
 // ResolveAndReconcile helps avoid boilerplate where you would normally attempt to fetch
 // your modified object at the start of a reconciliation loop, and instead calls an inner
 // reconciliation function with the already resolved object.
 func ResolveAndReconcile(ctx context.Context, logger logr.Logger, mgr manager.Manager, objType runtime.Object, inner ObjectReconcileFunc) reconcile.Reconciler {
+	return resolveAndReconcile(ctx, logger, mgr, objType, inner, "", nil)
+}
+
+// ResolveAndReconcileWithFinalizer behaves like ResolveAndReconcile, but also ensures
+// that every object has the given finalizer. When an object with the finalizer is
+// deleted, finalize is called instead of the inner reconciliation function, and the
+// finalizer removed once it succeeds.
+func ResolveAndReconcileWithFinalizer(ctx context.Context, logger logr.Logger, mgr manager.Manager, objType runtime.Object, finalizer string, inner ObjectReconcileFunc, finalize ObjectFinalizeFunc) reconcile.Reconciler {
+	return resolveAndReconcile(ctx, logger, mgr, objType, inner, finalizer, finalize)
+}
+
+func resolveAndReconcile(ctx context.Context, logger logr.Logger, mgr manager.Manager, objType runtime.Object, inner ObjectReconcileFunc, finalizer string, finalize ObjectFinalizeFunc) reconcile.Reconciler {
 	return reconcile.Func(func(ctx context.Context, request reconcile.Request) (res reconcile.Result, err error) {
 		logger := logger.WithValues("request", request)
 		logger.Info("Reconcile request start", "event", EventRequestStart)
@@ -102,15 +121,35 @@ func ResolveAndReconcile(ctx context.Context, logger logr.Logger, mgr manager.Ma
 		// reconciliation, as this can lead to recreating child resources (which
 		// we'd expect to be eventually deleted via propagation) and getting stuck
 		// in an infinite loop, due to these resources now blocking the deletion of
-		// the parent.
-		// If we need to support finalizers in the future then this will need to be
-		// extended to call a function that performs the finalizer actions.
+		// the parent. Only the finalizer actions are performed, if the object still
+		// has our finalizer.
 		if !obj.GetDeletionTimestamp().IsZero() {
-			logger.Info("Skipping reconciliation due to deletion", "event", EventSkipped)
-			res = reconcile.Result{Requeue: false}
+			if finalizer == "" || !controllerutil.ContainsFinalizer(obj, finalizer) {
+				logger.Info("Skipping reconciliation due to deletion", "event", EventSkipped)
+				res = reconcile.Result{Requeue: false}
+				return res, nil
+			}
+
+			if err := finalize(logger, request, obj); err != nil {
+				return res, errors.Wrap(err, "failed to finalize")
+			}
+
+			controllerutil.RemoveFinalizer(obj, finalizer)
+			if err := mgr.GetClient().Update(ctx, obj); err != nil {
+				return res, errors.Wrap(err, "failed to remove finalizer")
+			}
+
+			logger.Info("Removed finalizer", "event", EventFinalized, "finalizer", finalizer)
 			return res, nil
 		}
 
+		if finalizer != "" && !controllerutil.ContainsFinalizer(obj, finalizer) {
+			controllerutil.AddFinalizer(obj, finalizer)
+			if err := mgr.GetClient().Update(ctx, obj); err != nil {
+				return res, errors.Wrap(err, "failed to add finalizer")
+			}
+		}
+
 		return inner(logger, request, obj)
 	})
 }
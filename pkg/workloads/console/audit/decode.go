@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+// Event is a lifecycle event, with its spec left to be decoded according to
+// the kind of event
+type Event struct {
+	events.CommonEvent `json:",inline"`
+	Spec               json.RawMessage `json:"spec"`
+}
+
+var (
+	kinds      = []events.Kind{events.KindConsole, events.KindConsoleTemplate}
+	eventKinds = []events.EventKind{
+		events.EventRequest, events.EventAuthorise, events.EventStart,
+		events.EventAttach, events.EventAttachDenied, events.EventAttachClosed, events.EventTerminated,
+		events.EventTemplateChange,
+	}
+)
+
+// Decode decodes an event published in any of the formats supported by the
+// workloads-manager. Binary mode CloudEvents carry their attributes alongside
+// the body, so must be decoded with DecodeMessage.
+func Decode(body []byte) (Event, error) {
+	var cloudEvent events.CloudEvent
+	if err := json.Unmarshal(body, &cloudEvent); err != nil {
+		return Event{}, err
+	}
+	if cloudEvent.SpecVersion != "" {
+		return fromCloudEvent(cloudEvent)
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Event{}, err
+	}
+	if event.Kind == "" || event.Event == "" {
+		return Event{}, fmt.Errorf("not a theatre event: %s", body)
+	}
+
+	return event, nil
+}
+
+// DecodeMessage decodes an event received as a message whose headers or
+// attributes, named with the given prefix, may hold the attributes of a binary
+// mode CloudEvent. Other events are decoded from the body alone.
+func DecodeMessage(headers map[string]string, prefix string, body []byte) (Event, error) {
+	attributes := map[string]string{}
+	for name, value := range headers {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, prefix) {
+			attributes[strings.TrimPrefix(name, prefix)] = value
+		}
+	}
+
+	if attributes["specversion"] == "" {
+		return Decode(body)
+	}
+
+	observedAt, err := time.Parse(time.RFC3339Nano, attributes["time"])
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid CloudEvent time: %w", err)
+	}
+
+	return fromCloudEvent(events.CloudEvent{
+		SpecVersion:  attributes["specversion"],
+		ID:           attributes["id"],
+		Source:       attributes["source"],
+		Type:         attributes["type"],
+		Subject:      attributes["subject"],
+		Time:         observedAt,
+		Data:         body,
+		PartitionKey: attributes["partitionkey"],
+	})
+}
+
+// fromCloudEvent reverses events.NewCloudEvent. The event's annotations and
+// apiVersion aren't carried by the CloudEvent, so are left empty.
+func fromCloudEvent(cloudEvent events.CloudEvent) (Event, error) {
+	if !strings.HasPrefix(cloudEvent.Type, events.CloudEventsTypePrefix) {
+		return Event{}, fmt.Errorf("not a theatre CloudEvent: %s", cloudEvent.Type)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(cloudEvent.Type, events.CloudEventsTypePrefix), ".")
+	if len(parts) != 2 {
+		return Event{}, fmt.Errorf("invalid CloudEvent type: %s", cloudEvent.Type)
+	}
+
+	event := Event{Spec: cloudEvent.Data}
+	for _, kind := range kinds {
+		if strings.ToLower(string(kind)) == parts[0] {
+			event.Kind = kind
+		}
+	}
+	for _, eventKind := range eventKinds {
+		if strings.ToLower(string(eventKind)) == parts[1] {
+			event.Event = eventKind
+		}
+	}
+	if event.Kind == "" || event.Event == "" {
+		return Event{}, fmt.Errorf("unknown CloudEvent type: %s", cloudEvent.Type)
+	}
+
+	// The partition key is the theatre id, which also prefixes the CloudEvent id
+	event.Id = cloudEvent.PartitionKey
+	if event.Id == "" {
+		if idx := strings.LastIndex(cloudEvent.ID, "/"); idx > 0 {
+			if idx = strings.LastIndex(cloudEvent.ID[:idx], "/"); idx > 0 {
+				event.Id = cloudEvent.ID[:idx]
+			}
+		}
+	}
+	event.ObservedAt = cloudEvent.Time
+
+	return event, nil
+}
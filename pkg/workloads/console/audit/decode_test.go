@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+var _ = Describe("Decode", func() {
+	var attach *events.ConsoleAttachEvent
+
+	BeforeEach(func() {
+		attach = &events.ConsoleAttachEvent{
+			CommonEvent: events.CommonEvent{
+				Version:    "v1alpha1",
+				Kind:       events.KindConsole,
+				Event:      events.EventAttach,
+				ObservedAt: start,
+				Id:         consoleId,
+			},
+			Spec: events.ConsoleAttachSpec{Username: "bob@example.com", Collaborator: true},
+		}
+	})
+
+	expectAttach := func(event Event) {
+		Expect(event.Kind).To(Equal(events.KindConsole))
+		Expect(event.Event).To(Equal(events.EventAttach))
+		Expect(event.Id).To(Equal(consoleId))
+		Expect(event.ObservedAt.Equal(start)).To(BeTrue())
+
+		var spec events.ConsoleAttachSpec
+		Expect(json.Unmarshal(event.Spec, &spec)).To(Succeed())
+		Expect(spec).To(Equal(attach.Spec))
+	}
+
+	It("decodes theatre events", func() {
+		body, err := json.Marshal(attach)
+		Expect(err).NotTo(HaveOccurred())
+
+		event, err := Decode(body)
+		Expect(err).NotTo(HaveOccurred())
+		expectAttach(event)
+	})
+
+	It("decodes structured CloudEvents", func() {
+		cloudEvent, err := events.NewCloudEvent("prod", attach)
+		Expect(err).NotTo(HaveOccurred())
+		body, err := json.Marshal(cloudEvent)
+		Expect(err).NotTo(HaveOccurred())
+
+		event, err := Decode(body)
+		Expect(err).NotTo(HaveOccurred())
+		expectAttach(event)
+	})
+
+	It("decodes binary CloudEvents from their attributes", func() {
+		cloudEvent, err := events.NewCloudEvent("prod", attach)
+		Expect(err).NotTo(HaveOccurred())
+
+		headers := map[string]string{"Content-Type": "application/json"}
+		for name, value := range cloudEvent.Attributes() {
+			headers["Ce-"+name] = value
+		}
+
+		event, err := DecodeMessage(headers, "ce-", cloudEvent.Data)
+		Expect(err).NotTo(HaveOccurred())
+		expectAttach(event)
+	})
+
+	It("rejects JSON that isn't an event", func() {
+		_, err := Decode([]byte(`{"hello": "world"}`))
+		Expect(err).To(MatchError(ContainSubstring("not a theatre event")))
+	})
+
+	It("rejects CloudEvents from other sources", func() {
+		_, err := Decode([]byte(`{"specversion": "1.0", "type": "com.example.thing", "time": "2024-01-02T12:00:00Z"}`))
+		Expect(err).To(MatchError(ContainSubstring("not a theatre CloudEvent")))
+	})
+})
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+const consoleId = "20240102120000/prod/payments/console-abcde"
+
+var start = time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+// newEvent builds an event of the console observed the given number of
+// seconds after start, with the spec encoded as the publisher would
+func newEvent(eventKind events.EventKind, seconds int, spec interface{}) Event {
+	body, err := json.Marshal(spec)
+	Expect(err).NotTo(HaveOccurred())
+
+	return Event{
+		CommonEvent: events.CommonEvent{
+			Version:    "v1alpha1",
+			Kind:       events.KindConsole,
+			Event:      eventKind,
+			ObservedAt: start.Add(time.Duration(seconds) * time.Second),
+			Id:         consoleId,
+		},
+		Spec: body,
+	}
+}
+
+func requestEvent(seconds int, requiredAuthorisations int) Event {
+	return newEvent(events.EventRequest, seconds, events.ConsoleRequestSpec{
+		Username:               "alice@example.com",
+		Reason:                 "debugging",
+		Context:                "prod",
+		Namespace:              "payments",
+		Console:                "console-abcde",
+		ConsoleTemplate:        "payments-console",
+		RequiredAuthorisations: requiredAuthorisations,
+	})
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// Filter returns the timelines of consoles with the given name, or any if
+// empty, optionally only those with anomalies
+func Filter(timelines []Timeline, console string, anomaliesOnly bool) []Timeline {
+	filtered := []Timeline{}
+	for _, timeline := range timelines {
+		if console != "" && timeline.Console != console {
+			continue
+		}
+		if anomaliesOnly && len(timeline.Anomalies) == 0 {
+			continue
+		}
+		filtered = append(filtered, timeline)
+	}
+
+	return filtered
+}
+
+// PrintReport writes the timelines to the output in the given format. The
+// table shows a row per console, followed by a list of the anomalies found,
+// while JSON includes every event.
+func PrintReport(output io.Writer, format string, timelines []Timeline) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(timelines)
+	case OutputTable, "":
+		return printTable(output, timelines)
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+func printTable(output io.Writer, timelines []Timeline) error {
+	if len(timelines) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tNAMESPACE\tCONSOLE\tUSER\tREQUESTED\tSTARTED\tTERMINATED\tAUTHORISERS\tATTACHERS\tANOMALIES")
+	for _, t := range timelines {
+		terminated := formatTime(t.TerminatedAt)
+		if t.TerminateReason != "" {
+			terminated = fmt.Sprintf("%s (%s)", terminated, t.TerminateReason)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			t.Context, t.Namespace, t.Console, orNone(t.User),
+			formatTime(t.RequestedAt), formatTime(t.StartedAt), terminated,
+			orNone(strings.Join(t.Authorisers, ",")), orNone(strings.Join(t.Attachers, ",")),
+			len(t.Anomalies),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	header := false
+	for _, t := range timelines {
+		for _, anomaly := range t.Anomalies {
+			if !header {
+				fmt.Fprintln(output, "\nAnomalies:")
+				header = true
+			}
+			fmt.Fprintf(output, "  %s/%s %s %s: %s\n",
+				t.Namespace, t.Console, anomaly.ObservedAt.UTC().Format(time.RFC3339), anomaly.Type, anomaly.Message)
+		}
+	}
+
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "<none>"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+
+	return s
+}
@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/go-logr/logr"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+// maxEventSize is the largest event that will be read
+const maxEventSize = 1024 * 1024
+
+// ReadJSONL reads events from a file with one event per line, skipping blank
+// lines
+func ReadJSONL(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+
+	evs := []Event{}
+	for line := 1; scanner.Scan(); line++ {
+		body := bytes.TrimSpace(scanner.Bytes())
+		if len(body) == 0 {
+			continue
+		}
+
+		event, err := Decode(body)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		evs = append(evs, event)
+	}
+
+	return evs, scanner.Err()
+}
+
+// Collector accumulates events received concurrently, until it is closed
+type Collector struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+}
+
+// Add collects the event, returning whether it was collected, which it isn't
+// once the collector is closed
+func (c *Collector) Add(event Event) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.events = append(c.events, event)
+
+	return true
+}
+
+// Events returns the events received so far
+func (c *Collector) Events() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Event{}, c.events...)
+}
+
+// Close stops further events being collected, returning those that were
+func (c *Collector) Close() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	return append([]Event{}, c.events...)
+}
+
+// Receiver is an http.Handler that receives events POSTed by the
+// events.HTTPPublisher, verifying their signature if given a signing secret
+type Receiver struct {
+	Collector     *Collector
+	SigningSecret string
+	Logger        logr.Logger
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxEventSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if r.SigningSecret != "" {
+		signature := req.Header.Get(events.HTTPSignatureHeader)
+		if !hmac.Equal([]byte(signature), []byte(events.Sign(r.SigningSecret, body))) {
+			r.Logger.Info("rejected event with invalid signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	headers := map[string]string{}
+	for name := range req.Header {
+		headers[name] = req.Header.Get(name)
+	}
+
+	event, err := DecodeMessage(headers, "ce-", body)
+	if err != nil {
+		// The publisher won't retry a 4xx, which is what we want for an event we
+		// will never understand
+		r.Logger.Error(err, "failed to decode event")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !r.Collector.Add(event) {
+		// The publisher retries this, for whatever receives events next
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReceivePubSub pulls events from a Pub/Sub subscription until the context is
+// cancelled, then passes them to report. Messages are only acknowledged, which
+// removes them from the subscription, once report succeeds, so it must be
+// dedicated to auditing. Otherwise they are nacked to be redelivered, as are
+// messages that can't be decoded, leaving them to the subscription's dead
+// letter policy, if it has one.
+func ReceivePubSub(ctx context.Context, logger logr.Logger, projectID, subscriptionID string, report func([]Event) error) error {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to connect to pubsub: %w", err)
+	}
+	defer client.Close()
+
+	// Messages are held until the report is written, so mustn't stop more
+	// being pulled in the meantime
+	sub := client.Subscription(subscriptionID)
+	sub.ReceiveSettings.MaxOutstandingMessages = -1
+	sub.ReceiveSettings.MaxOutstandingBytes = -1
+
+	var (
+		collector = &Collector{}
+		reported  = make(chan struct{})
+		reportErr error
+	)
+
+	// Receiving continues until every message has been acknowledged or nacked,
+	// after the report is written
+	receiveCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan error, 1)
+	go func() {
+		received <- sub.Receive(receiveCtx, func(msgCtx context.Context, msg *pubsub.Message) {
+			event, err := DecodeMessage(msg.Attributes, "ce-", msg.Data)
+			if err != nil {
+				logger.Error(err, "failed to decode event", "message", msg.ID)
+				msg.Nack()
+				return
+			}
+
+			if !collector.Add(event) {
+				msg.Nack()
+				return
+			}
+
+			// Receiving stops early on a fatal error, in which case nothing is
+			// reported
+			select {
+			case <-reported:
+			case <-msgCtx.Done():
+			}
+			select {
+			case <-reported:
+				if reportErr == nil {
+					msg.Ack()
+					return
+				}
+			default:
+			}
+			msg.Nack()
+		})
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-received:
+		return fmt.Errorf("failed to receive from pubsub: %w", err)
+	}
+
+	reportErr = report(collector.Close())
+	close(reported)
+	cancel()
+
+	if err := <-received; err != nil {
+		logger.Error(err, "failed to acknowledge events")
+	}
+
+	return reportErr
+}
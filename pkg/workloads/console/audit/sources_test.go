@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+var _ = Describe("Sources", func() {
+	Describe("ReadJSONL", func() {
+		It("reads an event from each line, skipping blank lines", func() {
+			var lines []string
+			for _, event := range []Event{requestEvent(0, 0), newEvent(events.EventStart, 10, events.ConsoleStartSpec{})} {
+				body, err := json.Marshal(event)
+				Expect(err).NotTo(HaveOccurred())
+				lines = append(lines, string(body), "")
+			}
+
+			evs, err := ReadJSONL(strings.NewReader(strings.Join(lines, "\n")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(evs).To(HaveLen(2))
+			Expect(evs[1].Event).To(Equal(events.EventStart))
+		})
+
+		It("reports the line of an invalid event", func() {
+			_, err := ReadJSONL(strings.NewReader("\n{}\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 2")))
+		})
+	})
+
+	Describe("Receiver", func() {
+		var (
+			collector *Collector
+			server    *httptest.Server
+			body      []byte
+		)
+
+		BeforeEach(func() {
+			collector = &Collector{}
+			server = httptest.NewServer(&Receiver{Collector: collector, SigningSecret: "secret", Logger: logr.Discard()})
+
+			var err error
+			body, err = json.Marshal(requestEvent(0, 0))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		post := func(signature string) int {
+			req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set(events.HTTPSignatureHeader, signature)
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			return resp.StatusCode
+		}
+
+		It("collects events with a valid signature", func() {
+			Expect(post(events.Sign("secret", body))).To(Equal(http.StatusNoContent))
+			Expect(collector.Events()).To(HaveLen(1))
+			Expect(collector.Events()[0].Event).To(Equal(events.EventRequest))
+		})
+
+		It("refuses events once the collector is closed, so they are retried", func() {
+			Expect(collector.Close()).To(BeEmpty())
+			Expect(post(events.Sign("secret", body))).To(Equal(http.StatusServiceUnavailable))
+			Expect(collector.Events()).To(BeEmpty())
+		})
+
+		It("rejects events with an invalid signature", func() {
+			Expect(post(events.Sign("wrong", body))).To(Equal(http.StatusUnauthorized))
+			Expect(collector.Events()).To(BeEmpty())
+		})
+	})
+})
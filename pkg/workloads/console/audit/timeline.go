@@ -0,0 +1,270 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+// Types of anomaly found in a console's timeline
+const (
+	// AnomalyMissingRequest means no request event was received for the
+	// console, so its owner and required authorisations are unknown
+	AnomalyMissingRequest = "MissingRequest"
+	// AnomalyUnauthorisedStart means the console started before receiving the
+	// authorisations its request required
+	AnomalyUnauthorisedStart = "UnauthorisedStart"
+	// AnomalySelfAuthorisation means the console was authorised by its owner
+	AnomalySelfAuthorisation = "SelfAuthorisation"
+	// AnomalyNonOwnerAttach means a user attached who doesn't own the console,
+	// wasn't shared it or authorise it, and isn't one of its template's
+	// additional attach subjects, such as a member of a break-glass group
+	AnomalyNonOwnerAttach = "NonOwnerAttach"
+	// AnomalyAttachBeforeStart means a user attached to a console that hadn't
+	// started
+	AnomalyAttachBeforeStart = "AttachBeforeStart"
+	// AnomalyAttachAfterTerminate means a user attached to a console that had
+	// terminated
+	AnomalyAttachAfterTerminate = "AttachAfterTerminate"
+	// AnomalyAttachDenied means the attach webhook denied a user attaching to,
+	// or running a command in, the console
+	AnomalyAttachDenied = "AttachDenied"
+)
+
+// Anomaly is something unexpected in a console's timeline, which may warrant
+// investigation
+type Anomaly struct {
+	Type       string    `json:"type"`
+	Message    string    `json:"message"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// Timeline is the lifecycle of a single console, reassembled from its events
+type Timeline struct {
+	// Id shared by all the console's events
+	Id        string `json:"id"`
+	Context   string `json:"context,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Console   string `json:"console,omitempty"`
+	// Details of the console's request, unset if it wasn't received
+	User                   string `json:"user,omitempty"`
+	Reason                 string `json:"reason,omitempty"`
+	ConsoleTemplate        string `json:"console_template,omitempty"`
+	RequiredAuthorisations int    `json:"required_authorisations"`
+
+	RequestedAt     *time.Time             `json:"requested_at,omitempty"`
+	StartedAt       *time.Time             `json:"started_at,omitempty"`
+	TerminatedAt    *time.Time             `json:"terminated_at,omitempty"`
+	TerminateReason events.TerminateReason `json:"terminate_reason,omitempty"`
+
+	Authorisers []string `json:"authorisers"`
+	Attachers   []string `json:"attachers"`
+
+	Events    []Event   `json:"events"`
+	Anomalies []Anomaly `json:"anomalies"`
+}
+
+// Build groups events by console into timelines, ordered by when the first
+// event of each console was observed. Events in each timeline are ordered by
+// when they were observed, which is also the order anomalies are checked in.
+// Events received more than once are only included once.
+func Build(evs []Event) ([]Timeline, error) {
+	byId := map[string]*Timeline{}
+	ids := []string{}
+	for _, event := range Dedupe(evs) {
+		if event.Kind != events.KindConsole {
+			continue
+		}
+
+		timeline, ok := byId[event.Id]
+		if !ok {
+			timeline = newTimeline(event.Id)
+			byId[event.Id] = timeline
+			ids = append(ids, event.Id)
+		}
+		timeline.Events = append(timeline.Events, event)
+	}
+
+	timelines := []Timeline{}
+	for _, id := range ids {
+		timeline := byId[id]
+		if err := timeline.replay(); err != nil {
+			return nil, err
+		}
+		timelines = append(timelines, *timeline)
+	}
+
+	sort.SliceStable(timelines, func(i, j int) bool {
+		return timelines[i].Events[0].ObservedAt.Before(timelines[j].Events[0].ObservedAt)
+	})
+
+	return timelines, nil
+}
+
+// Dedupe removes events received more than once, such as those redelivered by
+// Pub/Sub or retried by the HTTP publisher, keeping the first of each. Events
+// are identified by their id, which all events of a console share, their kind
+// and event, and when they were observed.
+func Dedupe(evs []Event) []Event {
+	seen := map[string]bool{}
+	deduped := []Event{}
+	for _, event := range evs {
+		key := fmt.Sprintf("%s/%s/%s/%d", event.Id, event.Kind, event.Event, event.ObservedAt.UnixNano())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, event)
+	}
+
+	return deduped
+}
+
+// newTimeline creates a timeline with the context, namespace and console taken
+// from an id built by events.NewConsoleEventID. These are replaced by those of
+// the request event, if it is received.
+func newTimeline(id string) *Timeline {
+	timeline := &Timeline{Id: id, Authorisers: []string{}, Attachers: []string{}, Anomalies: []Anomaly{}}
+
+	// The context may contain slashes, but the namespace and console can't
+	parts := strings.Split(id, "/")
+	if len(parts) >= 4 {
+		timeline.Context = strings.Join(parts[1:len(parts)-2], "/")
+		timeline.Namespace = parts[len(parts)-2]
+		timeline.Console = parts[len(parts)-1]
+	}
+
+	return timeline
+}
+
+// replay orders the timeline's events, and steps through them to fill in the
+// timeline and find anomalies
+func (t *Timeline) replay() error {
+	sort.SliceStable(t.Events, func(i, j int) bool {
+		return t.Events[i].ObservedAt.Before(t.Events[j].ObservedAt)
+	})
+
+	var request *events.ConsoleRequestSpec
+	for _, event := range t.Events {
+		if event.Event == events.EventRequest {
+			request = &events.ConsoleRequestSpec{}
+			if err := decodeSpec(event, request); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	if request == nil {
+		t.flag(AnomalyMissingRequest, t.Events[0].ObservedAt, "no request event was received")
+	} else {
+		t.Context, t.Namespace, t.Console = request.Context, request.Namespace, request.Console
+		t.User, t.Reason, t.ConsoleTemplate = request.Username, request.Reason, request.ConsoleTemplate
+		t.RequiredAuthorisations = request.RequiredAuthorisations
+	}
+
+	for _, event := range t.Events {
+		observedAt := event.ObservedAt
+
+		switch event.Event {
+		case events.EventRequest:
+			if t.RequestedAt == nil {
+				t.RequestedAt = &observedAt
+			}
+		case events.EventAuthorise:
+			var spec events.ConsoleAuthoriseSpec
+			if err := decodeSpec(event, &spec); err != nil {
+				return err
+			}
+			if request != nil && spec.Username == request.Username {
+				t.flag(AnomalySelfAuthorisation, observedAt, "authorised by its owner %s", spec.Username)
+			}
+			t.Authorisers = appendUnique(t.Authorisers, spec.Username)
+		case events.EventStart:
+			if t.StartedAt == nil {
+				t.StartedAt = &observedAt
+			}
+			if request != nil && len(t.Authorisers) < request.RequiredAuthorisations {
+				t.flag(AnomalyUnauthorisedStart, observedAt, "started with %d of %d required authorisations",
+					len(t.Authorisers), request.RequiredAuthorisations)
+			}
+		case events.EventAttach:
+			var spec events.ConsoleAttachSpec
+			if err := decodeSpec(event, &spec); err != nil {
+				return err
+			}
+			// Attach events published before attach subjects were recorded only
+			// say whether the user was a collaborator, so authorisers are also
+			// recognised from their authorise events
+			sanctioned := spec.Collaborator || spec.AttachSubject || contains(t.Authorisers, spec.Username)
+			if request != nil && spec.Username != request.Username && !sanctioned {
+				t.flag(AnomalyNonOwnerAttach, observedAt, "%s attached, but doesn't own the console and wasn't granted access to it", spec.Username)
+			}
+			if t.StartedAt == nil {
+				t.flag(AnomalyAttachBeforeStart, observedAt, "%s attached before the console started", spec.Username)
+			}
+			if t.TerminatedAt != nil {
+				t.flag(AnomalyAttachAfterTerminate, observedAt, "%s attached after the console terminated", spec.Username)
+			}
+			t.Attachers = appendUnique(t.Attachers, spec.Username)
+		case events.EventAttachDenied:
+			var spec events.ConsoleAttachDeniedSpec
+			if err := decodeSpec(event, &spec); err != nil {
+				return err
+			}
+			t.flag(AnomalyAttachDenied, observedAt, "%s was denied %s: %s", spec.Username, spec.Subresource, spec.Reason)
+		case events.EventTerminated:
+			var spec events.ConsoleTerminatedSpec
+			if err := decodeSpec(event, &spec); err != nil {
+				return err
+			}
+			if t.TerminatedAt == nil {
+				t.TerminatedAt = &observedAt
+				t.TerminateReason = spec.Reason
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *Timeline) flag(anomalyType string, observedAt time.Time, format string, args ...interface{}) {
+	t.Anomalies = append(t.Anomalies, Anomaly{
+		Type:       anomalyType,
+		Message:    fmt.Sprintf(format, args...),
+		ObservedAt: observedAt,
+	})
+}
+
+func decodeSpec(event Event, spec interface{}) error {
+	if len(event.Spec) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(event.Spec, spec); err != nil {
+		return fmt.Errorf("failed to decode %s event of %s: %w", event.Event, event.Id, err)
+	}
+
+	return nil
+}
+
+func appendUnique(list []string, item string) []string {
+	if contains(list, item) {
+		return list
+	}
+
+	return append(list, item)
+}
+
+func contains(list []string, item string) bool {
+	for _, existing := range list {
+		if existing == item {
+			return true
+		}
+	}
+
+	return false
+}
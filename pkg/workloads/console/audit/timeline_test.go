@@ -0,0 +1,175 @@
+package audit
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+var _ = Describe("Build", func() {
+	var (
+		evs       []Event
+		timelines []Timeline
+		err       error
+	)
+
+	JustBeforeEach(func() {
+		timelines, err = Build(evs)
+	})
+
+	anomalyTypes := func(timeline Timeline) []string {
+		types := []string{}
+		for _, anomaly := range timeline.Anomalies {
+			types = append(types, anomaly.Type)
+		}
+		return types
+	}
+
+	Context("with a complete, authorised console", func() {
+		BeforeEach(func() {
+			// Deliberately out of order, as events can arrive in any order
+			evs = []Event{
+				newEvent(events.EventStart, 20, events.ConsoleStartSpec{Job: "console-abcde-console"}),
+				requestEvent(0, 1),
+				newEvent(events.EventAuthorise, 10, events.ConsoleAuthoriseSpec{Username: "carol@example.com"}),
+				newEvent(events.EventAttach, 30, events.ConsoleAttachSpec{Username: "alice@example.com"}),
+				newEvent(events.EventAttach, 40, events.ConsoleAttachSpec{Username: "bob@example.com", Collaborator: true}),
+				newEvent(events.EventTerminated, 50, events.ConsoleTerminatedSpec{Reason: events.TerminateCompleted}),
+			}
+		})
+
+		It("builds the timeline in order", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timelines).To(HaveLen(1))
+
+			timeline := timelines[0]
+			Expect(timeline.Id).To(Equal(consoleId))
+			Expect(timeline.Context).To(Equal("prod"))
+			Expect(timeline.Namespace).To(Equal("payments"))
+			Expect(timeline.Console).To(Equal("console-abcde"))
+			Expect(timeline.User).To(Equal("alice@example.com"))
+			Expect(timeline.Authorisers).To(Equal([]string{"carol@example.com"}))
+			Expect(timeline.Attachers).To(Equal([]string{"alice@example.com", "bob@example.com"}))
+			Expect(*timeline.RequestedAt).To(Equal(start))
+			Expect(timeline.StartedAt.Sub(start).Seconds()).To(BeEquivalentTo(20))
+			Expect(timeline.TerminatedAt.Sub(start).Seconds()).To(BeEquivalentTo(50))
+			Expect(timeline.TerminateReason).To(Equal(events.TerminateCompleted))
+
+			eventKinds := []events.EventKind{}
+			for _, event := range timeline.Events {
+				eventKinds = append(eventKinds, event.Event)
+			}
+			Expect(eventKinds).To(Equal([]events.EventKind{
+				events.EventRequest, events.EventAuthorise, events.EventStart,
+				events.EventAttach, events.EventAttach, events.EventTerminated,
+			}))
+		})
+
+		It("finds no anomalies", func() {
+			Expect(timelines[0].Anomalies).To(BeEmpty())
+		})
+	})
+
+	Context("with a console started without its authorisation", func() {
+		BeforeEach(func() {
+			evs = []Event{
+				requestEvent(0, 2),
+				newEvent(events.EventAuthorise, 10, events.ConsoleAuthoriseSpec{Username: "carol@example.com"}),
+				newEvent(events.EventStart, 20, events.ConsoleStartSpec{}),
+			}
+		})
+
+		It("flags the start", func() {
+			Expect(anomalyTypes(timelines[0])).To(Equal([]string{AnomalyUnauthorisedStart}))
+			Expect(timelines[0].Anomalies[0].Message).To(Equal("started with 1 of 2 required authorisations"))
+		})
+	})
+
+	Context("with unexpected authorisations and attaches", func() {
+		BeforeEach(func() {
+			evs = []Event{
+				requestEvent(0, 1),
+				newEvent(events.EventAuthorise, 10, events.ConsoleAuthoriseSpec{Username: "alice@example.com"}),
+				newEvent(events.EventAttach, 15, events.ConsoleAttachSpec{Username: "alice@example.com"}),
+				newEvent(events.EventStart, 20, events.ConsoleStartSpec{}),
+				newEvent(events.EventAttach, 30, events.ConsoleAttachSpec{Username: "mallory@example.com"}),
+				newEvent(events.EventAttachDenied, 35, events.ConsoleAttachDeniedSpec{Username: "mallory@example.com", Subresource: "exec"}),
+				newEvent(events.EventTerminated, 40, events.ConsoleTerminatedSpec{Reason: events.TerminateDeleted}),
+				newEvent(events.EventAttach, 50, events.ConsoleAttachSpec{Username: "alice@example.com"}),
+			}
+		})
+
+		It("flags each of them", func() {
+			Expect(anomalyTypes(timelines[0])).To(Equal([]string{
+				AnomalySelfAuthorisation,
+				AnomalyAttachBeforeStart,
+				AnomalyNonOwnerAttach,
+				AnomalyAttachDenied,
+				AnomalyAttachAfterTerminate,
+			}))
+		})
+	})
+
+	Context("with attaches by users granted access to the console", func() {
+		BeforeEach(func() {
+			evs = []Event{
+				requestEvent(0, 1),
+				newEvent(events.EventAuthorise, 10, events.ConsoleAuthoriseSpec{Username: "carol@example.com"}),
+				newEvent(events.EventStart, 20, events.ConsoleStartSpec{}),
+				newEvent(events.EventAttach, 30, events.ConsoleAttachSpec{Username: "carol@example.com"}),
+				newEvent(events.EventAttach, 40, events.ConsoleAttachSpec{Username: "oncall@example.com", AttachSubject: true}),
+			}
+		})
+
+		It("doesn't flag the authoriser or attach subject", func() {
+			Expect(timelines[0].Anomalies).To(BeEmpty())
+			Expect(timelines[0].Attachers).To(Equal([]string{"carol@example.com", "oncall@example.com"}))
+		})
+	})
+
+	Context("without a request event", func() {
+		BeforeEach(func() {
+			evs = []Event{
+				newEvent(events.EventAttach, 30, events.ConsoleAttachSpec{Username: "mallory@example.com"}),
+				newEvent(events.EventStart, 20, events.ConsoleStartSpec{}),
+			}
+		})
+
+		It("takes the console from its id, and flags the missing request", func() {
+			Expect(timelines[0].Context).To(Equal("prod"))
+			Expect(timelines[0].Namespace).To(Equal("payments"))
+			Expect(timelines[0].Console).To(Equal("console-abcde"))
+			Expect(anomalyTypes(timelines[0])).To(Equal([]string{AnomalyMissingRequest}))
+		})
+	})
+
+	Context("with several consoles", func() {
+		const otherId = "20240102115950/prod/payments/console-fghij"
+
+		BeforeEach(func() {
+			other := requestEvent(-10, 0)
+			other.Id = otherId
+			evs = []Event{requestEvent(0, 0), other}
+		})
+
+		It("orders them by their first event", func() {
+			Expect(timelines).To(HaveLen(2))
+			Expect(timelines[0].Id).To(Equal(otherId))
+			Expect(timelines[1].Id).To(Equal(consoleId))
+		})
+	})
+
+	Context("with redelivered events", func() {
+		BeforeEach(func() {
+			attach := newEvent(events.EventAttach, 30, events.ConsoleAttachSpec{Username: "alice@example.com"})
+			evs = []Event{requestEvent(0, 0), attach, requestEvent(0, 0), attach}
+		})
+
+		It("includes each event once", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timelines).To(HaveLen(1))
+			Expect(timelines[0].Events).To(HaveLen(2))
+		})
+	})
+})
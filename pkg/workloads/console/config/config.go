@@ -0,0 +1,208 @@
+// Package config loads the theatre-consoles configuration file, which holds
+// defaults for the command line flags and named aliases for console templates.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the contents of the configuration file, for example:
+//
+//	context: prod
+//	namespace: payments
+//	defaults:
+//	  timeout: 1h
+//	aliases:
+//	  prod-payments:
+//	    selector: app=payments-service,environment=production
+//	    namespace: payments
+//	    reason: Investigating a payment
+//	    attach: true
+type Config struct {
+	// Default kubernetes context and namespace
+	Context   string `yaml:"context,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Defaults for the options of commands that create consoles
+	Defaults Defaults `yaml:"defaults,omitempty"`
+
+	// Named aliases for console templates, which can be given in place of a
+	// selector
+	Aliases map[string]Alias `yaml:"aliases,omitempty"`
+}
+
+// Defaults are the default options for commands that create consoles
+type Defaults struct {
+	Reason  string   `yaml:"reason,omitempty"`
+	Timeout Duration `yaml:"timeout,omitempty"`
+	Attach  *bool    `yaml:"attach,omitempty"`
+}
+
+// Alias names a console template, by the context, namespace and selector that
+// find it, along with defaults for consoles created from it
+type Alias struct {
+	Context   string `yaml:"context,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Selector  string `yaml:"selector"`
+	Defaults  `yaml:",inline"`
+}
+
+// Duration is a time.Duration that is written as a string, such as 30m
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// DefaultPath returns the path of the configuration file:
+// $XDG_CONFIG_HOME/theatre/consoles.yaml, or ~/.config/theatre/consoles.yaml
+// if XDG_CONFIG_HOME is unset
+func DefaultPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(configHome, "theatre", "consoles.yaml"), nil
+}
+
+// Load reads the configuration file at the given path. If the file doesn't
+// exist and optional is true, an empty configuration is returned.
+func Load(path string, optional bool) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse parses and validates the contents of a configuration file. Unknown
+// fields are rejected, so that typos don't go unnoticed.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration file: %w", err)
+	}
+
+	for name, alias := range cfg.Aliases {
+		if alias.Selector == "" {
+			return nil, fmt.Errorf("invalid configuration file: alias %s has no selector", name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Options are the options that can be given by flags, environment variables,
+// an alias or the configuration file's defaults. Empty values are unset.
+type Options struct {
+	Context   string
+	Namespace string
+	Selector  string
+	Reason    string
+	Timeout   time.Duration
+	Attach    *bool
+}
+
+// HasAlias returns whether an alias with the given name is configured
+func (c *Config) HasAlias(name string) bool {
+	_, ok := c.Aliases[name]
+	return ok
+}
+
+// Resolve fills in any options that weren't given, which come from flags and
+// environment variables, first from the named alias, if any, and then from
+// the file's defaults. The order of precedence is therefore:
+//
+//  1. command line flags
+//  2. environment variables
+//  3. the alias
+//  4. the configuration file's defaults
+func (c *Config) Resolve(aliasName string, given Options) (Options, error) {
+	resolved := given
+
+	if aliasName != "" {
+		alias, ok := c.Aliases[aliasName]
+		if !ok {
+			return Options{}, fmt.Errorf("unknown alias %s, expected one of: %s", aliasName, strings.Join(c.aliasNames(), ", "))
+		}
+
+		resolved = merge(resolved, Options{
+			Context:   alias.Context,
+			Namespace: alias.Namespace,
+			Selector:  alias.Selector,
+			Reason:    alias.Reason,
+			Timeout:   time.Duration(alias.Timeout),
+			Attach:    alias.Attach,
+		})
+	}
+
+	return merge(resolved, Options{
+		Context:   c.Context,
+		Namespace: c.Namespace,
+		Reason:    c.Defaults.Reason,
+		Timeout:   time.Duration(c.Defaults.Timeout),
+		Attach:    c.Defaults.Attach,
+	}), nil
+}
+
+func (c *Config) aliasNames() []string {
+	names := []string{}
+	for name := range c.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// merge sets any unset options from the fallback
+func merge(opts, fallback Options) Options {
+	if opts.Context == "" {
+		opts.Context = fallback.Context
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = fallback.Namespace
+	}
+	if opts.Selector == "" {
+		opts.Selector = fallback.Selector
+	}
+	if opts.Reason == "" {
+		opts.Reason = fallback.Reason
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = fallback.Timeout
+	}
+	if opts.Attach == nil {
+		opts.Attach = fallback.Attach
+	}
+
+	return opts
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	const data = `
+context: staging
+namespace: default
+defaults:
+  reason: Investigating
+  timeout: 1h
+aliases:
+  prod-payments:
+    context: prod
+    namespace: payments
+    selector: app=payments-service,environment=production
+    timeout: 30m
+    attach: true
+`
+
+	var cfg *Config
+
+	BeforeEach(func() {
+		var err error
+		cfg, err = Parse([]byte(data))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Parse", func() {
+		It("parses aliases and defaults", func() {
+			Expect(cfg.Context).To(Equal("staging"))
+			Expect(time.Duration(cfg.Defaults.Timeout)).To(Equal(time.Hour))
+			Expect(cfg.HasAlias("prod-payments")).To(BeTrue())
+			Expect(cfg.Aliases["prod-payments"].Selector).To(Equal("app=payments-service,environment=production"))
+			Expect(*cfg.Aliases["prod-payments"].Attach).To(BeTrue())
+		})
+
+		It("rejects unknown fields", func() {
+			_, err := Parse([]byte("namespce: payments\n"))
+			Expect(err).To(MatchError(ContainSubstring("namespce")))
+		})
+
+		It("rejects invalid durations", func() {
+			_, err := Parse([]byte("defaults:\n  timeout: an hour\n"))
+			Expect(err).To(MatchError(ContainSubstring("invalid duration")))
+		})
+
+		It("rejects aliases without a selector", func() {
+			_, err := Parse([]byte("aliases:\n  foo:\n    namespace: foo\n"))
+			Expect(err).To(MatchError(ContainSubstring("alias foo has no selector")))
+		})
+	})
+
+	Describe("Resolve", func() {
+		It("uses the alias, then the defaults, for options that weren't given", func() {
+			opts, err := cfg.Resolve("prod-payments", Options{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(opts.Context).To(Equal("prod"))
+			Expect(opts.Namespace).To(Equal("payments"))
+			Expect(opts.Selector).To(Equal("app=payments-service,environment=production"))
+			Expect(opts.Timeout).To(Equal(30 * time.Minute))
+			Expect(opts.Reason).To(Equal("Investigating"))
+			Expect(*opts.Attach).To(BeTrue())
+		})
+
+		It("prefers the options that were given", func() {
+			attach := false
+			opts, err := cfg.Resolve("prod-payments", Options{Namespace: "other", Timeout: time.Minute, Attach: &attach})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(opts.Namespace).To(Equal("other"))
+			Expect(opts.Timeout).To(Equal(time.Minute))
+			Expect(*opts.Attach).To(BeFalse())
+		})
+
+		It("uses the defaults without an alias", func() {
+			opts, err := cfg.Resolve("", Options{Selector: "app=foo"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(opts.Context).To(Equal("staging"))
+			Expect(opts.Namespace).To(Equal("default"))
+			Expect(opts.Selector).To(Equal("app=foo"))
+			Expect(opts.Attach).To(BeNil())
+		})
+
+		It("rejects unknown aliases", func() {
+			_, err := cfg.Resolve("prod-payment", Options{})
+			Expect(err).To(MatchError("unknown alias prod-payment, expected one of: prod-payments"))
+		})
+	})
+
+	Describe("Load", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "theatre-config")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("returns an empty configuration for a missing optional file", func() {
+			cfg, err := Load(filepath.Join(dir, "consoles.yaml"), true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Aliases).To(BeEmpty())
+		})
+
+		It("fails for a missing file that isn't optional", func() {
+			_, err := Load(filepath.Join(dir, "consoles.yaml"), false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("DefaultPath", func() {
+		It("respects XDG_CONFIG_HOME", func() {
+			original, set := os.LookupEnv("XDG_CONFIG_HOME")
+			defer func() {
+				if set {
+					os.Setenv("XDG_CONFIG_HOME", original)
+				} else {
+					os.Unsetenv("XDG_CONFIG_HOME")
+				}
+			}()
+
+			os.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+			Expect(DefaultPath()).To(Equal("/tmp/config/theatre/consoles.yaml"))
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formats in which events can be published
+const (
+	// FormatTheatre publishes the event structs as they are
+	FormatTheatre = "theatre"
+	// FormatCloudEventsStructured publishes a CloudEvents 1.0 JSON document,
+	// with the event's spec as its data
+	FormatCloudEventsStructured = "cloudevents-structured"
+	// FormatCloudEventsBinary publishes the event's spec, with the CloudEvents
+	// attributes in the headers or attributes of the message
+	FormatCloudEventsBinary = "cloudevents-binary"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsTypePrefix is followed by the kind and event, such as
+	// com.gocardless.theatre.console.request
+	CloudEventsTypePrefix = "com.gocardless.theatre."
+	// CloudEventsContentType is the content type of a structured mode event
+	CloudEventsContentType = "application/cloudevents+json"
+)
+
+// CloudEvent is a theatre event encoded as a CloudEvents 1.0 event. When
+// marshalled to JSON, it is a structured mode event.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	// PartitionKey is the id of the theatre event, which is shared by all the
+	// events of a console. It is the partitioning extension attribute.
+	PartitionKey string `json:"partitionkey,omitempty"`
+}
+
+// Attributes returns the context attributes of the event, by name, for use in
+// binary mode
+func (e CloudEvent) Attributes() map[string]string {
+	attributes := map[string]string{
+		"specversion": e.SpecVersion,
+		"id":          e.ID,
+		"source":      e.Source,
+		"type":        e.Type,
+		"time":        e.Time.UTC().Format(time.RFC3339Nano),
+	}
+	if e.Subject != "" {
+		attributes["subject"] = e.Subject
+	}
+	if e.PartitionKey != "" {
+		attributes["partitionkey"] = e.PartitionKey
+	}
+
+	return attributes
+}
+
+// BinaryCloudEvent is a CloudEvent to be published in binary mode, where the
+// message body is the event data and the attributes are sent alongside it
+type BinaryCloudEvent struct {
+	CloudEvent
+}
+
+// NewCloudEvent encodes one of the theatre event structs as a CloudEvent. The
+// source is the cluster context that the event came from.
+func NewCloudEvent(source string, msg interface{}) (CloudEvent, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return CloudEvent{}, err
+	}
+
+	var event struct {
+		CommonEvent
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return CloudEvent{}, err
+	}
+	if event.Kind == "" || event.Event == "" {
+		return CloudEvent{}, fmt.Errorf("not a theatre event: %T", msg)
+	}
+
+	data := event.Spec
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              uniqueEventID(event.CommonEvent),
+		Source:          source,
+		Type:            CloudEventsTypePrefix + strings.ToLower(string(event.Kind)) + "." + strings.ToLower(string(event.Event)),
+		Subject:         consoleSubject(event.Id),
+		Time:            event.ObservedAt,
+		DataContentType: "application/json",
+		Data:            data,
+		PartitionKey:    event.Id,
+	}, nil
+}
+
+// consoleSubject returns the namespace/console from an id built by
+// NewConsoleEventID. The context may contain slashes, but the namespace and
+// console name can't.
+func consoleSubject(id string) string {
+	parts := strings.Split(id, "/")
+	if len(parts) < 4 {
+		return ""
+	}
+
+	return strings.Join(parts[len(parts)-2:], "/")
+}
+
+// CloudEventsPublisher wraps a Publisher, encoding each event as a CloudEvent
+type CloudEventsPublisher struct {
+	publisher Publisher
+	source    string
+	binary    bool
+}
+
+// Test we implement the Publisher interface
+var _ Publisher = &CloudEventsPublisher{}
+
+// NewCloudEventsPublisher wraps the publisher to publish events in the given
+// format, one of FormatCloudEventsStructured or FormatCloudEventsBinary. The
+// source is required, as CloudEvents are only unique by their source and id.
+func NewCloudEventsPublisher(publisher Publisher, source, format string) (*CloudEventsPublisher, error) {
+	if source == "" {
+		return nil, errors.New("no CloudEvents source was provided")
+	}
+
+	switch format {
+	case FormatCloudEventsStructured, FormatCloudEventsBinary:
+	default:
+		return nil, fmt.Errorf("unsupported CloudEvents format: %s", format)
+	}
+
+	return &CloudEventsPublisher{
+		publisher: publisher,
+		source:    source,
+		binary:    format == FormatCloudEventsBinary,
+	}, nil
+}
+
+func (p *CloudEventsPublisher) Publish(ctx context.Context, msg interface{}) (string, error) {
+	event, err := NewCloudEvent(p.source, msg)
+	if err != nil {
+		return "", err
+	}
+
+	if p.binary {
+		return p.publisher.Publish(ctx, BinaryCloudEvent{event})
+	}
+
+	return p.publisher.Publish(ctx, event)
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingPublisher records the messages it is given
+type recordingPublisher struct {
+	messages []interface{}
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, msg interface{}) (string, error) {
+	p.messages = append(p.messages, msg)
+	return "id", nil
+}
+
+var _ = Describe("CloudEvents", func() {
+	var (
+		observedAt time.Time
+		event      ConsoleAuthoriseEvent
+	)
+
+	BeforeEach(func() {
+		observedAt = time.Date(2022, 1, 1, 12, 30, 0, 0, time.UTC)
+		event = ConsoleAuthoriseEvent{
+			CommonEvent: CommonEvent{
+				Version:    "v1alpha1",
+				Kind:       KindConsole,
+				Event:      EventAuthorise,
+				ObservedAt: observedAt,
+				Id:         NewConsoleEventID("arn:aws:eks:eu-west-1:123:cluster/prod", "payments", "console-abcde", observedAt),
+			},
+			Spec: ConsoleAuthoriseSpec{Username: "alice@example.com"},
+		}
+	})
+
+	Describe("NewCloudEvent", func() {
+		It("maps the theatre event to CloudEvents attributes", func() {
+			ce, err := NewCloudEvent("prod", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ce.SpecVersion).To(Equal("1.0"))
+			Expect(ce.Type).To(Equal("com.gocardless.theatre.console.authorise"))
+			Expect(ce.Source).To(Equal("prod"))
+			Expect(ce.Subject).To(Equal("payments/console-abcde"))
+			Expect(ce.Time).To(Equal(observedAt))
+			Expect(ce.DataContentType).To(Equal("application/json"))
+			Expect(ce.PartitionKey).To(Equal(event.Id))
+			Expect(string(ce.Data)).To(MatchJSON(`{"username":"alice@example.com"}`))
+		})
+
+		It("gives each event of a console a different id", func() {
+			authorise, err := NewCloudEvent("prod", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			event.Event = EventStart
+			start, err := NewCloudEvent("prod", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			event.Event = EventAuthorise
+			event.ObservedAt = observedAt.Add(time.Second)
+			later, err := NewCloudEvent("prod", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(authorise.ID).NotTo(Equal(start.ID))
+			Expect(authorise.ID).NotTo(Equal(later.ID))
+			Expect(authorise.PartitionKey).To(Equal(start.PartitionKey))
+		})
+
+		It("rejects messages that aren't theatre events", func() {
+			_, err := NewCloudEvent("prod", map[string]string{"foo": "bar"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CloudEventsPublisher", func() {
+		var inner *recordingPublisher
+
+		BeforeEach(func() {
+			inner = &recordingPublisher{}
+		})
+
+		It("publishes structured mode events", func() {
+			publisher, err := NewCloudEventsPublisher(inner, "prod", FormatCloudEventsStructured)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = publisher.Publish(context.Background(), event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inner.messages).To(ConsistOf(BeAssignableToTypeOf(CloudEvent{})))
+		})
+
+		It("publishes binary mode events", func() {
+			publisher, err := NewCloudEventsPublisher(inner, "prod", FormatCloudEventsBinary)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = publisher.Publish(context.Background(), event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inner.messages).To(ConsistOf(BeAssignableToTypeOf(BinaryCloudEvent{})))
+		})
+
+		It("rejects unknown formats", func() {
+			_, err := NewCloudEventsPublisher(inner, "prod", FormatTheatre)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects an empty source", func() {
+			_, err := NewCloudEventsPublisher(inner, "", FormatCloudEventsStructured)
+			Expect(err).To(MatchError("no CloudEvents source was provided"))
+		})
+	})
+
+	Describe("publishing over HTTP", func() {
+		var (
+			server  *httptest.Server
+			headers http.Header
+			body    map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			headers, body = nil, nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		publish := func(format string) {
+			httpPublisher, err := NewHTTPPublisher(HTTPPublisherOptions{URL: server.URL, Timeout: time.Second})
+			Expect(err).NotTo(HaveOccurred())
+			publisher, err := NewCloudEventsPublisher(httpPublisher, "prod", format)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = publisher.Publish(context.Background(), event)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("sends structured mode events as a CloudEvents document", func() {
+			publish(FormatCloudEventsStructured)
+
+			Expect(headers.Get("Content-Type")).To(Equal("application/cloudevents+json"))
+			Expect(headers.Get("ce-type")).To(BeEmpty())
+			Expect(body).To(HaveKeyWithValue("specversion", "1.0"))
+			Expect(body).To(HaveKeyWithValue("type", "com.gocardless.theatre.console.authorise"))
+			Expect(body).To(HaveKeyWithValue("data", HaveKeyWithValue("username", "alice@example.com")))
+		})
+
+		It("sends binary mode events with attributes in headers", func() {
+			publish(FormatCloudEventsBinary)
+
+			Expect(headers.Get("Content-Type")).To(Equal("application/json"))
+			Expect(headers.Get("ce-specversion")).To(Equal("1.0"))
+			Expect(headers.Get("ce-type")).To(Equal("com.gocardless.theatre.console.authorise"))
+			Expect(headers.Get("ce-source")).To(Equal("prod"))
+			Expect(headers.Get("ce-subject")).To(Equal("payments/console-abcde"))
+			Expect(headers.Get("ce-time")).To(Equal("2022-01-01T12:30:00Z"))
+			Expect(body).To(Equal(map[string]interface{}{"username": "alice@example.com"}))
+		})
+	})
+
+	Describe("publishing to Kafka", func() {
+		It("sends binary mode events with attributes in headers, keyed by console", func() {
+			writer := &fakeWriter{}
+			kafkaPublisher := &KafkaPublisher{writer: writer, topic: "console-events"}
+
+			publisher, err := NewCloudEventsPublisher(kafkaPublisher, "prod", FormatCloudEventsBinary)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = publisher.Publish(context.Background(), event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(writer.messages).To(HaveLen(1))
+			Expect(string(writer.messages[0].Key)).To(Equal(event.Id))
+
+			headers := map[string]string{}
+			for _, header := range writer.messages[0].Headers {
+				headers[header.Key] = string(header.Value)
+			}
+			Expect(headers).To(HaveKeyWithValue("content-type", "application/json"))
+			Expect(headers).To(HaveKeyWithValue("ce_type", "com.gocardless.theatre.console.authorise"))
+			Expect(headers).To(HaveKeyWithValue("ce_partitionkey", event.Id))
+		})
+	})
+})
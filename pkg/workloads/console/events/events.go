@@ -1,6 +1,7 @@
 package events
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 )
@@ -8,17 +9,22 @@ import (
 type Kind string
 
 const (
-	KindConsole Kind = "Console"
+	KindConsole         Kind = "Console"
+	KindConsoleTemplate Kind = "ConsoleTemplate"
 )
 
 type EventKind string
 
 const (
-	EventRequest    EventKind = "Request"
-	EventAuthorise  EventKind = "Authorise"
-	EventStart      EventKind = "Start"
-	EventAttach     EventKind = "Attach"
-	EventTerminated EventKind = "Terminate"
+	EventRequest      EventKind = "Request"
+	EventAuthorise    EventKind = "Authorise"
+	EventStart        EventKind = "Start"
+	EventAttach       EventKind = "Attach"
+	EventAttachDenied EventKind = "AttachDenied"
+	EventAttachClosed EventKind = "AttachClosed"
+	EventTerminated   EventKind = "Terminate"
+
+	EventTemplateChange EventKind = "TemplateChange"
 )
 
 type CommonEvent struct {
@@ -47,6 +53,11 @@ type ConsoleRequestSpec struct {
 	AuthorisationRuleName  string            `json:"authorisation_rule_name"`
 	Timestamp              time.Time         `json:"timestamp"`
 	Labels                 map[string]string `json:"labels"`
+	SharedWith             []string          `json:"shared_with"`
+	// Generation of the console template when the console was requested, and
+	// a hash of the pod spec of the job it would run, as ConsoleStatus
+	TemplateGeneration int64  `json:"template_generation"`
+	TemplateHash       string `json:"template_hash"`
 }
 
 type ConsoleRequestEvent struct {
@@ -76,6 +87,15 @@ type ConsoleAttachSpec struct {
 	Username  string `json:"username"`
 	Pod       string `json:"pod"`
 	Container string `json:"container"`
+	// Subresource of the pod requested, either attach or exec
+	Subresource string `json:"subresource"`
+	// Collaborator is set when the attaching user is not the console owner,
+	// but someone the owner shared the console with
+	Collaborator bool `json:"collaborator"`
+	// AttachSubject is set when the attaching user is not the console owner,
+	// but a subject of the console's RoleBinding: one of the template's
+	// additional attach subjects, an authoriser or a collaborator
+	AttachSubject bool `json:"attach_subject"`
 }
 
 type ConsoleAttachEvent struct {
@@ -83,7 +103,73 @@ type ConsoleAttachEvent struct {
 	Spec        ConsoleAttachSpec `json:"spec"`
 }
 
+type ConsoleAttachDeniedSpec struct {
+	Username    string `json:"username"`
+	Pod         string `json:"pod"`
+	Container   string `json:"container"`
+	Subresource string `json:"subresource"`
+	// Reason the attach webhook gave for denying the request
+	Reason string `json:"reason"`
+}
+
+type ConsoleAttachDeniedEvent struct {
+	CommonEvent `json:",inline"`
+	Spec        ConsoleAttachDeniedSpec `json:"spec"`
+}
+
+// ConsoleAttachClosedSpec records that an attach session can no longer be
+// open. Nothing trusted reports when a user detaches, so sessions are closed
+// when the container they attached to terminates or the console stops: the
+// user detached at some point before ClosedAt, not necessarily at it.
+type ConsoleAttachClosedSpec struct {
+	Username     string             `json:"username"`
+	Pod          string             `json:"pod"`
+	Container    string             `json:"container"`
+	Collaborator bool               `json:"collaborator"`
+	AttachedAt   time.Time          `json:"attached_at"`
+	ClosedAt     time.Time          `json:"closed_at"`
+	Reason       AttachClosedReason `json:"reason"`
+}
+
+type ConsoleAttachClosedEvent struct {
+	CommonEvent `json:",inline"`
+	Spec        ConsoleAttachClosedSpec `json:"spec"`
+}
+
+// AttachClosedReason describes why an attach session was closed
+type AttachClosedReason string
+
+const (
+	// AttachClosedContainerTerminated means the container the user attached to
+	// terminated
+	AttachClosedContainerTerminated AttachClosedReason = "ContainerTerminated"
+	// AttachClosedConsoleStopped means the console stopped
+	AttachClosedConsoleStopped AttachClosedReason = "ConsoleStopped"
+)
+
+// TerminateReason describes why a console terminated
+type TerminateReason string
+
+const (
+	// TerminateCompleted is given when the console's job completed successfully
+	TerminateCompleted TerminateReason = "Completed"
+	// TerminateFailed is given when the console's job failed, including when it
+	// reached its timeout
+	TerminateFailed TerminateReason = "Failed"
+	// TerminateStopped is given when the console stopped without being seen to
+	// run
+	TerminateStopped TerminateReason = "Stopped"
+	// TerminateExpired is given when the console expired before being authorised
+	TerminateExpired TerminateReason = "Expired"
+	// TerminateAborted is given when the console was aborted by the controller
+	TerminateAborted TerminateReason = "Aborted"
+	// TerminateDeleted is given when the console, its job or its namespace was
+	// deleted before the console terminated
+	TerminateDeleted TerminateReason = "Deleted"
+)
+
 type ConsoleTerminatedSpec struct {
+	Reason            TerminateReason   `json:"reason"`
 	TimedOut          bool              `json:"timed_out"`
 	ContainerStatuses map[string]string `json:"container_statuses"`
 	ExitCodes         map[string]int32  `json:"exit_codes"`
@@ -94,6 +180,37 @@ type ConsoleTerminatedEvent struct {
 	Spec        ConsoleTerminatedSpec `json:"spec"`
 }
 
+// TemplateFieldChange is a change to a security-relevant field of a console
+// template, with its values encoded as JSON. A value is null when the field
+// was, or has become, unset.
+type TemplateFieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+type ConsoleTemplateChangeSpec struct {
+	Username string `json:"username"`
+	// Operation is the admission operation that made the change, CREATE,
+	// UPDATE or DELETE
+	Operation       string `json:"operation"`
+	Context         string `json:"context"`
+	Namespace       string `json:"namespace"`
+	ConsoleTemplate string `json:"console_template"`
+	// Generation of the template once the change is made, or when it was
+	// deleted
+	Generation int64                 `json:"generation"`
+	Changes    []TemplateFieldChange `json:"changes"`
+	// PendingConsoles are the consoles still pending authorisation whose
+	// authorisation rules the change may alter
+	PendingConsoles []string `json:"pending_consoles"`
+}
+
+type ConsoleTemplateChangeEvent struct {
+	CommonEvent `json:",inline"`
+	Spec        ConsoleTemplateChangeSpec `json:"spec"`
+}
+
 // NewConsoleEventID creates a deterministic ID for consoles that can
 // be used to correlate events.
 func NewConsoleEventID(context, namespace, console string, time time.Time) string {
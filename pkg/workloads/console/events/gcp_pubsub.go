@@ -2,7 +2,6 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"cloud.google.com/go/pubsub"
@@ -68,12 +67,12 @@ func (p *GooglePubSubPublisher) Stop() {
 }
 
 func (p *GooglePubSubPublisher) Publish(ctx context.Context, msg interface{}) (string, error) {
-	messageBytes, err := json.Marshal(msg)
+	message, err := encode(msg)
 	if err != nil {
 		return "", ErrorPubsubFailedPublish{err: err, Topic: p.topic.ID(), Message: msg}
 	}
 
-	result := p.topic.Publish(ctx, &pubsub.Message{Data: messageBytes})
+	result := p.topic.Publish(ctx, &pubsub.Message{Data: message.Body, Attributes: message.Headers("ce-")})
 	id, err := result.Get(ctx)
 	if err != nil {
 		return "", err
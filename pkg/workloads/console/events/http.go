@@ -0,0 +1,183 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// HTTPSignatureHeader holds the hex encoded HMAC-SHA256 of the request body,
+	// prefixed with "sha256=", when a signing secret is configured
+	HTTPSignatureHeader = "X-Theatre-Signature-256"
+	// HTTPEventIdHeader holds an id unique to the event in the request body, so
+	// that receivers can de-duplicate retried requests without parsing it. This
+	// is not the id in the body, which all events of a console share.
+	HTTPEventIdHeader = "X-Theatre-Event-Id"
+)
+
+// ErrorHTTPFailedPublish provides context on the reasons that we failed to
+// publish our message
+type ErrorHTTPFailedPublish struct {
+	err     error
+	URL     string
+	Message interface{}
+}
+
+func (e ErrorHTTPFailedPublish) Unwrap() error { return e.err }
+func (e ErrorHTTPFailedPublish) Error() string {
+	return fmt.Sprintf(
+		"failed to publish message '%v' to '%s': %s",
+		e.Message,
+		e.URL,
+		e.err,
+	)
+}
+
+// Test we implement the error interface
+var _ error = &ErrorHTTPFailedPublish{}
+
+// HTTPPublisherOptions configures an HTTPPublisher
+type HTTPPublisherOptions struct {
+	// URL that each event is POSTed to
+	URL string
+	// Headers added to every request
+	Headers map[string]string
+	// SigningSecret, if set, is used to sign each request body with
+	// HMAC-SHA256, sent in the HTTPSignatureHeader
+	SigningSecret string
+	// Timeout of each attempt to send an event
+	Timeout time.Duration
+	// MaxRetries is the number of times a failed request is retried, after the
+	// first attempt
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubling for each retry up to
+	// MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxElapsed bounds the time spent on all attempts to send an event, which
+	// is often published while handling an admission request, so must finish
+	// before the API server gives up on the webhook
+	MaxElapsed time.Duration
+	// TLS configures connections to https URLs
+	TLS TLSOptions
+}
+
+// HTTPPublisher implements the publisher.Publisher interface, allowing us to
+// POST messages as JSON to an HTTP endpoint
+type HTTPPublisher struct {
+	client *http.Client
+	opts   HTTPPublisherOptions
+}
+
+// Test we implement the Publisher interface
+var _ Publisher = &HTTPPublisher{}
+
+func NewHTTPPublisher(opts HTTPPublisherOptions) (*HTTPPublisher, error) {
+	if opts.URL == "" {
+		return nil, errors.New("no URL was provided")
+	}
+
+	tlsConfig, err := opts.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &HTTPPublisher{
+		client: &http.Client{Transport: transport, Timeout: opts.Timeout},
+		opts:   opts,
+	}, nil
+}
+
+// Publish POSTs the message as JSON, retrying with backoff on connection
+// errors, 429 and 5xx responses. It returns the id unique to the event.
+func (p *HTTPPublisher) Publish(ctx context.Context, msg interface{}) (string, error) {
+	message, err := encode(msg)
+	if err != nil {
+		return "", ErrorHTTPFailedPublish{err: err, URL: p.opts.URL, Message: msg}
+	}
+
+	if p.opts.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.MaxElapsed)
+		defer cancel()
+	}
+
+	backoff := p.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := p.send(ctx, message)
+		if err == nil {
+			return message.ID, nil
+		}
+
+		if !retry || attempt >= p.opts.MaxRetries {
+			return "", ErrorHTTPFailedPublish{err: err, URL: p.opts.URL, Message: msg}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ErrorHTTPFailedPublish{err: ctx.Err(), URL: p.opts.URL, Message: msg}
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if p.opts.MaxBackoff > 0 && backoff > p.opts.MaxBackoff {
+			backoff = p.opts.MaxBackoff
+		}
+	}
+}
+
+// send makes a single attempt to POST the body, returning whether it's worth
+// retrying if it fails
+func (p *HTTPPublisher) send(ctx context.Context, message encodedMessage) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.URL, bytes.NewReader(message.Body))
+	if err != nil {
+		return false, err
+	}
+
+	for name, value := range p.opts.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range message.Headers("ce-") {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", message.ContentType)
+	if message.ID != "" {
+		req.Header.Set(HTTPEventIdHeader, message.ID)
+	}
+	if p.opts.SigningSecret != "" {
+		req.Header.Set(HTTPSignatureHeader, Sign(p.opts.SigningSecret, message.Body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected response status: %s", resp.Status)
+}
+
+// Sign returns the value of the HTTPSignatureHeader for the body. Receivers
+// should compute the same and compare it using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPPublisher", func() {
+	var (
+		ctx      context.Context
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   [][]byte
+		statuses []int
+		opts     HTTPPublisherOptions
+		event    ConsoleAuthoriseEvent
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		requests, bodies, statuses = nil, nil, nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			body, _ := io.ReadAll(r.Body)
+			requests = append(requests, r)
+			bodies = append(bodies, body)
+
+			status := http.StatusOK
+			if len(statuses) > 0 {
+				status, statuses = statuses[0], statuses[1:]
+			}
+			w.WriteHeader(status)
+		}))
+
+		opts = HTTPPublisherOptions{
+			URL:        server.URL,
+			Timeout:    time.Second,
+			MaxRetries: 2,
+			Backoff:    time.Millisecond,
+		}
+
+		event = ConsoleAuthoriseEvent{
+			CommonEvent: CommonEvent{Kind: KindConsole, Event: EventAuthorise, Id: "event-id"},
+			Spec:        ConsoleAuthoriseSpec{Username: "alice@example.com"},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	publish := func() (string, error) {
+		publisher, err := NewHTTPPublisher(opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		return publisher.Publish(ctx, event)
+	}
+
+	It("POSTs the event as JSON", func() {
+		opts.Headers = map[string]string{"Authorization": "Bearer token"}
+
+		id, err := publish()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(uniqueEventID(event.CommonEvent)))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Method).To(Equal(http.MethodPost))
+		Expect(requests[0].Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("Bearer token"))
+		Expect(requests[0].Header.Get(HTTPEventIdHeader)).To(Equal(id))
+		Expect(requests[0].Header.Get(HTTPSignatureHeader)).To(BeEmpty())
+
+		var received ConsoleAuthoriseEvent
+		Expect(json.Unmarshal(bodies[0], &received)).To(Succeed())
+		Expect(received).To(Equal(event))
+	})
+
+	It("signs the body when a secret is configured", func() {
+		opts.SigningSecret = "secret"
+
+		_, err := publish()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests[0].Header.Get(HTTPSignatureHeader)).To(Equal(Sign("secret", bodies[0])))
+		Expect(Sign("secret", bodies[0])).NotTo(Equal(Sign("other", bodies[0])))
+	})
+
+	It("retries server errors", func() {
+		statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+
+		_, err := publish()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(HaveLen(3))
+	})
+
+	It("gives up after the maximum number of retries", func() {
+		statuses = []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}
+
+		_, err := publish()
+		Expect(err).To(MatchError(ContainSubstring("500 Internal Server Error")))
+		Expect(err).To(BeAssignableToTypeOf(ErrorHTTPFailedPublish{}))
+		Expect(requests).To(HaveLen(3))
+	})
+
+	It("sends a different id for each event of the console", func() {
+		first, err := publish()
+		Expect(err).NotTo(HaveOccurred())
+
+		event.Event = EventAttach
+		second, err := publish()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first).To(HavePrefix("event-id/"))
+		Expect(second).NotTo(Equal(first))
+	})
+
+	It("stops retrying once the maximum elapsed time has passed", func() {
+		statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+		opts.Backoff = time.Second
+		opts.MaxElapsed = 50 * time.Millisecond
+
+		_, err := publish()
+		Expect(err).To(MatchError(ContainSubstring("context deadline exceeded")))
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("doesn't retry client errors", func() {
+		statuses = []int{http.StatusBadRequest}
+
+		_, err := publish()
+		Expect(err).To(HaveOccurred())
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("requires a URL", func() {
+		_, err := NewHTTPPublisher(HTTPPublisherOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with TLS", func() {
+		BeforeEach(func() {
+			server.Close()
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			opts.URL = server.URL
+			opts.MaxRetries = 0
+		})
+
+		It("verifies the server certificate", func() {
+			_, err := publish()
+			Expect(err).To(MatchError(ContainSubstring("certificate")))
+		})
+
+		It("can skip verification", func() {
+			opts.TLS.InsecureSkipVerify = true
+
+			_, err := publish()
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASL mechanisms supported by the KafkaPublisher
+const (
+	KafkaSASLPlain       = "plain"
+	KafkaSASLSCRAMSHA256 = "scram-sha-256"
+	KafkaSASLSCRAMSHA512 = "scram-sha-512"
+)
+
+// ErrorKafkaFailedPublish provides context on the reasons that we failed to
+// publish our message
+type ErrorKafkaFailedPublish struct {
+	err     error
+	Topic   string
+	Message interface{}
+}
+
+func (e ErrorKafkaFailedPublish) Unwrap() error { return e.err }
+func (e ErrorKafkaFailedPublish) Error() string {
+	return fmt.Sprintf(
+		"failed to publish message '%v' to topic '%s': %s",
+		e.Message,
+		e.Topic,
+		e.err,
+	)
+}
+
+// Test we implement the error interface
+var _ error = &ErrorKafkaFailedPublish{}
+
+// KafkaPublisherOptions configures a KafkaPublisher
+type KafkaPublisherOptions struct {
+	// Brokers used to discover the cluster
+	Brokers []string
+	Topic   string
+	// SASLMechanism is one of the KafkaSASL constants, or empty to disable SASL
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+	// TLS enables TLS connections to the brokers, configured by TLSOptions
+	TLS        bool
+	TLSOptions TLSOptions
+	// Timeout of each attempt to write an event
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts to write an event before giving up
+	MaxAttempts int
+}
+
+// messageWriter is the subset of kafka.Writer used by the KafkaPublisher
+type messageWriter interface {
+	WriteMessages(context.Context, ...kafka.Message) error
+	Close() error
+}
+
+// KafkaPublisher implements the publisher.Publisher interface, allowing us to
+// publish messages to a Kafka topic.
+//
+// Messages are keyed by the event id, which is built by NewConsoleEventID to be
+// the same for every event of a console, so that they land in one partition
+// and are consumed in order. Publish waits for the write to be acknowledged by
+// all in-sync replicas.
+type KafkaPublisher struct {
+	writer messageWriter
+	topic  string
+}
+
+// Test we implement the Publisher interface
+var _ Publisher = &KafkaPublisher{}
+
+func NewKafkaPublisher(opts KafkaPublisherOptions) (*KafkaPublisher, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, errors.New("no brokers were provided")
+	}
+	if opts.Topic == "" {
+		return nil, errors.New("no topic was provided")
+	}
+
+	transport := &kafka.Transport{}
+
+	mechanism, err := opts.saslMechanism()
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL = mechanism
+
+	if opts.TLS {
+		if transport.TLS, err = opts.TLSOptions.Config(); err != nil {
+			return nil, err
+		}
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(opts.Brokers...),
+		Topic:        opts.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  opts.MaxAttempts,
+		WriteTimeout: opts.Timeout,
+		// Events are written one at a time, so don't wait to fill a batch
+		BatchSize: 1,
+		Transport: transport,
+	}
+
+	return &KafkaPublisher{writer: writer, topic: opts.Topic}, nil
+}
+
+func (o KafkaPublisherOptions) saslMechanism() (sasl.Mechanism, error) {
+	switch o.SASLMechanism {
+	case "":
+		return nil, nil
+	case KafkaSASLPlain:
+		return plain.Mechanism{Username: o.SASLUsername, Password: o.SASLPassword}, nil
+	case KafkaSASLSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, o.SASLUsername, o.SASLPassword)
+	case KafkaSASLSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, o.SASLUsername, o.SASLPassword)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism: %s", o.SASLMechanism)
+	}
+}
+
+func (p *KafkaPublisher) Stop() {
+	_ = p.writer.Close()
+}
+
+// Publish writes the message as JSON, keyed by the id shared by the events of
+// its console so that they are kept in order. Kafka doesn't assign messages an
+// id, so once it is acknowledged this returns an id unique to the event.
+func (p *KafkaPublisher) Publish(ctx context.Context, msg interface{}) (string, error) {
+	message, err := encode(msg)
+	if err != nil {
+		return "", ErrorKafkaFailedPublish{err: err, Topic: p.topic, Message: msg}
+	}
+	if len(message.Body) == 0 {
+		return "", ErrorKafkaFailedPublish{err: errors.New("empty message"), Topic: p.topic, Message: msg}
+	}
+
+	var headers []kafka.Header
+	for name, value := range message.Headers("ce_") {
+		headers = append(headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+
+	if err := p.writer.WriteMessages(ctx, kafka.Message{Key: []byte(message.Key), Value: message.Body, Headers: headers}); err != nil {
+		return "", ErrorKafkaFailedPublish{err: err, Topic: p.topic, Message: msg}
+	}
+
+	return message.ID, nil
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter records the messages written to it, or fails with err
+type fakeWriter struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.messages = append(w.messages, msgs...)
+
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+var _ = Describe("KafkaPublisher", func() {
+	var (
+		publisher *KafkaPublisher
+		writer    *fakeWriter
+		event     ConsoleAuthoriseEvent
+	)
+
+	BeforeEach(func() {
+		writer = &fakeWriter{}
+		publisher = &KafkaPublisher{writer: writer, topic: "console-events"}
+
+		event = ConsoleAuthoriseEvent{
+			CommonEvent: CommonEvent{
+				Kind:  KindConsole,
+				Event: EventAuthorise,
+				Id:    "20220101120000/cluster/namespace/console",
+			},
+			Spec: ConsoleAuthoriseSpec{Username: "alice@example.com"},
+		}
+	})
+
+	It("keys the message by the event id", func() {
+		_, err := publisher.Publish(context.Background(), event)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(writer.messages).To(HaveLen(1))
+		Expect(string(writer.messages[0].Key)).To(Equal("20220101120000/cluster/namespace/console"))
+
+		var received ConsoleAuthoriseEvent
+		Expect(json.Unmarshal(writer.messages[0].Value, &received)).To(Succeed())
+		Expect(received).To(Equal(event))
+	})
+
+	It("returns an id unique to the acknowledged event", func() {
+		id, err := publisher.Publish(context.Background(), event)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(uniqueEventID(event.CommonEvent)))
+		Expect(id).To(HavePrefix("20220101120000/cluster/namespace/console/authorise/"))
+	})
+
+	It("returns write errors", func() {
+		writer.err = errors.New("leader not available")
+
+		_, err := publisher.Publish(context.Background(), event)
+		Expect(err).To(MatchError(ContainSubstring("leader not available")))
+		Expect(err).To(BeAssignableToTypeOf(ErrorKafkaFailedPublish{}))
+	})
+
+	Describe("NewKafkaPublisher", func() {
+		var opts KafkaPublisherOptions
+
+		BeforeEach(func() {
+			opts = KafkaPublisherOptions{Brokers: []string{"localhost:9092"}, Topic: "console-events"}
+		})
+
+		It("requires brokers and a topic", func() {
+			_, err := NewKafkaPublisher(KafkaPublisherOptions{Topic: "console-events"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = NewKafkaPublisher(KafkaPublisherOptions{Brokers: []string{"localhost:9092"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		for _, mechanism := range []string{"", KafkaSASLPlain, KafkaSASLSCRAMSHA256, KafkaSASLSCRAMSHA512} {
+			mechanism := mechanism
+
+			It("supports SASL mechanism '"+mechanism+"'", func() {
+				opts.SASLMechanism, opts.SASLUsername, opts.SASLPassword = mechanism, "user", "password"
+
+				_, err := NewKafkaPublisher(opts)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		}
+
+		It("rejects unknown SASL mechanisms", func() {
+			opts.SASLMechanism = "gssapi"
+
+			_, err := NewKafkaPublisher(opts)
+			Expect(err).To(MatchError("unsupported SASL mechanism: gssapi"))
+		})
+	})
+})
@@ -0,0 +1,334 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// OutboxLabel marks the ConfigMaps that hold events waiting to be published
+	OutboxLabel = "workloads.crd.gocardless.com/outbox"
+	// OutboxDataKey is the key of the ConfigMap data holding the event JSON
+	OutboxDataKey = "event.json"
+	// OutboxAttemptsAnnotation counts the failed attempts to publish an event
+	// from the outbox while the publisher was accepting other events
+	OutboxAttemptsAnnotation = "workloads.crd.gocardless.com/outbox-attempts"
+	// OutboxDeadLetter is the value of the OutboxLabel given to events that are
+	// no longer retried, which are kept for inspection
+	OutboxDeadLetter = "dead-letter"
+
+	// DefaultOutboxMaxAttempts is used when OutboxOptions.MaxAttempts is unset
+	DefaultOutboxMaxAttempts = 10
+)
+
+var (
+	outboxDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "theatre_lifecycle_events_outbox_depth",
+			Help: "Number of lifecycle events waiting in the outbox to be published",
+		},
+	)
+	outboxOldestAge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "theatre_lifecycle_events_outbox_oldest_age_seconds",
+			Help: "Age of the oldest lifecycle event waiting in the outbox to be published",
+		},
+	)
+	outboxRedelivered = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "theatre_lifecycle_events_outbox_redelivered_total",
+			Help: "Count of lifecycle events published from the outbox after failing to be published when recorded",
+		},
+	)
+	outboxErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "theatre_lifecycle_events_outbox_errors_total",
+			Help: "Count of failed attempts to publish lifecycle events from the outbox",
+		},
+	)
+	outboxDeadLettered = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "theatre_lifecycle_events_outbox_dead_lettered_total",
+			Help: "Count of lifecycle events given up on after repeatedly failing to publish from the outbox",
+		},
+	)
+)
+
+func init() {
+	// Register custom metrics with the global controller runtime prometheus registry
+	metrics.Registry.MustRegister(outboxDepth, outboxOldestAge, outboxRedelivered, outboxErrors, outboxDeadLettered)
+}
+
+// OutboxOptions configures an Outbox
+type OutboxOptions struct {
+	// Namespace the ConfigMaps holding events are created in
+	Namespace string
+	// Interval between attempts to publish events from the outbox, which
+	// doubles after each failure up to MaxInterval
+	Interval    time.Duration
+	MaxInterval time.Duration
+	// Grace is how long an event is left for Publish to deliver before the
+	// background worker attempts it, to avoid publishing it twice
+	Grace time.Duration
+	// MaxAttempts is how many times an event may fail to publish, while the
+	// publisher is accepting other events, before it is dead-lettered. Defaults
+	// to DefaultOutboxMaxAttempts.
+	MaxAttempts int
+}
+
+// Outbox implements the publisher.Publisher interface, guaranteeing that
+// events are published at least once even if the underlying publisher is
+// unavailable.
+//
+// Each event is first written to a ConfigMap, then published, and the
+// ConfigMap deleted once it has been. Events that fail to publish are retried
+// by a background worker, which runs on the leader, so survive restarts and
+// leader changes.
+//
+// Events are retried oldest first, but one that fails doesn't hold back the
+// others, so the events of a console may be published out of order. An event
+// that keeps failing while others are published is dead-lettered rather than
+// retried forever.
+type Outbox struct {
+	client    client.Client
+	reader    client.Reader
+	publisher Publisher
+	logger    logr.Logger
+	opts      OutboxOptions
+
+	// published is set whenever Publish delivers an event, showing the publisher
+	// was available since the last flush
+	published atomic.Bool
+
+	now func() time.Time
+}
+
+// Test we implement the Publisher and Runnable interfaces
+var _ Publisher = &Outbox{}
+var _ manager.LeaderElectionRunnable = &Outbox{}
+
+// NewOutbox wraps the publisher with an outbox. The reader is used to list the
+// outbox without caching ConfigMaps, so is usually the manager's API reader.
+func NewOutbox(c client.Client, reader client.Reader, publisher Publisher, logger logr.Logger, opts OutboxOptions) *Outbox {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+
+	return &Outbox{
+		client:    c,
+		reader:    reader,
+		publisher: publisher,
+		logger:    logger,
+		opts:      opts,
+		now:       time.Now,
+	}
+}
+
+// Publish writes the message to the outbox, then attempts to publish it. If
+// publishing fails, the message is left for the background worker and no error
+// is returned, as it will be delivered eventually.
+func (o *Outbox) Publish(ctx context.Context, msg interface{}) (string, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "lifecycle-event-",
+			Namespace:    o.opts.Namespace,
+			Labels:       map[string]string{OutboxLabel: "true"},
+		},
+		Data: map[string]string{OutboxDataKey: string(body)},
+	}
+
+	// If we can't write to the outbox, publishing directly is the best we can do
+	if err := o.client.Create(ctx, cm); err != nil {
+		o.logger.Error(err, "failed to write event to outbox, publishing directly")
+		return o.publisher.Publish(ctx, json.RawMessage(body))
+	}
+
+	id, err := o.publisher.Publish(ctx, json.RawMessage(body))
+	if err != nil {
+		o.logger.Error(err, "failed to publish event, leaving it in the outbox", "configmap", cm.Name)
+		return cm.Name, nil
+	}
+	o.published.Store(true)
+
+	if err := o.client.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+		o.logger.Error(err, "failed to remove published event from outbox, it will be published again", "configmap", cm.Name)
+	}
+
+	return id, nil
+}
+
+// NeedLeaderElection ensures only one replica flushes the outbox
+func (o *Outbox) NeedLeaderElection() bool {
+	return true
+}
+
+// Start flushes the outbox until the context is cancelled, backing off while
+// the publisher is failing
+func (o *Outbox) Start(ctx context.Context) error {
+	interval := o.opts.Interval
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+
+		if err := o.Flush(ctx); err != nil {
+			o.logger.Error(err, "failed to flush outbox")
+
+			interval *= 2
+			if interval > o.opts.MaxInterval {
+				interval = o.opts.MaxInterval
+			}
+			continue
+		}
+
+		interval = o.opts.Interval
+	}
+}
+
+// Flush publishes the events in the outbox, oldest first, continuing past any
+// that fail. Events younger than the grace period are left for Publish.
+//
+// A failure only counts towards dead-lettering an event if the publisher
+// accepted another event since the last flush, so an outage of the publisher
+// doesn't empty the outbox. Events without a body are dead-lettered at once.
+func (o *Outbox) Flush(ctx context.Context) error {
+	var cms corev1.ConfigMapList
+	if err := o.reader.List(ctx, &cms, client.InNamespace(o.opts.Namespace), client.MatchingLabels{OutboxLabel: "true"}); err != nil {
+		return err
+	}
+
+	pending := cms.Items
+	sort.SliceStable(pending, func(i, j int) bool {
+		if pending[i].CreationTimestamp.Equal(&pending[j].CreationTimestamp) {
+			return pending[i].Name < pending[j].Name
+		}
+		return pending[i].CreationTimestamp.Before(&pending[j].CreationTimestamp)
+	})
+
+	remaining := []corev1.ConfigMap{}
+	defer func() { o.recordBacklog(remaining) }()
+
+	var (
+		failed   []corev1.ConfigMap
+		firstErr error
+	)
+	available := o.published.Swap(false)
+
+	for i, cm := range pending {
+		if o.now().Sub(cm.CreationTimestamp.Time) < o.opts.Grace {
+			remaining = append(remaining, cm)
+			continue
+		}
+
+		body, ok := cm.Data[OutboxDataKey]
+		if !ok {
+			o.logger.Info("event in outbox has no body", "configmap", cm.Name)
+			if err := o.deadLetter(ctx, cm); err != nil {
+				remaining = append(remaining, pending[i:]...)
+				return err
+			}
+			continue
+		}
+
+		if _, err := o.publisher.Publish(ctx, json.RawMessage(body)); err != nil {
+			outboxErrors.Inc()
+			o.logger.Error(err, "failed to publish event from outbox", "configmap", cm.Name)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed = append(failed, cm)
+			remaining = append(remaining, cm)
+			continue
+		}
+		outboxRedelivered.Inc()
+		available = true
+
+		if err := o.client.Delete(ctx, &cm); err != nil && !apierrors.IsNotFound(err) {
+			remaining = append(remaining, pending[i:]...)
+			return err
+		}
+
+		o.logger.Info("published event from outbox", "configmap", cm.Name)
+	}
+
+	if firstErr == nil {
+		return nil
+	}
+
+	if available {
+		for _, cm := range failed {
+			if err := o.recordAttempt(ctx, cm); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("failed to publish %d events from the outbox: %w", len(failed), firstErr)
+}
+
+// recordAttempt counts a failed attempt to publish the event while the
+// publisher was available, dead-lettering it once it reaches MaxAttempts
+func (o *Outbox) recordAttempt(ctx context.Context, cm corev1.ConfigMap) error {
+	attempts, _ := strconv.Atoi(cm.Annotations[OutboxAttemptsAnnotation])
+	attempts++
+
+	if attempts >= o.opts.MaxAttempts {
+		o.logger.Info("event in outbox failed too many times", "configmap", cm.Name, "attempts", attempts)
+		return o.deadLetter(ctx, cm)
+	}
+
+	patch := client.MergeFrom(cm.DeepCopy())
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[OutboxAttemptsAnnotation] = strconv.Itoa(attempts)
+
+	return client.IgnoreNotFound(o.client.Patch(ctx, &cm, patch))
+}
+
+// deadLetter relabels the event so the worker no longer retries it, keeping
+// the ConfigMap so the event can be inspected and replayed by hand
+func (o *Outbox) deadLetter(ctx context.Context, cm corev1.ConfigMap) error {
+	patch := client.MergeFrom(cm.DeepCopy())
+	cm.Labels[OutboxLabel] = OutboxDeadLetter
+
+	if err := o.client.Patch(ctx, &cm, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	outboxDeadLettered.Inc()
+	o.logger.Info("dead-lettered event from outbox", "configmap", cm.Name)
+
+	return nil
+}
+
+func (o *Outbox) recordBacklog(pending []corev1.ConfigMap) {
+	outboxDepth.Set(float64(len(pending)))
+	if len(pending) == 0 {
+		outboxOldestAge.Set(0)
+		return
+	}
+
+	outboxOldestAge.Set(o.now().Sub(pending[0].CreationTimestamp.Time).Seconds())
+}
@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// flakyPublisher records the events it publishes, failing while err is set and
+// always failing for the events it rejects
+type flakyPublisher struct {
+	published []string
+	rejected  map[string]bool
+	err       error
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, msg interface{}) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	if p.rejected[eventID(body)] {
+		return "", errors.New("rejected")
+	}
+
+	p.published = append(p.published, eventID(body))
+	return eventID(body), nil
+}
+
+var _ = Describe("Outbox", func() {
+	var (
+		ctx       context.Context
+		c         client.Client
+		publisher *flakyPublisher
+		outbox    *Outbox
+		now       time.Time
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		now = time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		publisher = &flakyPublisher{}
+		outbox = NewOutbox(c, c, publisher, logr.Discard(), OutboxOptions{
+			Namespace:   "theatre-system",
+			Grace:       time.Minute,
+			MaxAttempts: 2,
+		})
+		outbox.now = func() time.Time { return now }
+	})
+
+	outboxed := func() []corev1.ConfigMap {
+		var cms corev1.ConfigMapList
+		Expect(c.List(ctx, &cms, client.InNamespace("theatre-system"), client.MatchingLabels{OutboxLabel: "true"})).To(Succeed())
+		return cms.Items
+	}
+
+	deadLettered := func() []string {
+		var cms corev1.ConfigMapList
+		Expect(c.List(ctx, &cms, client.InNamespace("theatre-system"), client.MatchingLabels{OutboxLabel: OutboxDeadLetter})).To(Succeed())
+
+		names := []string{}
+		for _, cm := range cms.Items {
+			names = append(names, cm.Name)
+		}
+		return names
+	}
+
+	// spool writes an event to the outbox as if Publish had failed to deliver it
+	spool := func(id string, createdAt time.Time) {
+		body, err := json.Marshal(CommonEvent{Id: id})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              id,
+				Namespace:         "theatre-system",
+				Labels:            map[string]string{OutboxLabel: "true"},
+				CreationTimestamp: metav1.NewTime(createdAt),
+			},
+			Data: map[string]string{OutboxDataKey: string(body)},
+		})).To(Succeed())
+	}
+
+	Describe("Publish", func() {
+		It("publishes the event and removes it from the outbox", func() {
+			id, err := outbox.Publish(ctx, CommonEvent{Id: "event"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal("event"))
+
+			Expect(publisher.published).To(ConsistOf("event"))
+			Expect(outboxed()).To(BeEmpty())
+		})
+
+		It("leaves the event in the outbox when publishing fails", func() {
+			publisher.err = errors.New("unavailable")
+
+			_, err := outbox.Publish(ctx, CommonEvent{Id: "event"})
+			Expect(err).NotTo(HaveOccurred())
+
+			cms := outboxed()
+			Expect(cms).To(HaveLen(1))
+			Expect(cms[0].Data[OutboxDataKey]).To(ContainSubstring(`"id":"event"`))
+		})
+	})
+
+	Describe("Flush", func() {
+		It("publishes events oldest first", func() {
+			spool("second", now.Add(-2*time.Minute))
+			spool("first", now.Add(-3*time.Minute))
+
+			Expect(outbox.Flush(ctx)).To(Succeed())
+			Expect(publisher.published).To(Equal([]string{"first", "second"}))
+			Expect(outboxed()).To(BeEmpty())
+		})
+
+		It("leaves events within the grace period for Publish", func() {
+			spool("old", now.Add(-2*time.Minute))
+			spool("new", now.Add(-time.Second))
+
+			Expect(outbox.Flush(ctx)).To(Succeed())
+			Expect(publisher.published).To(Equal([]string{"old"}))
+			Expect(outboxed()).To(HaveLen(1))
+		})
+
+		It("keeps events when publishing fails", func() {
+			spool("first", now.Add(-3*time.Minute))
+			spool("second", now.Add(-2*time.Minute))
+			publisher.err = errors.New("unavailable")
+
+			Expect(outbox.Flush(ctx)).To(MatchError(ContainSubstring("unavailable")))
+			Expect(outboxed()).To(HaveLen(2))
+
+			publisher.err = nil
+			Expect(outbox.Flush(ctx)).To(Succeed())
+			Expect(publisher.published).To(Equal([]string{"first", "second"}))
+		})
+
+		It("publishes the events behind one that fails", func() {
+			spool("poison", now.Add(-3*time.Minute))
+			spool("second", now.Add(-2*time.Minute))
+			publisher.rejected = map[string]bool{"poison": true}
+
+			Expect(outbox.Flush(ctx)).To(MatchError(ContainSubstring("rejected")))
+			Expect(publisher.published).To(Equal([]string{"second"}))
+			Expect(outboxed()).To(HaveLen(1))
+		})
+
+		It("dead-letters an event that keeps failing while others are published", func() {
+			spool("poison", now.Add(-3*time.Minute))
+			publisher.rejected = map[string]bool{"poison": true}
+
+			_, err := outbox.Publish(ctx, CommonEvent{Id: "event"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outbox.Flush(ctx)).NotTo(Succeed())
+			Expect(outboxed()[0].Annotations).To(HaveKeyWithValue(OutboxAttemptsAnnotation, "1"))
+
+			spool("other", now.Add(-2*time.Minute))
+			Expect(outbox.Flush(ctx)).NotTo(Succeed())
+			Expect(outboxed()).To(BeEmpty())
+			Expect(deadLettered()).To(ConsistOf("poison"))
+		})
+
+		It("doesn't count failures while the publisher is unavailable", func() {
+			spool("first", now.Add(-3*time.Minute))
+			publisher.err = errors.New("unavailable")
+
+			for i := 0; i < 3; i++ {
+				Expect(outbox.Flush(ctx)).NotTo(Succeed())
+			}
+
+			cms := outboxed()
+			Expect(cms).To(HaveLen(1))
+			Expect(cms[0].Annotations).NotTo(HaveKey(OutboxAttemptsAnnotation))
+			Expect(deadLettered()).To(BeEmpty())
+		})
+
+		It("dead-letters events without a body", func() {
+			Expect(c.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "empty",
+					Namespace:         "theatre-system",
+					Labels:            map[string]string{OutboxLabel: "true"},
+					CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+				},
+			})).To(Succeed())
+
+			Expect(outbox.Flush(ctx)).To(Succeed())
+			Expect(deadLettered()).To(ConsistOf("empty"))
+		})
+	})
+})
@@ -1,6 +1,11 @@
 package events
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
 
 type Publisher interface {
 	Publish(context.Context, interface{}) (string, error)
@@ -16,3 +21,99 @@ func (nop NopPublisher) Publish(_ context.Context, _ interface{}) (string, error
 func NewNopPublisher() *NopPublisher {
 	return &NopPublisher{}
 }
+
+// encodedMessage is an event ready to be sent by a publisher
+type encodedMessage struct {
+	Body        []byte
+	ContentType string
+	// ID identifies the event, and Key the console that it belongs to
+	ID  string
+	Key string
+	// CloudEvent is set when the message is a CloudEvent, and Attributes when it
+	// is in binary mode, to be sent alongside the body
+	CloudEvent bool
+	Attributes map[string]string
+}
+
+// encode marshals a message to JSON, unless it is a binary mode CloudEvent,
+// whose body is its data
+func encode(msg interface{}) (encodedMessage, error) {
+	switch event := msg.(type) {
+	case BinaryCloudEvent:
+		return encodedMessage{
+			Body:        event.Data,
+			ContentType: event.DataContentType,
+			ID:          event.ID,
+			Key:         event.PartitionKey,
+			CloudEvent:  true,
+			Attributes:  event.Attributes(),
+		}, nil
+	case CloudEvent:
+		body, err := json.Marshal(event)
+		if err != nil {
+			return encodedMessage{}, err
+		}
+
+		return encodedMessage{
+			Body:        body,
+			ContentType: CloudEventsContentType,
+			ID:          event.ID,
+			Key:         event.PartitionKey,
+			CloudEvent:  true,
+		}, nil
+	default:
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return encodedMessage{}, err
+		}
+
+		return encodedMessage{Body: body, ContentType: "application/json", ID: messageID(body), Key: eventID(body)}, nil
+	}
+}
+
+// Headers returns the CloudEvents attributes of the message, with their names
+// prefixed as required by the protocol binding, and its content type. It is
+// empty for messages that aren't CloudEvents, which are sent as they always
+// have been.
+func (m encodedMessage) Headers(prefix string) map[string]string {
+	if !m.CloudEvent {
+		return nil
+	}
+
+	headers := map[string]string{"content-type": m.ContentType}
+	for name, value := range m.Attributes {
+		headers[prefix+name] = value
+	}
+
+	return headers
+}
+
+// messageID returns an id unique to an event marshalled to JSON, or an empty
+// string if it doesn't have an id
+func messageID(body []byte) string {
+	var event CommonEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Id == "" {
+		return ""
+	}
+
+	return uniqueEventID(event)
+}
+
+// uniqueEventID combines the id of an event, which is shared by all events of
+// its console, with the event and the time it was observed to be unique
+func uniqueEventID(event CommonEvent) string {
+	return strings.Join([]string{
+		event.Id, strings.ToLower(string(event.Event)), strconv.FormatInt(event.ObservedAt.UnixNano(), 10),
+	}, "/")
+}
+
+// eventID returns the id of an event marshalled to JSON, or an empty string if
+// it doesn't have one
+func eventID(body []byte) string {
+	var event struct {
+		Id string `json:"id"`
+	}
+	_ = json.Unmarshal(body, &event)
+
+	return event.Id
+}
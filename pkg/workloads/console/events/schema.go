@@ -0,0 +1,402 @@
+package events
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:generate go run schemas_gen.go
+
+// Version is the apiVersion of the events published by this package. It must
+// be bumped whenever an event changes in a way that isn't backwards compatible,
+// which TestSchemas enforces against the schemas in SchemaDir.
+const Version = "v1alpha1"
+
+// SchemaDir holds the published JSON Schema of each event, in a directory per
+// Version
+const SchemaDir = "schemas"
+
+// JSONSchemaDialect is the JSON Schema version of the published schemas
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+//go:embed schemas
+var publishedSchemas embed.FS
+
+// eventTypes are the events that have schemas, by kind and event
+var eventTypes = map[Kind]map[EventKind]interface{}{
+	KindConsole: {
+		EventRequest:      ConsoleRequestEvent{},
+		EventAuthorise:    ConsoleAuthoriseEvent{},
+		EventStart:        ConsoleStartEvent{},
+		EventAttach:       ConsoleAttachEvent{},
+		EventAttachDenied: ConsoleAttachDeniedEvent{},
+		EventAttachClosed: ConsoleAttachClosedEvent{},
+		EventTerminated:   ConsoleTerminatedEvent{},
+	},
+	KindConsoleTemplate: {
+		EventTemplateChange: ConsoleTemplateChangeEvent{},
+	},
+}
+
+// enums are the values of string types that hold a fixed set of values
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(TerminateReason("")): {
+		string(TerminateCompleted), string(TerminateFailed), string(TerminateStopped),
+		string(TerminateExpired), string(TerminateAborted), string(TerminateDeleted),
+	},
+	reflect.TypeOf(AttachClosedReason("")): {
+		string(AttachClosedContainerTerminated), string(AttachClosedConsoleStopped),
+	},
+}
+
+// Schema is the subset of JSON Schema needed to describe the events
+type Schema struct {
+	Dialect              string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 SchemaTypes        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Const                *string            `json:"const,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+// SchemaTypes are the JSON types allowed by a schema, marshalled as a string
+// when there is only one
+type SchemaTypes []string
+
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+
+	return json.Marshal([]string(t))
+}
+
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Allows returns whether the schema allows values of the JSON type, where a
+// schema without types allows any
+func (t SchemaTypes) Allows(jsonType string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, allowed := range t {
+		if allowed == jsonType || (allowed == "number" && jsonType == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SchemaFile returns the name of the file holding the schema of an event,
+// relative to the directory of its version
+func SchemaFile(kind Kind, event EventKind) string {
+	return strings.ToLower(fmt.Sprintf("%s-%s.json", kind, event))
+}
+
+// GenerateSchemas generates the schema of each event from its Go type, keyed
+// by SchemaFile
+func GenerateSchemas() map[string]*Schema {
+	schemas := map[string]*Schema{}
+	for kind, kindEvents := range eventTypes {
+		for event, eventType := range kindEvents {
+			schema := schemaFor(reflect.TypeOf(eventType))
+			schema.Dialect = JSONSchemaDialect
+			schema.ID = fmt.Sprintf("theatre/%s/%s/%s", SchemaDir, Version, SchemaFile(kind, event))
+			schema.Title = reflect.TypeOf(eventType).Name()
+
+			// Each schema describes a single event, at the current version
+			schema.Properties["apiVersion"].Const = stringPtr(Version)
+			schema.Properties["kind"].Const = stringPtr(string(kind))
+			schema.Properties["event"].Const = stringPtr(string(event))
+
+			schemas[SchemaFile(kind, event)] = schema
+		}
+	}
+
+	return schemas
+}
+
+// PublishedSchemas returns the schemas published for the version, keyed by
+// SchemaFile
+func PublishedSchemas(version string) (map[string]*Schema, error) {
+	dir := SchemaDir + "/" + version
+	entries, err := publishedSchemas.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no schemas published for %q: %w", version, err)
+	}
+
+	schemas := map[string]*Schema{}
+	for _, entry := range entries {
+		body, err := publishedSchemas.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		schema := &Schema{}
+		if err := json.Unmarshal(body, schema); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", entry.Name(), err)
+		}
+		schemas[entry.Name()] = schema
+	}
+
+	return schemas, nil
+}
+
+// parsedSchemas caches the schemas parsed by PublishedSchemas, by version. As
+// they are embedded, they never change.
+var parsedSchemas sync.Map
+
+// cachedPublishedSchemas returns PublishedSchemas, parsing the schemas of each
+// version only once. The schemas are shared, so mustn't be modified.
+func cachedPublishedSchemas(version string) (map[string]*Schema, error) {
+	if schemas, ok := parsedSchemas.Load(version); ok {
+		return schemas.(map[string]*Schema), nil
+	}
+
+	schemas, err := PublishedSchemas(version)
+	if err != nil {
+		return nil, err
+	}
+	parsedSchemas.Store(version, schemas)
+
+	return schemas, nil
+}
+
+// WriteSchemas writes the generated schemas to the directory of the current
+// version within dir, replacing any already there. As those have been
+// published, it refuses to replace them with schemas that are incompatible, in
+// which case Version must be bumped instead.
+func WriteSchemas(dir string) error {
+	dir = filepath.Join(dir, Version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	generated := GenerateSchemas()
+	problems, err := incompatibleWrites(dir, generated)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("schemas changed incompatibly, so Version must be bumped from %s: %s", Version, strings.Join(problems, "; "))
+	}
+
+	for name, schema := range generated {
+		body, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), append(body, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// incompatibleWrites returns the ways in which the generated schemas are
+// incompatible with those already written to dir, prefixed by their file
+func incompatibleWrites(dir string, generated map[string]*Schema) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	for _, entry := range entries {
+		body, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		existing := &Schema{}
+		if err := json.Unmarshal(body, existing); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", entry.Name(), err)
+		}
+
+		for _, problem := range Incompatibilities(existing, generated[entry.Name()]) {
+			problems = append(problems, entry.Name()+" "+problem)
+		}
+	}
+
+	return problems, nil
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor generates the schema of values of the type, as they are marshalled
+// by encoding/json
+func schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		schema := schemaFor(t.Elem())
+		schema.Type = append(schema.Type, "null")
+		return schema
+	}
+
+	if t == timeType {
+		return &Schema{Type: SchemaTypes{"string"}, Format: "date-time"}
+	}
+	if t == rawMessageType || t.Kind() == reflect.Interface {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: SchemaTypes{"string"}, Enum: enums[t]}
+	case reflect.Bool:
+		return &Schema{Type: SchemaTypes{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: SchemaTypes{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaTypes{"number"}}
+	case reflect.Slice:
+		// Nil slices and maps are marshalled as null
+		return &Schema{Type: SchemaTypes{"array", "null"}, Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: SchemaTypes{"object", "null"}, AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{Type: SchemaTypes{"object"}, Properties: map[string]*Schema{}}
+		addFields(schema, t)
+		sort.Strings(schema.Required)
+		return schema
+	}
+
+	panic(fmt.Sprintf("no schema for values of type %s", t))
+}
+
+// addFields adds the exported fields of the struct to the schema, flattening
+// embedded structs as encoding/json does
+func addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// Incompatibilities returns the ways in which events matching the new schema
+// may break consumers of the old one: a property that was removed, or is no
+// longer required, a type that now allows different values, or values added to
+// an enum. Adding properties is compatible, as consumers must ignore those they
+// don't know.
+func Incompatibilities(old, new *Schema) []string {
+	return incompatibilities("", old, new)
+}
+
+func incompatibilities(path string, old, new *Schema) []string {
+	describe := func(format string, args ...interface{}) string {
+		location := path
+		if location == "" {
+			location = "/"
+		}
+		return location + ": " + fmt.Sprintf(format, args...)
+	}
+
+	if new == nil {
+		return []string{describe("removed")}
+	}
+
+	problems := []string{}
+	if len(new.Type) == 0 && len(old.Type) > 0 {
+		problems = append(problems, describe("now allows any type, was %s", strings.Join(old.Type, " or ")))
+	}
+	for _, jsonType := range new.Type {
+		if !old.Type.Allows(jsonType) {
+			problems = append(problems, describe("now allows %s, was %s", jsonType, strings.Join(old.Type, " or ")))
+		}
+	}
+	if old.Format != new.Format {
+		problems = append(problems, describe("format changed from %q to %q", old.Format, new.Format))
+	}
+	if old.Const != nil && (new.Const == nil || *new.Const != *old.Const) {
+		problems = append(problems, describe("no longer always %q", *old.Const))
+	}
+	if len(old.Enum) > 0 {
+		for _, value := range missing(old.Enum, new.Enum) {
+			problems = append(problems, describe("now allows %q", value))
+		}
+		if len(new.Enum) == 0 {
+			problems = append(problems, describe("no longer restricted to %s", strings.Join(old.Enum, ", ")))
+		}
+	}
+	for _, name := range missing(new.Required, old.Required) {
+		problems = append(problems, describe("%s is no longer required", name))
+	}
+
+	names := []string{}
+	for name := range old.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problems = append(problems, incompatibilities(path+"/"+name, old.Properties[name], new.Properties[name])...)
+	}
+	if old.AdditionalProperties != nil {
+		problems = append(problems, incompatibilities(path+"/*", old.AdditionalProperties, new.AdditionalProperties)...)
+	}
+	if old.Items != nil {
+		problems = append(problems, incompatibilities(path+"/[]", old.Items, new.Items)...)
+	}
+
+	return problems
+}
+
+// missing returns the values that aren't in the list of allowed values
+func missing(allowed, values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		found := false
+		for _, allowedValue := range allowed {
+			found = found || allowedValue == value
+		}
+		if !found {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
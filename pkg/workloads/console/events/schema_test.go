@@ -0,0 +1,226 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schemas", func() {
+	Describe("published schemas", func() {
+		var (
+			published map[string]*Schema
+			generated map[string]*Schema
+		)
+
+		BeforeEach(func() {
+			var err error
+			published, err = PublishedSchemas(Version)
+			Expect(err).NotTo(HaveOccurred(), "schemas must be published for the current Version")
+			generated = GenerateSchemas()
+		})
+
+		It("are compatible with the event types", func() {
+			for name, schema := range published {
+				Expect(Incompatibilities(schema, generated[name])).To(BeEmpty(),
+					"%s has changed incompatibly, so Version must be bumped and the schemas generated for the new version", name)
+			}
+		})
+
+		It("are up to date", func() {
+			Expect(published).To(HaveLen(len(generated)))
+			for name, schema := range generated {
+				Expect(published).To(HaveKey(name))
+				Expect(published[name]).To(Equal(schema),
+					"%s is out of date, run: go generate ./pkg/workloads/console/events", name)
+			}
+		})
+	})
+
+	Describe("Incompatibilities", func() {
+		var old, new *Schema
+
+		BeforeEach(func() {
+			old = schemaFor(reflect.TypeOf(ConsoleTerminatedEvent{}))
+			new = schemaFor(reflect.TypeOf(ConsoleTerminatedEvent{}))
+		})
+
+		It("allows properties to be added", func() {
+			new.Properties["spec"].Properties["signal"] = &Schema{Type: SchemaTypes{"string"}}
+			new.Properties["spec"].Required = append(new.Properties["spec"].Required, "signal")
+
+			Expect(Incompatibilities(old, new)).To(BeEmpty())
+		})
+
+		It("allows enum values to be removed", func() {
+			new.Properties["spec"].Properties["reason"].Enum = []string{"Completed"}
+
+			Expect(Incompatibilities(old, new)).To(BeEmpty())
+		})
+
+		It("rejects properties being removed or renamed", func() {
+			new.Properties["spec"].Properties["timedOut"] = new.Properties["spec"].Properties["timed_out"]
+			delete(new.Properties["spec"].Properties, "timed_out")
+
+			Expect(Incompatibilities(old, new)).To(ContainElement("/spec/timed_out: removed"))
+		})
+
+		It("rejects properties becoming optional", func() {
+			new.Required = []string{"id"}
+
+			Expect(Incompatibilities(old, new)).To(ContainElement("/: kind is no longer required"))
+		})
+
+		It("rejects types changing", func() {
+			new.Properties["spec"].Properties["exit_codes"].AdditionalProperties.Type = SchemaTypes{"string"}
+
+			Expect(Incompatibilities(old, new)).To(ConsistOf("/spec/exit_codes/*: now allows string, was integer"))
+		})
+
+		It("rejects enum values being added", func() {
+			new.Properties["spec"].Properties["reason"].Enum = append(new.Properties["spec"].Properties["reason"].Enum, "Evicted")
+
+			Expect(Incompatibilities(old, new)).To(ConsistOf(`/spec/reason: now allows "Evicted"`))
+		})
+	})
+
+	Describe("WriteSchemas", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "schemas")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(WriteSchemas(dir)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("replaces compatible schemas", func() {
+			Expect(WriteSchemas(dir)).To(Succeed())
+		})
+
+		It("refuses to replace schemas with incompatible ones", func() {
+			path := filepath.Join(dir, Version, SchemaFile(KindConsole, EventTerminated))
+			schema := schemaFor(reflect.TypeOf(ConsoleTerminatedEvent{}))
+			schema.Properties["signal"] = &Schema{Type: SchemaTypes{"string"}}
+			body, err := json.Marshal(schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(path, body, 0644)).To(Succeed())
+
+			Expect(WriteSchemas(dir)).To(MatchError(ContainSubstring("console-terminate.json /signal: removed")))
+
+			written, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(written).To(Equal(body))
+		})
+	})
+
+	Describe("Validate", func() {
+		var event ConsoleTerminatedEvent
+
+		BeforeEach(func() {
+			event = ConsoleTerminatedEvent{
+				CommonEvent: CommonEvent{
+					Version:    Version,
+					Kind:       KindConsole,
+					Event:      EventTerminated,
+					ObservedAt: time.Date(2022, 1, 1, 12, 30, 0, 0, time.UTC),
+					Id:         "20220101123000/prod/payments/console-abcde",
+				},
+				Spec: ConsoleTerminatedSpec{
+					Reason:    TerminateCompleted,
+					ExitCodes: map[string]int32{"console-container-0": 0},
+				},
+			}
+		})
+
+		validate := func(msg interface{}) error {
+			body, err := json.Marshal(msg)
+			Expect(err).NotTo(HaveOccurred())
+			return Validate(body)
+		}
+
+		It("accepts events matching their schema", func() {
+			Expect(validate(event)).To(Succeed())
+		})
+
+		It("rejects events with invalid values", func() {
+			event.Spec.Reason = "Evicted"
+			Expect(validate(event)).To(MatchError(ContainSubstring(`/spec/reason: "Evicted" is not one of`)))
+		})
+
+		It("rejects events missing properties", func() {
+			var body map[string]interface{}
+			Expect(json.Unmarshal(mustMarshal(event), &body)).To(Succeed())
+			delete(body["spec"].(map[string]interface{}), "timed_out")
+
+			Expect(validate(body)).To(MatchError(ContainSubstring("/spec: missing timed_out")))
+		})
+
+		It("rejects events of unknown versions", func() {
+			event.Version = "v0"
+			Expect(validate(event)).To(MatchError(ContainSubstring("no schemas published for \"v0\"")))
+		})
+	})
+
+	Describe("ValidatingPublisher", func() {
+		var (
+			inner *recordingPublisher
+			event ConsoleAuthoriseEvent
+		)
+
+		BeforeEach(func() {
+			inner = &recordingPublisher{}
+			event = ConsoleAuthoriseEvent{
+				CommonEvent: CommonEvent{Version: Version, Kind: KindConsole, Event: EventAuthorise, Id: "id"},
+				Spec:        ConsoleAuthoriseSpec{Username: "alice@example.com"},
+			}
+		})
+
+		It("publishes valid events", func() {
+			publisher, err := NewValidatingPublisher(inner, logr.Discard(), ValidationReject)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = publisher.Publish(context.TODO(), event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inner.messages).To(ConsistOf(event))
+		})
+
+		It("rejects invalid events", func() {
+			publisher, err := NewValidatingPublisher(inner, logr.Discard(), ValidationReject)
+			Expect(err).NotTo(HaveOccurred())
+
+			event.Event = EventStart
+			_, err = publisher.Publish(context.TODO(), event)
+			Expect(err).To(MatchError(ContainSubstring("/spec: missing job")))
+			Expect(inner.messages).To(BeEmpty())
+		})
+
+		It("publishes invalid events when only warning", func() {
+			publisher, err := NewValidatingPublisher(inner, logr.Discard(), ValidationWarn)
+			Expect(err).NotTo(HaveOccurred())
+
+			event.Event = EventStart
+			_, err = publisher.Publish(context.TODO(), event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inner.messages).To(ConsistOf(event))
+		})
+	})
+})
+
+func mustMarshal(v interface{}) []byte {
+	body, err := json.Marshal(v)
+	Expect(err).NotTo(HaveOccurred())
+	return body
+}
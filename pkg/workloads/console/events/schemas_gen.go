@@ -0,0 +1,17 @@
+//go:build ignore
+
+// Writes the JSON Schema of each event to the directory of the current version
+// within the schemas directory. Run with go generate.
+package main
+
+import (
+	"log"
+
+	"github.com/gocardless/theatre/v3/pkg/workloads/console/events"
+)
+
+func main() {
+	if err := events.WriteSchemas(events.SchemaDir); err != nil {
+		log.Fatalf("failed to write schemas: %v", err)
+	}
+}
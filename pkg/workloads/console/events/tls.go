@@ -0,0 +1,48 @@
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures TLS connections made by publishers
+type TLSOptions struct {
+	// CAFile is a PEM bundle used to verify the server, in place of the system
+	// roots
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key, for mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables verification of the server certificate
+	InsecureSkipVerify bool
+}
+
+// Config builds a tls.Config from the options
+func (o TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.CAFile != "" {
+		ca, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
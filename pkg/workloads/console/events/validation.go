@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Modes in which the ValidatingPublisher handles events that don't match their
+// schema
+const (
+	// ValidationOff publishes events without validating them
+	ValidationOff = "off"
+	// ValidationWarn logs events that don't match their schema, but publishes
+	// them anyway
+	ValidationWarn = "warn"
+	// ValidationReject refuses to publish events that don't match their schema
+	ValidationReject = "reject"
+)
+
+// Validate checks that an event, marshalled to JSON, matches the schema
+// published for its apiVersion, kind and event
+func Validate(body []byte) error {
+	var event CommonEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return err
+	}
+
+	schemas, err := cachedPublishedSchemas(event.Version)
+	if err != nil {
+		return err
+	}
+
+	schema, ok := schemas[SchemaFile(event.Kind, event.Event)]
+	if !ok {
+		return fmt.Errorf("no schema for %s events", event.EventKind())
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+
+	if problems := validate("", schema, value); len(problems) > 0 {
+		return fmt.Errorf("%s event doesn't match its schema: %s", event.EventKind(), strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func validate(path string, schema *Schema, value interface{}) []string {
+	location := path
+	if location == "" {
+		location = "/"
+	}
+
+	jsonType := jsonTypeOf(value)
+	if !schema.Type.Allows(jsonType) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", location, strings.Join(schema.Type, " or "), jsonType)}
+	}
+
+	problems := []string{}
+	switch value := value.(type) {
+	case string:
+		if schema.Const != nil && value != *schema.Const {
+			problems = append(problems, fmt.Sprintf("%s: expected %q, got %q", location, *schema.Const, value))
+		}
+		if len(schema.Enum) > 0 && len(missing(schema.Enum, []string{value})) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %s", location, value, strings.Join(schema.Enum, ", ")))
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", location, value))
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for idx, item := range value {
+				problems = append(problems, validate(fmt.Sprintf("%s/%d", path, idx), schema.Items, item)...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing %s", location, name))
+			}
+		}
+
+		names := []string{}
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				problems = append(problems, validate(path+"/"+name, property, value[name])...)
+			} else if schema.AdditionalProperties != nil {
+				problems = append(problems, validate(path+"/"+name, schema.AdditionalProperties, value[name])...)
+			}
+		}
+	}
+
+	return problems
+}
+
+// jsonTypeOf returns the JSON Schema type of a value unmarshalled from JSON
+func jsonTypeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// ValidatingPublisher wraps a Publisher, validating each event against its
+// schema before it is published. It must see events as they are created, so
+// wraps any CloudEventsPublisher or Outbox.
+type ValidatingPublisher struct {
+	publisher Publisher
+	logger    logr.Logger
+	reject    bool
+}
+
+// Test we implement the Publisher interface
+var _ Publisher = &ValidatingPublisher{}
+
+// NewValidatingPublisher wraps the publisher to validate events in the given
+// mode, one of ValidationWarn or ValidationReject
+func NewValidatingPublisher(publisher Publisher, logger logr.Logger, mode string) (*ValidatingPublisher, error) {
+	switch mode {
+	case ValidationWarn, ValidationReject:
+	default:
+		return nil, fmt.Errorf("unsupported validation mode: %s", mode)
+	}
+
+	return &ValidatingPublisher{
+		publisher: publisher,
+		logger:    logger,
+		reject:    mode == ValidationReject,
+	}, nil
+}
+
+func (p *ValidatingPublisher) Publish(ctx context.Context, msg interface{}) (string, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	if err := Validate(body); err != nil {
+		if p.reject {
+			return "", err
+		}
+		p.logger.Error(err, "publishing invalid event", "id", eventID(body))
+	}
+
+	return p.publisher.Publish(ctx, msg)
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// CompleteConsoleNames returns the names of the consoles in the namespace, or
+// all namespaces if empty, for use in shell completion. If any phases are
+// given, only consoles in one of them are returned.
+func (c *Runner) CompleteConsoleNames(namespace string, phases ...workloadsv1alpha1.ConsolePhase) ([]string, error) {
+	csls, err := c.ListConsolesByLabelsAndUser(namespace, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return consoleNames(csls, phases...), nil
+}
+
+// CompleteTemplateSelectors returns a selector for each label of the console
+// templates in the namespace, or all namespaces if empty, for use in shell
+// completion.
+func (c *Runner) CompleteTemplateSelectors(namespace string) ([]string, error) {
+	templates, err := c.FindTemplatesBySelector(namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return templateSelectors(templates), nil
+}
+
+func consoleNames(csls ConsoleSlice, phases ...workloadsv1alpha1.ConsolePhase) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, csl := range csls {
+		if !hasPhase(csl, phases) || seen[csl.Name] {
+			continue
+		}
+
+		seen[csl.Name] = true
+		names = append(names, csl.Name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func hasPhase(csl workloadsv1alpha1.Console, phases []workloadsv1alpha1.ConsolePhase) bool {
+	if len(phases) == 0 {
+		return true
+	}
+
+	for _, phase := range phases {
+		if csl.Status.Phase == phase {
+			return true
+		}
+	}
+
+	return false
+}
+
+func templateSelectors(templates []workloadsv1alpha1.ConsoleTemplate) []string {
+	selectors := []string{}
+	seen := map[string]bool{}
+	for _, tpl := range templates {
+		for key, value := range tpl.Labels {
+			selector := fmt.Sprintf("%s=%s", key, value)
+			if seen[selector] {
+				continue
+			}
+
+			seen[selector] = true
+			selectors = append(selectors, selector)
+		}
+	}
+
+	sort.Strings(selectors)
+	return selectors
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("Completion", func() {
+	Describe("consoleNames", func() {
+		var csls ConsoleSlice
+
+		BeforeEach(func() {
+			csls = ConsoleSlice{
+				buildConsoleWithPhase("b", workloadsv1alpha1.ConsoleRunning),
+				buildConsoleWithPhase("a", workloadsv1alpha1.ConsolePendingAuthorisation),
+				buildConsoleWithPhase("c", workloadsv1alpha1.ConsoleStopped),
+			}
+		})
+
+		It("returns every console, sorted, when no phases are given", func() {
+			Expect(consoleNames(csls)).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("filters by phase", func() {
+			Expect(consoleNames(csls, workloadsv1alpha1.ConsoleRunning)).To(Equal([]string{"b"}))
+			Expect(consoleNames(csls, workloadsv1alpha1.ConsolePendingAuthorisation, workloadsv1alpha1.ConsoleStopped)).
+				To(Equal([]string{"a", "c"}))
+		})
+
+		It("de-duplicates consoles with the same name in different namespaces", func() {
+			other := buildConsoleWithPhase("b", workloadsv1alpha1.ConsoleRunning)
+			other.Namespace = "other"
+
+			Expect(consoleNames(append(csls, other), workloadsv1alpha1.ConsoleRunning)).To(Equal([]string{"b"}))
+		})
+	})
+
+	Describe("templateSelectors", func() {
+		It("returns a sorted, de-duplicated selector for each label", func() {
+			templates := []workloadsv1alpha1.ConsoleTemplate{
+				{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"app": "payments", "env": "prod"}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "billing", Labels: map[string]string{"app": "billing", "env": "prod"}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "unlabelled"}},
+			}
+
+			Expect(templateSelectors(templates)).To(Equal([]string{"app=billing", "app=payments", "env=prod"}))
+		})
+	})
+})
+
+func buildConsoleWithPhase(name string, phase workloadsv1alpha1.ConsolePhase) workloadsv1alpha1.Console {
+	return workloadsv1alpha1.Console{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     workloadsv1alpha1.ConsoleStatus{Phase: phase},
+	}
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// ContextAnnotation is set on consoles listed across several contexts, to the
+// name of the context they were found in. It is only set on the copies
+// returned by the runner, and never stored in the cluster.
+const ContextAnnotation = "workloads.crd.gocardless.com/context"
+
+// ContextError is an error from a single context, when operating across
+// several of them
+type ContextError struct {
+	Context string
+	Err     error
+}
+
+func (e ContextError) Error() string {
+	return fmt.Sprintf("context %s: %s", e.Context, e.Err)
+}
+
+func (e ContextError) Unwrap() error {
+	return e.Err
+}
+
+// ListAcrossTargets lists the consoles matching the options in each of the
+// targets concurrently, annotating each console with its context. Errors from
+// individual targets are returned alongside the consoles from the others.
+func ListAcrossTargets(targets []Target, opts ListOptions) (ConsoleSlice, []ContextError) {
+	consolesByTarget := make([]ConsoleSlice, len(targets))
+	errs := make([]error, len(targets))
+
+	forEach(len(targets), func(i int) {
+		consolesByTarget[i], errs[i] = targets[i].Runner.ListConsolesByLabelsAndUser(
+			opts.Namespace, opts.Username, opts.Selector,
+		)
+	})
+
+	consoles := ConsoleSlice{}
+	for i, targetConsoles := range consolesByTarget {
+		for _, csl := range targetConsoles {
+			consoles = append(consoles, *withContext(&csl, targets[i].Context))
+		}
+	}
+
+	return consoles, contextErrors(targets, errs)
+}
+
+// ListPendingAcrossTargets lists the consoles awaiting authorisation by the
+// user in each of the targets concurrently, oldest first. Errors from
+// individual targets are returned alongside the consoles from the others.
+func ListPendingAcrossTargets(ctx context.Context, targets []Target, opts PendingOptions) (PendingConsoleSlice, []ContextError) {
+	pendingByTarget := make([]PendingConsoleSlice, len(targets))
+	errs := make([]error, len(targets))
+
+	forEach(len(targets), func(i int) {
+		pendingByTarget[i], errs[i] = targets[i].Runner.ListPendingAuthorisation(ctx, opts)
+	})
+
+	pending := PendingConsoleSlice{}
+	for i, targetPending := range pendingByTarget {
+		for _, p := range targetPending {
+			p.Context = targets[i].Context
+			pending = append(pending, p)
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Console.CreationTimestamp.Before(&pending[j].Console.CreationTimestamp)
+	})
+
+	return pending, contextErrors(targets, errs)
+}
+
+// withContext returns a copy of the console annotated with its context
+func withContext(csl *workloadsv1alpha1.Console, kctx string) *workloadsv1alpha1.Console {
+	csl = csl.DeepCopy()
+	if csl.Annotations == nil {
+		csl.Annotations = map[string]string{}
+	}
+	csl.Annotations[ContextAnnotation] = kctx
+
+	return csl
+}
+
+func contextErrors(targets []Target, errs []error) []ContextError {
+	var contextErrs []ContextError
+	for i, err := range errs {
+		if err != nil {
+			contextErrs = append(contextErrs, ContextError{Context: targets[i].Context, Err: err})
+		}
+	}
+
+	return contextErrs
+}
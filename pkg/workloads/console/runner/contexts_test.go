@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("Listing across contexts", func() {
+	var csl *workloadsv1alpha1.Console
+
+	BeforeEach(func() {
+		csl = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{Name: "console-a", Namespace: "default"},
+			Spec: workloadsv1alpha1.ConsoleSpec{
+				User:    "alice@example.com",
+				Command: []string{"rails", "c"},
+			},
+			Status: workloadsv1alpha1.ConsoleStatus{Phase: workloadsv1alpha1.ConsolePendingAuthorisation},
+		}
+	})
+
+	Describe("ConsoleSlice PrintAs", func() {
+		It("adds a context column when consoles are annotated with their context", func() {
+			output := &bytes.Buffer{}
+			consoles := ConsoleSlice{*withContext(csl, "prod-eu")}
+
+			Expect(consoles.PrintAs(output, "")).To(Succeed())
+			Expect(output.String()).To(HavePrefix("CONTEXT"))
+			Expect(output.String()).To(MatchRegexp(`prod-eu\s+console-a\s+default`))
+		})
+
+		It("doesn't modify the original console", func() {
+			withContext(csl, "prod-eu")
+			Expect(csl.Annotations).To(BeNil())
+		})
+	})
+
+	Describe("PendingConsoleSlice Print", func() {
+		It("adds a context column when consoles have a context", func() {
+			output := &bytes.Buffer{}
+			pending := PendingConsoleSlice{{Context: "prod-us", Console: *csl}}
+
+			Expect(pending.Print(output)).To(Succeed())
+			Expect(output.String()).To(MatchRegexp(`#\s+CONTEXT\s+NAMESPACE`))
+			Expect(output.String()).To(MatchRegexp(`1\s+prod-us\s+default\s+console-a`))
+		})
+
+		It("omits the context column otherwise", func() {
+			output := &bytes.Buffer{}
+			pending := PendingConsoleSlice{{Console: *csl}}
+
+			Expect(pending.Print(output)).To(Succeed())
+			Expect(output.String()).NotTo(ContainSubstring("CONTEXT"))
+		})
+	})
+
+	Describe("contextErrors", func() {
+		It("returns an error for each target that failed", func() {
+			targets := []Target{{Context: "prod-eu"}, {Context: "prod-us"}}
+			refused := errors.New("connection refused")
+
+			errs := contextErrors(targets, []error{nil, refused})
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Error()).To(Equal("context prod-us: connection refused"))
+			Expect(errors.Is(errs[0], refused)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,313 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// maxDescribedEvents is the number of most recent Kubernetes events shown when
+// describing a console
+const maxDescribedEvents = 10
+
+// DescribeOptions encapsulates the arguments to describe a console
+type DescribeOptions struct {
+	Namespace string
+	Name      string
+	Output    io.Writer
+}
+
+// ConsoleDescription gathers together the console and the related resources
+// that are shown when describing it. Any of the related resources may be nil if
+// they don't exist, e.g. once the console has been destroyed.
+type ConsoleDescription struct {
+	Console           *workloadsv1alpha1.Console
+	Template          *workloadsv1alpha1.ConsoleTemplate
+	AuthorisationRule *workloadsv1alpha1.ConsoleAuthorisationRule
+	Authorisation     *workloadsv1alpha1.ConsoleAuthorisation
+	Pod               *corev1.Pod
+	ContainerName     string
+	Events            []corev1.Event
+}
+
+// Describe prints a detailed description of a console, along with its related
+// resources and recent events
+func (c *Runner) Describe(ctx context.Context, opts DescribeOptions) (*ConsoleDescription, error) {
+	desc, err := c.GetConsoleDescription(ctx, opts.Namespace, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return desc, desc.Print(opts.Output)
+}
+
+// GetConsoleDescription finds a console by name, along with its related
+// resources
+func (c *Runner) GetConsoleDescription(ctx context.Context, namespace, name string) (*ConsoleDescription, error) {
+	csl, err := c.FindConsoleByName(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &ConsoleDescription{Console: csl}
+
+	tpl := &workloadsv1alpha1.ConsoleTemplate{}
+	err = c.kubeClient.Get(ctx, client.ObjectKey{Namespace: csl.Namespace, Name: csl.Spec.ConsoleTemplateRef.Name}, tpl)
+	if err == nil {
+		desc.Template = tpl
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get console template: %w", err)
+	}
+
+	if desc.Template != nil {
+		desc.AuthorisationRule = authorisationRuleFor(csl, desc.Template)
+	}
+
+	authorisation := &workloadsv1alpha1.ConsoleAuthorisation{}
+	err = c.kubeClient.Get(ctx, client.ObjectKey{Namespace: csl.Namespace, Name: csl.Name}, authorisation)
+	if err == nil {
+		desc.Authorisation = authorisation
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get console authorisation: %w", err)
+	}
+
+	if csl.Status.PodName != "" {
+		pod, containerName, err := c.GetAttachablePod(ctx, csl)
+		if err == nil {
+			desc.Pod, desc.ContainerName = pod, containerName
+		} else if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get console pod: %w", err)
+		}
+	}
+
+	events, err := c.listEvents(ctx, csl.Namespace, "Console", csl.Name)
+	if err != nil {
+		return nil, err
+	}
+	if desc.Pod != nil {
+		podEvents, err := c.listEvents(ctx, csl.Namespace, "Pod", desc.Pod.Name)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, podEvents...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if len(events) > maxDescribedEvents {
+		events = events[len(events)-maxDescribedEvents:]
+	}
+	desc.Events = events
+
+	return desc, nil
+}
+
+// authorisationRuleFor returns the authorisation rule that applies to the
+// console, determined in the same way as the controller, or nil if the template
+// has no authorisation rules
+func authorisationRuleFor(csl *workloadsv1alpha1.Console, tpl *workloadsv1alpha1.ConsoleTemplate) *workloadsv1alpha1.ConsoleAuthorisationRule {
+	if !tpl.HasAuthorisationRules() {
+		return nil
+	}
+
+	command := csl.Spec.Command
+	if len(command) == 0 {
+		command, _ = tpl.GetDefaultCommandWithArgs()
+	}
+
+	rule, err := tpl.GetAuthorisationRuleForCommand(command)
+	if err != nil {
+		return nil
+	}
+
+	return &rule
+}
+
+func (c *Runner) listEvents(ctx context.Context, namespace, kind, name string) ([]corev1.Event, error) {
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{
+			"involvedObject.kind": kind,
+			"involvedObject.name": name,
+		}.AsSelector().String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	return events.Items, nil
+}
+
+// eventTime returns the time at which an event was last observed, accounting
+// for the different fields populated by the core and events APIs
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+
+	return event.CreationTimestamp.Time
+}
+
+// ExitCode returns the exit code of the console's container, and whether it
+// has terminated
+func (d *ConsoleDescription) ExitCode() (int32, bool) {
+	if d.Pod == nil {
+		return 0, false
+	}
+
+	return containerExitCode(d.Pod, d.ContainerName)
+}
+
+// containerExitCode returns the exit code of a container in the pod, and
+// whether it has terminated
+func containerExitCode(pod *corev1.Pod, containerName string) (int32, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName && status.State.Terminated != nil {
+			return status.State.Terminated.ExitCode, true
+		}
+	}
+
+	return 0, false
+}
+
+// Print writes the description to the output, in a similar format to kubectl
+// describe
+func (d *ConsoleDescription) Print(output io.Writer) error {
+	csl := d.Console
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Name:\t%s\n", csl.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", csl.Namespace)
+	fmt.Fprintf(w, "User:\t%s\n", csl.Spec.User)
+	fmt.Fprintf(w, "Reason:\t%s\n", orNone(csl.Spec.Reason))
+	fmt.Fprintf(w, "Template:\t%s\n", describeTemplate(csl, d.Template))
+	fmt.Fprintf(w, "Command:\t%s\n", orNone(strings.Join(csl.Spec.Command, " ")))
+	fmt.Fprintf(w, "Shared With:\t%s\n", orNone(strings.Join(csl.Spec.SharedWith, ", ")))
+	fmt.Fprintf(w, "Phase:\t%s\n", csl.Status.Phase)
+	fmt.Fprintf(w, "Created:\t%s\n", csl.CreationTimestamp.Format(time.RFC3339))
+	fmt.Fprintf(w, "Expiry:\t%s\n", orNone(formatTime(csl.Status.ExpiryTime)))
+	fmt.Fprintf(w, "Pod:\t%s\n", orNone(csl.Status.PodName))
+
+	exitCode := "<none>"
+	if code, ok := d.ExitCode(); ok {
+		exitCode = fmt.Sprintf("%d", code)
+	}
+	fmt.Fprintf(w, "Exit Code:\t%s\n", exitCode)
+	fmt.Fprintf(w, "Captured Output:\t%s\n", describeCapturedOutput(csl.Status.CapturedOutput))
+
+	fmt.Fprintf(w, "Authorisation:\n")
+	if d.AuthorisationRule == nil {
+		fmt.Fprintf(w, "  Rule:\t<none>\n")
+	} else {
+		given := []string{}
+		if d.Authorisation != nil {
+			for _, subject := range d.Authorisation.Spec.Authorisations {
+				given = append(given, subject.Name)
+			}
+		}
+
+		authorisers := []string{}
+		for _, subject := range d.AuthorisationRule.Subjects {
+			authorisers = append(authorisers, subject.Kind+":"+subject.Name)
+		}
+
+		fmt.Fprintf(w, "  Rule:\t%s\n", orNone(d.AuthorisationRule.Name))
+		fmt.Fprintf(w, "  Authorisers:\t%s\n", orNone(strings.Join(authorisers, ", ")))
+		fmt.Fprintf(w, "  Required:\t%d\n", d.AuthorisationRule.AuthorisationsRequired)
+		fmt.Fprintf(w, "  Given:\t%s\n", strings.TrimSpace(fmt.Sprintf("%d %s", len(given), formatList(given))))
+	}
+
+	fmt.Fprintf(w, "Attach Sessions:\n")
+	if len(csl.Status.AttachSessions) == 0 {
+		fmt.Fprintf(w, "  <none>\n")
+	} else {
+		fmt.Fprintf(w, "  USER\tCONTAINER\tSTARTED\tENDED\n")
+		for _, session := range csl.Status.AttachSessions {
+			fmt.Fprintf(
+				w, "  %s\t%s\t%s\t%s\n",
+				session.Username, session.Container,
+				session.StartTime.Format(time.RFC3339), orNone(formatTime(session.EndTime)),
+			)
+		}
+	}
+
+	fmt.Fprintf(w, "Events:\n")
+	if len(d.Events) == 0 {
+		fmt.Fprintf(w, "  <none>\n")
+	} else {
+		fmt.Fprintf(w, "  LAST SEEN\tTYPE\tREASON\tOBJECT\tMESSAGE\n")
+		for _, event := range d.Events {
+			fmt.Fprintf(
+				w, "  %s\t%s\t%s\t%s\t%s\n",
+				duration.HumanDuration(time.Since(eventTime(event))),
+				event.Type,
+				event.Reason,
+				strings.ToLower(event.InvolvedObject.Kind)+"/"+event.InvolvedObject.Name,
+				strings.TrimSpace(event.Message),
+			)
+		}
+	}
+
+	return w.Flush()
+}
+
+func describeTemplate(csl *workloadsv1alpha1.Console, tpl *workloadsv1alpha1.ConsoleTemplate) string {
+	if tpl == nil {
+		return csl.Spec.ConsoleTemplateRef.Name + " (not found)"
+	}
+
+	return tpl.Name
+}
+
+func describeCapturedOutput(captured *workloadsv1alpha1.ConsoleCapturedOutput) string {
+	if captured == nil {
+		return "<none>"
+	}
+
+	description := fmt.Sprintf("%s (%d bytes", captured.SecretName, captured.Bytes)
+	if captured.Truncated {
+		description += ", truncated"
+	}
+
+	return description + ")"
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func formatList(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	return "(" + strings.Join(items, ", ") + ")"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+
+	return s
+}
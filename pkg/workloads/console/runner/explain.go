@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// ExplainOptions encapsulates the arguments to explain how a console would be
+// created
+type ExplainOptions struct {
+	Namespace      string
+	Selector       string
+	Timeout        time.Duration
+	Reason         string
+	Command        []string
+	Noninteractive bool
+	SharedWith     []string
+}
+
+// Explanation describes the console that would be created for a set of
+// options, without creating anything
+type Explanation struct {
+	// The console as it would be created, after being admitted by the API
+	// server and having the controller's defaults applied
+	Console  *workloadsv1alpha1.Console
+	Template *workloadsv1alpha1.ConsoleTemplate
+	// The authorisation rule matching the console's command, or nil if the
+	// console wouldn't require authorisation
+	AuthorisationRule *workloadsv1alpha1.ConsoleAuthorisationRule
+	// The timeout that was requested, before being clamped to the template's
+	// maximum
+	RequestedTimeoutSeconds int
+	// The job that the controller would create for the console
+	Job *batchv1.Job
+	// Set when the job could not be submitted for a server-side dry-run, in
+	// which case the job is as built by the controller, without any defaults
+	// applied by the API server
+	JobDryRunError error
+}
+
+// Explain determines the authorisation rule, limits and job for a console
+// created with the given options. The console and its job are submitted to the
+// API server as dry-runs, so that validation and admission webhooks are
+// applied, but nothing is persisted.
+func (c *Runner) Explain(ctx context.Context, opts ExplainOptions) (*Explanation, error) {
+	tpl, err := c.FindTemplateBySelector(opts.Namespace, opts.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	csl := buildConsole(tpl.Namespace, *tpl, Options{
+		Cmd:            opts.Command,
+		Timeout:        int(opts.Timeout.Seconds()),
+		Reason:         opts.Reason,
+		Noninteractive: opts.Noninteractive,
+		SharedWith:     opts.SharedWith,
+	})
+
+	if err := c.kubeClient.Create(ctx, csl, client.DryRunAll); err != nil {
+		return nil, fmt.Errorf("console would be rejected: %w", err)
+	}
+
+	// Apply the same defaults as the controller does when it first sees the
+	// console. Any reduction of the timeout is shown in the explanation.
+	requestedTimeout := csl.Spec.TimeoutSeconds
+	csl = csl.WithTemplateTTLs(tpl)
+	csl, _ = csl.WithTemplateTimeout(tpl)
+
+	explanation := &Explanation{
+		Console:                 csl,
+		Template:                tpl,
+		AuthorisationRule:       authorisationRuleFor(csl, tpl),
+		RequestedTimeoutSeconds: requestedTimeout,
+	}
+
+	job := csl.BuildJob(tpl)
+	dryRunJob := job.DeepCopy()
+	if err := c.kubeClient.Create(ctx, dryRunJob, client.DryRunAll); err != nil {
+		explanation.JobDryRunError = err
+	} else {
+		job = dryRunJob
+	}
+
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	explanation.Job = job
+
+	return explanation, nil
+}
+
+// Print writes the explanation to the output, followed by the job as YAML
+func (e *Explanation) Print(output io.Writer) error {
+	csl := e.Console
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+
+	command := csl.Spec.Command
+	if len(command) == 0 {
+		command, _ = e.Template.GetDefaultCommandWithArgs()
+	}
+
+	fmt.Fprintf(w, "Template:\t%s/%s\n", e.Template.Namespace, e.Template.Name)
+	fmt.Fprintf(w, "User:\t%s\n", orNone(csl.Spec.User))
+	fmt.Fprintf(w, "Command:\t%s\n", orNone(strings.Join(command, " ")))
+
+	if e.AuthorisationRule == nil {
+		fmt.Fprintf(w, "Authorisation:\tnot required\n")
+	} else {
+		authorisers := []string{}
+		for _, subject := range e.AuthorisationRule.Subjects {
+			authorisers = append(authorisers, subject.Kind+":"+subject.Name)
+		}
+
+		fmt.Fprintf(w, "Authorisation:\n")
+		fmt.Fprintf(w, "  Rule:\t%s\n", orNone(e.AuthorisationRule.Name))
+		fmt.Fprintf(w, "  Required:\t%d\n", e.AuthorisationRule.AuthorisationsRequired)
+		fmt.Fprintf(w, "  Authorisers:\t%s\n", orNone(strings.Join(authorisers, ", ")))
+	}
+
+	timeout := (time.Duration(csl.Spec.TimeoutSeconds) * time.Second).String()
+	switch {
+	case e.RequestedTimeoutSeconds < 1:
+		timeout += " (template default)"
+	case e.RequestedTimeoutSeconds != csl.Spec.TimeoutSeconds:
+		timeout += fmt.Sprintf(
+			" (requested %s, reduced to the template maximum)",
+			time.Duration(e.RequestedTimeoutSeconds)*time.Second,
+		)
+	}
+	fmt.Fprintf(w, "Timeout:\t%s\n", timeout)
+	fmt.Fprintf(w, "TTL Before Running:\t%s\n", csl.TTLSecondsBeforeRunning())
+	fmt.Fprintf(w, "TTL After Finished:\t%s\n", csl.TTLSecondsAfterFinished())
+
+	if e.JobDryRunError != nil {
+		fmt.Fprintf(w, "\nThe job could not be submitted for a dry-run, so is shown without server-side defaults: %v\n", e.JobDryRunError)
+	}
+	fmt.Fprintf(w, "\nJob:\n")
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return (&printers.YAMLPrinter{}).PrintObj(e.Job, output)
+}
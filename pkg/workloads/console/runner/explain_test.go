@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("Explanation", func() {
+	Describe("Print", func() {
+		var (
+			explanation *Explanation
+			output      *bytes.Buffer
+			err         error
+		)
+
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			ttl := int32(60)
+			explanation = &Explanation{
+				Console: &workloadsv1alpha1.Console{
+					ObjectMeta: metav1.ObjectMeta{Name: "template-abcde", Namespace: "default"},
+					Spec: workloadsv1alpha1.ConsoleSpec{
+						User:                    "alice@example.com",
+						Command:                 []string{"rails", "c"},
+						TimeoutSeconds:          3600,
+						TTLSecondsBeforeRunning: &ttl,
+						TTLSecondsAfterFinished: &ttl,
+						ConsoleTemplateRef:      corev1.LocalObjectReference{Name: "template"},
+					},
+				},
+				Template: &workloadsv1alpha1.ConsoleTemplate{
+					ObjectMeta: metav1.ObjectMeta{Name: "template", Namespace: "default"},
+				},
+				RequestedTimeoutSeconds: 7200,
+				Job: &batchv1.Job{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+					ObjectMeta: metav1.ObjectMeta{Name: "template-abcde-console", Namespace: "default"},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = explanation.Print(output)
+		})
+
+		Context("without an authorisation rule", func() {
+			It("shows that no authorisation is required", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(MatchRegexp(`Authorisation:\s+not required`))
+			})
+
+			It("shows the timeout was reduced", func() {
+				Expect(output.String()).To(ContainSubstring("1h0m0s (requested 2h0m0s, reduced to the template maximum)"))
+			})
+
+			It("prints the job as YAML", func() {
+				Expect(output.String()).To(ContainSubstring("kind: Job"))
+				Expect(output.String()).To(ContainSubstring("name: template-abcde-console"))
+			})
+		})
+
+		Context("with an authorisation rule", func() {
+			BeforeEach(func() {
+				explanation.AuthorisationRule = &workloadsv1alpha1.ConsoleAuthorisationRule{
+					Name: "rails-console",
+					ConsoleAuthorisers: workloadsv1alpha1.ConsoleAuthorisers{
+						AuthorisationsRequired: 2,
+						Subjects: []rbacv1.Subject{
+							{Kind: rbacv1.GroupKind, Name: "platform@example.com"},
+						},
+					},
+				}
+			})
+
+			It("shows the rule and its authorisers", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(MatchRegexp(`Rule:\s+rails-console`))
+				Expect(output.String()).To(MatchRegexp(`Required:\s+2`))
+				Expect(output.String()).To(MatchRegexp(`Authorisers:\s+Group:platform@example.com`))
+			})
+		})
+
+		Context("without a requested timeout", func() {
+			BeforeEach(func() {
+				explanation.RequestedTimeoutSeconds = 0
+			})
+
+			It("shows the template default is used", func() {
+				Expect(output.String()).To(ContainSubstring("1h0m0s (template default)"))
+			})
+		})
+	})
+})
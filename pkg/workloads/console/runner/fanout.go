@@ -0,0 +1,370 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// Target is a cluster to operate on, identified by its kubernetes context
+type Target struct {
+	Context string
+	Runner  *Runner
+}
+
+// FanOutOptions encapsulates the arguments to create a console from every
+// template that matches a selector
+type FanOutOptions struct {
+	// Namespace to search for templates, or all namespaces if empty
+	Namespace      string
+	Selector       string
+	Timeout        time.Duration
+	Reason         string
+	Command        []string
+	Noninteractive bool
+	SharedWith     []string
+
+	// Identifies the consoles created together, so that they can be authorised
+	// together. One is generated if unset. The console authenticator webhook
+	// qualifies it with the user creating the consoles to label them.
+	Batch string
+
+	// Called once every console has been created, with the batch label they
+	// were given, before waiting for them to be authorised and become ready
+	Created func(batch string, results FanOutResultSlice)
+}
+
+// FanOutResult is the outcome of creating a console from a single template,
+// or of finding the templates in a target if that failed
+type FanOutResult struct {
+	Context   string
+	Namespace string
+	Template  string
+	Console   *workloadsv1alpha1.Console
+	Err       error
+}
+
+// FanOutResultSlice is a list of fan-out results, in the order of the targets
+// and then the templates within them
+type FanOutResultSlice []FanOutResult
+
+// Failed returns the results that failed
+func (rs FanOutResultSlice) Failed() FanOutResultSlice {
+	failed := FanOutResultSlice{}
+	for _, r := range rs {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
+
+// Print writes a table of the results, one line per target template
+func (rs FanOutResultSlice) Print(output io.Writer) error {
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tNAMESPACE\tTEMPLATE\tCONSOLE\tPHASE\tERROR")
+	for _, r := range rs {
+		name, phase, errMsg := "-", "-", "-"
+		if r.Console != nil {
+			name, phase = r.Console.Name, string(r.Console.Status.Phase)
+		}
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			orDash(r.Context), orDash(r.Namespace), orDash(r.Template), name, orDash(phase), errMsg)
+	}
+
+	return w.Flush()
+}
+
+// FanOut creates a console from every template matching the selector, in each
+// of the targets, requesting the same batch for them all. It then waits for
+// each console to be authorised and become ready, returning the batch label
+// that they were given.
+//
+// A failure in one target doesn't prevent consoles from being created in the
+// others: it is recorded in the results instead.
+func FanOut(ctx context.Context, targets []Target, opts FanOutOptions) (string, FanOutResultSlice, error) {
+	batch := opts.Batch
+	if batch == "" {
+		batch = strings.Split(uuid.New().String(), "-")[0]
+	}
+
+	createOpts := Options{
+		Cmd:            opts.Command,
+		Timeout:        int(opts.Timeout.Seconds()),
+		Reason:         opts.Reason,
+		Noninteractive: opts.Noninteractive,
+		SharedWith:     opts.SharedWith,
+		Batch:          batch,
+	}
+
+	resultsByTarget := make([]FanOutResultSlice, len(targets))
+	forEach(len(targets), func(i int) {
+		resultsByTarget[i] = targets[i].createAll(opts.Namespace, opts.Selector, createOpts)
+	})
+
+	results := FanOutResultSlice{}
+	runners := []*Runner{}
+	for i, targetResults := range resultsByTarget {
+		for _, result := range targetResults {
+			results = append(results, result)
+			runners = append(runners, targets[i].Runner)
+		}
+	}
+
+	if len(results) == 0 {
+		return batch, results, fmt.Errorf("no console templates matched the selector: %s", opts.Selector)
+	}
+
+	for _, result := range results {
+		if result.Console != nil && result.Console.Labels[workloadsv1alpha1.ConsoleBatchLabel] != "" {
+			batch = result.Console.Labels[workloadsv1alpha1.ConsoleBatchLabel]
+			break
+		}
+	}
+
+	if opts.Created != nil {
+		opts.Created(batch, results)
+	}
+
+	forEach(len(results), func(i int) {
+		if results[i].Err != nil {
+			return
+		}
+
+		csl, err := runners[i].WaitUntilReady(ctx, *results[i].Console, true)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].Console = csl
+	})
+
+	return batch, results, nil
+}
+
+// createAll creates a console from each template matching the selector
+func (t Target) createAll(namespace, selector string, opts Options) FanOutResultSlice {
+	templates, err := t.Runner.FindTemplatesBySelector(namespace, selector)
+	if err != nil {
+		return FanOutResultSlice{{Context: t.Context, Namespace: namespace, Err: err}}
+	}
+
+	results := make(FanOutResultSlice, len(templates))
+	for i, tpl := range templates {
+		csl, err := t.Runner.CreateResource(tpl.Namespace, tpl, opts)
+		results[i] = FanOutResult{
+			Context:   t.Context,
+			Namespace: tpl.Namespace,
+			Template:  tpl.Name,
+			Err:       err,
+		}
+		if err == nil {
+			results[i].Console = csl
+		}
+	}
+
+	return results
+}
+
+// AuthoriseBatchOptions encapsulates the arguments to authorise every console
+// in a batch
+type AuthoriseBatchOptions struct {
+	// Namespace to search, or all namespaces if empty
+	Namespace string
+	Batch     string
+	Username  string
+}
+
+// ListBatch returns the consoles in the batch that are awaiting authorisation
+func (c *Runner) ListBatch(ctx context.Context, opts AuthoriseBatchOptions) (ConsoleSlice, error) {
+	if opts.Batch == "" {
+		return nil, errors.New("no batch given")
+	}
+
+	var csls workloadsv1alpha1.ConsoleList
+	listOpts := []client.ListOption{
+		client.InNamespace(opts.Namespace),
+		client.MatchingLabels{workloadsv1alpha1.ConsoleBatchLabel: opts.Batch},
+	}
+	if err := c.kubeClient.List(ctx, &csls, listOpts...); err != nil {
+		return nil, err
+	}
+
+	pending := ConsoleSlice{}
+	for _, csl := range csls.Items {
+		if csl.PendingAuthorisation() {
+			pending = append(pending, csl)
+		}
+	}
+
+	return pending, nil
+}
+
+// ListBatchAcrossTargets lists the consoles in the batch that are awaiting
+// authorisation in each of the targets concurrently, annotating each console
+// with its context
+func ListBatchAcrossTargets(ctx context.Context, targets []Target, opts AuthoriseBatchOptions) (ConsoleSlice, []ContextError) {
+	consolesByTarget := make([]ConsoleSlice, len(targets))
+	errs := make([]error, len(targets))
+
+	forEach(len(targets), func(i int) {
+		consolesByTarget[i], errs[i] = targets[i].Runner.ListBatch(ctx, opts)
+	})
+
+	consoles := ConsoleSlice{}
+	for i, targetConsoles := range consolesByTarget {
+		for _, csl := range targetConsoles {
+			consoles = append(consoles, *withContext(&csl, targets[i].Context))
+		}
+	}
+
+	return consoles, contextErrors(targets, errs)
+}
+
+// ValidateBatch checks that the consoles in a batch can be authorised together:
+// that there is at least one, and that every console was created by the same
+// user from the same template, with the same command and reason
+func ValidateBatch(consoles ConsoleSlice) error {
+	if len(consoles) == 0 {
+		return errors.New("no consoles in the batch are awaiting authorisation")
+	}
+
+	first := consoles[0].Spec
+	for _, csl := range consoles[1:] {
+		var field string
+		switch {
+		case csl.Spec.User != first.User:
+			field = "user"
+		case csl.Spec.ConsoleTemplateRef.Name != first.ConsoleTemplateRef.Name:
+			field = "template"
+		case !equality.Semantic.DeepEqual(csl.Spec.Command, first.Command):
+			field = "command"
+		case csl.Spec.Reason != first.Reason:
+			field = "reason"
+		case csl.Spec.Noninteractive != first.Noninteractive:
+			field = "interactivity"
+		case csl.Spec.TimeoutSeconds != first.TimeoutSeconds:
+			field = "timeout"
+		case !equality.Semantic.DeepEqual(csl.Spec.SharedWith, first.SharedWith):
+			field = "list of users shared with"
+		default:
+			continue
+		}
+
+		return fmt.Errorf(
+			"console %s/%s has a different %s to %s/%s, so the batch must be authorised one console at a time",
+			csl.Namespace, csl.Name, field, consoles[0].Namespace, consoles[0].Name,
+		)
+	}
+
+	return nil
+}
+
+// PrintBatch writes what the consoles in a validated batch will run, followed
+// by a table of the consoles
+func PrintBatch(output io.Writer, consoles ConsoleSlice) error {
+	spec := consoles[0].Spec
+	fmt.Fprintf(output, "User:           %s\n", spec.User)
+	fmt.Fprintf(output, "Template:       %s\n", spec.ConsoleTemplateRef.Name)
+	fmt.Fprintf(output, "Command:        %s\n", strings.Join(spec.Command, " "))
+	fmt.Fprintf(output, "Reason:         %s\n", spec.Reason)
+	fmt.Fprintf(output, "Noninteractive: %t\n", spec.Noninteractive)
+	fmt.Fprintf(output, "Timeout:        %s\n", orDash(timeoutString(spec.TimeoutSeconds)))
+	fmt.Fprintf(output, "Shared with:    %s\n\n", orDash(strings.Join(spec.SharedWith, ", ")))
+
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tNAMESPACE\tNAME\tAGE")
+	for _, csl := range consoles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			orDash(csl.Annotations[ContextAnnotation]), csl.Namespace, csl.Name,
+			duration.HumanDuration(time.Since(csl.CreationTimestamp.Time)))
+	}
+
+	return w.Flush()
+}
+
+// timeoutString returns the timeout, or an empty string if the template's
+// default applies
+func timeoutString(seconds int) string {
+	if seconds == 0 {
+		return ""
+	}
+
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// AuthoriseBatch authorises each of the consoles listed from a batch, in the
+// target for its context, returning the consoles that were authorised. It
+// attempts to authorise every console, even if some fail.
+func AuthoriseBatch(ctx context.Context, targets []Target, consoles ConsoleSlice, username string) (ConsoleSlice, error) {
+	runners := map[string]*Runner{}
+	for _, target := range targets {
+		runners[target.Context] = target.Runner
+	}
+
+	var (
+		authorised ConsoleSlice
+		result     error
+	)
+	for _, csl := range consoles {
+		runner, ok := runners[csl.Annotations[ContextAnnotation]]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("%s/%s: unknown context", csl.Namespace, csl.Name))
+			continue
+		}
+
+		err := runner.Authorise(ctx, AuthoriseOptions{
+			Namespace:   csl.Namespace,
+			ConsoleName: csl.Name,
+			Username:    username,
+		})
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("%s/%s: %w", csl.Namespace, csl.Name, err))
+			continue
+		}
+
+		authorised = append(authorised, csl)
+	}
+
+	return authorised, result
+}
+
+// forEach calls fn concurrently for each index up to n, returning once all the
+// calls have returned
+func forEach(n int, fn func(int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("FanOutResultSlice", func() {
+	var results FanOutResultSlice
+
+	BeforeEach(func() {
+		results = FanOutResultSlice{
+			{
+				Context:   "cluster-a",
+				Namespace: "tenant-a",
+				Template:  "app",
+				Console: &workloadsv1alpha1.Console{
+					ObjectMeta: metav1.ObjectMeta{Name: "app-abcde", Namespace: "tenant-a"},
+					Status:     workloadsv1alpha1.ConsoleStatus{Phase: workloadsv1alpha1.ConsoleRunning},
+				},
+			},
+			{
+				Context: "cluster-b",
+				Err:     errors.New("connection refused"),
+			},
+		}
+	})
+
+	Describe("Print", func() {
+		It("prints a line per target template", func() {
+			output := &bytes.Buffer{}
+			Expect(results.Print(output)).To(Succeed())
+
+			Expect(output.String()).To(HavePrefix("CONTEXT"))
+			Expect(output.String()).To(MatchRegexp(`cluster-a\s+tenant-a\s+app\s+app-abcde\s+Running\s+-`))
+			Expect(output.String()).To(MatchRegexp(`cluster-b\s+-\s+-\s+-\s+-\s+connection refused`))
+		})
+	})
+
+	Describe("Failed", func() {
+		It("returns only the failed results", func() {
+			failed := results.Failed()
+			Expect(failed).To(HaveLen(1))
+			Expect(failed[0].Context).To(Equal("cluster-b"))
+		})
+	})
+})
+
+var _ = Describe("forEach", func() {
+	It("calls the function for each index", func() {
+		var calls int32
+		seen := make([]bool, 5)
+		forEach(len(seen), func(i int) {
+			atomic.AddInt32(&calls, 1)
+			seen[i] = true
+		})
+
+		Expect(calls).To(Equal(int32(5)))
+		Expect(seen).NotTo(ContainElement(false))
+	})
+})
+
+var _ = Describe("ValidateBatch", func() {
+	var consoles ConsoleSlice
+
+	BeforeEach(func() {
+		console := func(namespace string) workloadsv1alpha1.Console {
+			return workloadsv1alpha1.Console{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-abcde", Namespace: namespace},
+				Spec: workloadsv1alpha1.ConsoleSpec{
+					User:               "alice@example.com",
+					Reason:             "Backfill payments",
+					Command:            []string{"rake", "payments:backfill"},
+					ConsoleTemplateRef: corev1.LocalObjectReference{Name: "app"},
+				},
+			}
+		}
+		consoles = ConsoleSlice{console("tenant-a"), console("tenant-b")}
+	})
+
+	It("accepts consoles that will run the same thing", func() {
+		Expect(ValidateBatch(consoles)).To(Succeed())
+	})
+
+	It("rejects an empty batch", func() {
+		Expect(ValidateBatch(ConsoleSlice{})).To(MatchError(ContainSubstring("no consoles")))
+	})
+
+	DescribeTable("rejects consoles that differ",
+		func(modify func(*workloadsv1alpha1.ConsoleSpec), field string) {
+			modify(&consoles[1].Spec)
+			Expect(ValidateBatch(consoles)).To(MatchError(
+				"console tenant-b/app-abcde has a different " + field + " to tenant-a/app-abcde, so the batch must be authorised one console at a time",
+			))
+		},
+		Entry("user", func(s *workloadsv1alpha1.ConsoleSpec) { s.User = "mallory@example.com" }, "user"),
+		Entry("template", func(s *workloadsv1alpha1.ConsoleSpec) { s.ConsoleTemplateRef.Name = "other" }, "template"),
+		Entry("command", func(s *workloadsv1alpha1.ConsoleSpec) { s.Command = []string{"rake", "payments:delete"} }, "command"),
+		Entry("reason", func(s *workloadsv1alpha1.ConsoleSpec) { s.Reason = "Something else" }, "reason"),
+		Entry("interactivity", func(s *workloadsv1alpha1.ConsoleSpec) { s.Noninteractive = true }, "interactivity"),
+		Entry("timeout", func(s *workloadsv1alpha1.ConsoleSpec) { s.TimeoutSeconds = 3600 }, "timeout"),
+		Entry("list of users shared with", func(s *workloadsv1alpha1.ConsoleSpec) { s.SharedWith = []string{"mallory@example.com"} }, "list of users shared with"),
+	)
+})
+
+var _ = Describe("PrintBatch", func() {
+	It("prints everything the consoles will run with", func() {
+		consoles := ConsoleSlice{{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-abcde", Namespace: "tenant-a"},
+			Spec: workloadsv1alpha1.ConsoleSpec{
+				User:               "alice@example.com",
+				Reason:             "Backfill payments",
+				Command:            []string{"rake", "payments:backfill"},
+				ConsoleTemplateRef: corev1.LocalObjectReference{Name: "app"},
+				Noninteractive:     true,
+				TimeoutSeconds:     3600,
+				SharedWith:         []string{"bob@example.com"},
+			},
+		}}
+
+		var output bytes.Buffer
+		Expect(PrintBatch(&output, consoles)).To(Succeed())
+		Expect(output.String()).To(ContainSubstring("Noninteractive: true\n"))
+		Expect(output.String()).To(ContainSubstring("Timeout:        1h0m0s\n"))
+		Expect(output.String()).To(ContainSubstring("Shared with:    bob@example.com\n"))
+	})
+})
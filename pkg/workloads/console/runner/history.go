@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// HistoryOptions encapsulates the arguments to list the history of consoles
+// that have been deleted
+type HistoryOptions struct {
+	Namespace string
+	Username  string
+	Selector  string
+	// Only list consoles created within this duration, or all if zero
+	Since  time.Duration
+	Output io.Writer
+	// Format of the output, as accepted by NewConsoleHistoryPrinter
+	OutputFormat string
+}
+
+const (
+	historyColumns     = "CONSOLE:.spec.consoleName,NAMESPACE:.metadata.namespace,PHASE:.spec.phase,CREATED:.spec.creationTime,USER:.spec.user,EXIT CODE:.spec.exitCode,REASON:.spec.reason"
+	historyWideColumns = historyColumns + ",TEMPLATE:.spec.consoleTemplateRef.name,ENDED:.spec.endTime,AUTHORISERS:.spec.authorisers,ATTACHED:.spec.attachSessions[*].username,COMMAND:.spec.command"
+)
+
+// NewConsoleHistoryPrinter returns a printer for console history in the given
+// output format, which is any of those accepted by NewConsolePrinter
+func NewConsoleHistoryPrinter(format string) (printers.ResourcePrinter, error) {
+	return newPrinter(format, historyColumns, historyWideColumns)
+}
+
+// History lists the history of consoles that have been deleted, oldest first,
+// and writes it to the output
+func (c *Runner) History(ctx context.Context, opts HistoryOptions) (ConsoleHistorySlice, error) {
+	// Check the output format before making any requests
+	if _, err := NewConsoleHistoryPrinter(opts.OutputFormat); err != nil {
+		return nil, err
+	}
+
+	selectorSet, err := labels.ConvertSelectorToLabelsMap(opts.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+
+	var histories workloadsv1alpha1.ConsoleHistoryList
+	listOpts := &client.ListOptions{Namespace: opts.Namespace, LabelSelector: labels.SelectorFromSet(selectorSet)}
+	if err := c.kubeClient.List(ctx, &histories, listOpts); err != nil {
+		return nil, err
+	}
+
+	var since time.Time
+	if opts.Since > 0 {
+		since = time.Now().Add(-opts.Since)
+	}
+
+	filtered := filterHistory(histories.Items, opts.Username, since)
+	return filtered, filtered.PrintAs(opts.Output, opts.OutputFormat)
+}
+
+// filterHistory returns the history of consoles belonging to the user, or any
+// user if empty, that were created after since, oldest first
+func filterHistory(histories []workloadsv1alpha1.ConsoleHistory, username string, since time.Time) ConsoleHistorySlice {
+	filtered := ConsoleHistorySlice{}
+	for _, history := range histories {
+		if username != "" && history.Spec.User != username {
+			continue
+		}
+		if history.Spec.CreationTime.Time.Before(since) {
+			continue
+		}
+		filtered = append(filtered, history)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Spec.CreationTime.Before(&filtered[j].Spec.CreationTime)
+	})
+
+	return filtered
+}
+
+type ConsoleHistorySlice []workloadsv1alpha1.ConsoleHistory
+
+// PrintAs writes the history to the output in the given format, as accepted by
+// NewConsoleHistoryPrinter. Structured formats print a ConsoleHistoryList.
+func (hs ConsoleHistorySlice) PrintAs(output io.Writer, format string) error {
+	printer, err := NewConsoleHistoryPrinter(format)
+	if err != nil {
+		return err
+	}
+
+	// Don't print table headers when there's nothing to list
+	if _, ok := printer.(tablePrinter); ok && len(hs) == 0 {
+		return nil
+	}
+
+	list := &workloadsv1alpha1.ConsoleHistoryList{Items: []workloadsv1alpha1.ConsoleHistory{}}
+	list.SetGroupVersionKind(workloadsv1alpha1.GroupVersion.WithKind("ConsoleHistoryList"))
+	for _, history := range hs {
+		history := history.DeepCopy()
+		history.SetGroupVersionKind(workloadsv1alpha1.GroupVersion.WithKind("ConsoleHistory"))
+		list.Items = append(list.Items, *history)
+	}
+
+	return printer.PrintObj(list, output)
+}
+
+// ParseSince parses a duration such as 7d or 12h. In addition to the units
+// accepted by time.ParseDuration, it accepts a whole number of days.
+func ParseSince(since string) (time.Duration, error) {
+	if strings.HasSuffix(since, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(since, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", since)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(since)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", since)
+	}
+
+	return duration, nil
+}
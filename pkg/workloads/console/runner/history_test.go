@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("History", func() {
+	buildHistory := func(consoleName, user string, created time.Time) workloadsv1alpha1.ConsoleHistory {
+		return workloadsv1alpha1.ConsoleHistory{
+			ObjectMeta: metav1.ObjectMeta{Name: consoleName + "-history", Namespace: "default"},
+			Spec: workloadsv1alpha1.ConsoleHistorySpec{
+				ConsoleName:  consoleName,
+				User:         user,
+				Phase:        workloadsv1alpha1.ConsoleStopped,
+				CreationTime: metav1.NewTime(created),
+			},
+		}
+	}
+
+	Describe("filterHistory", func() {
+		var (
+			now       time.Time
+			histories []workloadsv1alpha1.ConsoleHistory
+		)
+
+		BeforeEach(func() {
+			now = time.Now()
+			histories = []workloadsv1alpha1.ConsoleHistory{
+				buildHistory("b", "alice@example.com", now.Add(-2*time.Hour)),
+				buildHistory("a", "alice@example.com", now.Add(-3*time.Hour)),
+				buildHistory("c", "bob@example.com", now.Add(-time.Hour)),
+				buildHistory("d", "alice@example.com", now.Add(-10*24*time.Hour)),
+			}
+		})
+
+		consoleNames := func(hs ConsoleHistorySlice) []string {
+			names := []string{}
+			for _, h := range hs {
+				names = append(names, h.Spec.ConsoleName)
+			}
+			return names
+		}
+
+		It("returns all history, oldest first, without filters", func() {
+			Expect(consoleNames(filterHistory(histories, "", time.Time{}))).To(Equal([]string{"d", "a", "b", "c"}))
+		})
+
+		It("filters by user", func() {
+			Expect(consoleNames(filterHistory(histories, "bob@example.com", time.Time{}))).To(Equal([]string{"c"}))
+		})
+
+		It("filters by creation time", func() {
+			Expect(consoleNames(filterHistory(histories, "alice@example.com", now.Add(-7*24*time.Hour)))).
+				To(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Describe("PrintAs", func() {
+		It("prints a table", func() {
+			output := &bytes.Buffer{}
+			histories := ConsoleHistorySlice{buildHistory("console-a", "alice@example.com", time.Now())}
+
+			Expect(histories.PrintAs(output, "")).To(Succeed())
+			Expect(output.String()).To(HavePrefix("CONSOLE"))
+			Expect(output.String()).To(ContainSubstring("console-a"))
+		})
+
+		It("prints nothing when there's no history", func() {
+			output := &bytes.Buffer{}
+
+			Expect(ConsoleHistorySlice{}.PrintAs(output, "")).To(Succeed())
+			Expect(output.String()).To(BeEmpty())
+		})
+
+		It("prints a list in structured formats", func() {
+			output := &bytes.Buffer{}
+			histories := ConsoleHistorySlice{buildHistory("console-a", "alice@example.com", time.Now())}
+
+			Expect(histories.PrintAs(output, "json")).To(Succeed())
+			Expect(output.String()).To(ContainSubstring(`"kind": "ConsoleHistoryList"`))
+		})
+	})
+
+	Describe("ParseSince", func() {
+		It("parses days", func() {
+			Expect(ParseSince("7d")).To(Equal(7 * 24 * time.Hour))
+		})
+
+		It("parses Go durations", func() {
+			Expect(ParseSince("12h")).To(Equal(12 * time.Hour))
+		})
+
+		It("rejects invalid durations", func() {
+			_, err := ParseSince("a week")
+			Expect(err).To(MatchError("invalid duration: a week"))
+		})
+	})
+})
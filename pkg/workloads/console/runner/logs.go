@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// LogsOptions encapsulates the arguments to retrieve the output of a console
+type LogsOptions struct {
+	Namespace string
+	Name      string
+	// Follow the output of a running console until it exits
+	Follow bool
+	Output io.Writer
+}
+
+// errNoConsoleOutput is returned when the console's pod has gone, and its
+// output was not captured
+var errNoConsoleOutput = errors.New("console output is not available: its pod has been deleted and its output was not captured")
+
+// Logs writes the output of a console. This is read from the console's pod
+// while it exists, falling back to the output captured once the console
+// finished.
+func (c *Runner) Logs(ctx context.Context, opts LogsOptions) error {
+	csl, err := c.FindConsoleByName(opts.Namespace, opts.Name)
+	if err != nil {
+		return err
+	}
+
+	if csl.Status.PodName != "" {
+		pod, containerName, err := c.GetAttachablePod(ctx, csl)
+		if err == nil {
+			return c.streamLogs(ctx, pod, containerName, opts.Follow, opts.Output)
+		}
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get console pod: %w", err)
+		}
+	}
+
+	captured := csl.Status.CapturedOutput
+	if captured == nil {
+		return errNoConsoleOutput
+	}
+
+	secret := &corev1.Secret{}
+	err = c.kubeClient.Get(ctx, client.ObjectKey{Namespace: csl.Namespace, Name: captured.SecretName}, secret)
+	if err != nil {
+		return fmt.Errorf("failed to get captured console output: %w", err)
+	}
+
+	_, err = opts.Output.Write(secret.Data[workloadsv1alpha1.ConsoleOutputSecretKey])
+	return err
+}
@@ -0,0 +1,275 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gomodules.xyz/jsonpatch/v3"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rbacv1alpha1 "github.com/gocardless/theatre/v3/apis/rbac/v1alpha1"
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// PendingOptions encapsulates the arguments to list consoles awaiting
+// authorisation
+type PendingOptions struct {
+	// Namespace to search, or all namespaces if empty
+	Namespace string
+	// The user that would be authorising the consoles. This must match the
+	// username that the Kubernetes API recognises them as.
+	Username string
+}
+
+// PendingConsole is a console awaiting authorisation
+type PendingConsole struct {
+	// Context the console was found in, when listing across several
+	Context       string
+	Console       workloadsv1alpha1.Console
+	Rule          workloadsv1alpha1.ConsoleAuthorisationRule
+	Authorisation *workloadsv1alpha1.ConsoleAuthorisation
+}
+
+// ApprovalsNeeded returns the number of authorisations still required for the
+// console to start
+func (p PendingConsole) ApprovalsNeeded() int {
+	given := 0
+	if p.Authorisation != nil {
+		given = len(p.Authorisation.Spec.Authorisations)
+	}
+
+	if needed := p.Rule.AuthorisationsRequired - given; needed > 0 {
+		return needed
+	}
+
+	return 0
+}
+
+// selfSubjectReviewVersions are the versions of the SelfSubjectReview API to
+// try, newest first. Clusters older than 1.27 serve none of them.
+var selfSubjectReviewVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+// CurrentUser returns the username that the Kubernetes API recognises the
+// client as, by creating a SelfSubjectReview
+func (c *Runner) CurrentUser(ctx context.Context) (string, error) {
+	for _, version := range selfSubjectReviewVersions {
+		review := &unstructured.Unstructured{}
+		review.SetAPIVersion("authentication.k8s.io/" + version)
+		review.SetKind("SelfSubjectReview")
+
+		err := c.kubeClient.Create(ctx, review)
+		if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to review current user: %w", err)
+		}
+
+		username, _, _ := unstructured.NestedString(review.Object, "status", "userInfo", "username")
+		if username == "" {
+			return "", fmt.Errorf("the Kubernetes API did not return a username")
+		}
+
+		return username, nil
+	}
+
+	return "", fmt.Errorf("the Kubernetes API does not support SelfSubjectReview, so the current user can't be determined")
+}
+
+// ListPendingAuthorisation returns the consoles awaiting authorisation that the
+// given user is eligible to authorise, oldest first
+func (c *Runner) ListPendingAuthorisation(ctx context.Context, opts PendingOptions) (PendingConsoleSlice, error) {
+	var csls workloadsv1alpha1.ConsoleList
+	if err := c.kubeClient.List(ctx, &csls, &client.ListOptions{Namespace: opts.Namespace}); err != nil {
+		return nil, err
+	}
+
+	templates := map[client.ObjectKey]*workloadsv1alpha1.ConsoleTemplate{}
+
+	pending := PendingConsoleSlice{}
+	for _, csl := range csls.Items {
+		if !csl.PendingAuthorisation() {
+			continue
+		}
+
+		key := client.ObjectKey{Namespace: csl.Namespace, Name: csl.Spec.ConsoleTemplateRef.Name}
+		tpl, ok := templates[key]
+		if !ok {
+			tpl = &workloadsv1alpha1.ConsoleTemplate{}
+			if err := c.kubeClient.Get(ctx, key, tpl); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, fmt.Errorf("failed to get console template: %w", err)
+				}
+				tpl = nil
+			}
+			templates[key] = tpl
+		}
+
+		// Without the template we can't tell who may authorise the console
+		if tpl == nil {
+			continue
+		}
+
+		rule := authorisationRuleFor(&csl, tpl)
+		if rule == nil {
+			continue
+		}
+
+		var authorisation *workloadsv1alpha1.ConsoleAuthorisation
+		authz := &workloadsv1alpha1.ConsoleAuthorisation{}
+		err := c.kubeClient.Get(ctx, client.ObjectKey{Namespace: csl.Namespace, Name: csl.Name}, authz)
+		if err == nil {
+			authorisation = authz
+		} else if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get console authorisation: %w", err)
+		}
+
+		p := PendingConsole{Console: csl, Rule: *rule, Authorisation: authorisation}
+
+		eligible, err := c.canAuthorise(ctx, p, opts.Username)
+		if err != nil {
+			return nil, err
+		}
+		if eligible {
+			pending = append(pending, p)
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Console.CreationTimestamp.Before(&pending[j].Console.CreationTimestamp)
+	})
+
+	return pending, nil
+}
+
+// canAuthorise determines whether a user can authorise a pending console,
+// mirroring the checks made by the controller and the authorisation webhook
+func (c *Runner) canAuthorise(ctx context.Context, p PendingConsole, username string) (bool, error) {
+	csl := p.Console
+
+	// The authorisation webhook rejects these
+	if csl.Spec.User == username || csl.IsCollaborator(username) {
+		return false, nil
+	}
+	if p.Authorisation == nil {
+		return false, nil
+	}
+	for _, subject := range p.Authorisation.Spec.Authorisations {
+		if subject.Name == username {
+			return false, nil
+		}
+	}
+
+	// The controller binds the rule's subjects to a role allowing them to
+	// update the console authorisation. Any Google groups in the rule are
+	// expanded into their members by the DirectoryRoleBinding.
+	subjects := p.Rule.Subjects
+	rb := &rbacv1.RoleBinding{}
+	err := c.kubeClient.Get(ctx, client.ObjectKey{Namespace: csl.Namespace, Name: csl.Name + "-authorisation"}, rb)
+	switch {
+	case err == nil:
+		subjects = rb.Subjects
+	case apierrors.IsNotFound(err) || apierrors.IsForbidden(err):
+		// Fall back to the rule's subjects, whose Google groups we can't expand
+	default:
+		return false, fmt.Errorf("failed to get console authorisation rolebinding: %w", err)
+	}
+
+	needsReview := false
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			if subject.Name == username {
+				return true, nil
+			}
+		case rbacv1.GroupKind, rbacv1alpha1.GoogleGroupKind:
+			needsReview = true
+		}
+	}
+
+	// We can't see the user's membership of groups, so ask the API server
+	if needsReview {
+		return c.dryRunAuthorise(ctx, p.Authorisation, username)
+	}
+
+	return false, nil
+}
+
+// dryRunAuthorise asks the API server whether the user could authorise a
+// console, by submitting their authorisation as a dry-run. Both RBAC and the
+// authorisation webhook evaluate the authenticated user, so this fails if they
+// aren't the given user.
+func (c *Runner) dryRunAuthorise(ctx context.Context, authz *workloadsv1alpha1.ConsoleAuthorisation, username string) (bool, error) {
+	patch, err := json.Marshal([]jsonpatch.Operation{
+		jsonpatch.NewOperation("add", "/spec/authorisations/-", rbacv1.Subject{Kind: rbacv1.UserKind, Name: username}),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = c.kubeClient.Patch(ctx, authz.DeepCopy(), client.RawPatch(types.JSONPatchType, patch), client.DryRunAll)
+	switch {
+	case err == nil:
+		return true, nil
+	case strings.Contains(err.Error(), workloadsv1alpha1.ErrAuthoriserNotCurrentUser.Error()):
+		return false, fmt.Errorf("%s is not the user you are authenticated as", username)
+	case apierrors.IsForbidden(err):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to dry-run console authorisation: %w", err)
+	}
+}
+
+type PendingConsoleSlice []PendingConsole
+
+// Print writes the pending consoles to the output as a numbered table
+func (ps PendingConsoleSlice) Print(output io.Writer) error {
+	if len(ps) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+
+	// Show which cluster each console is in, when listing across several
+	withContexts := false
+	for _, p := range ps {
+		withContexts = withContexts || p.Context != ""
+	}
+
+	if withContexts {
+		fmt.Fprintf(w, "#\tCONTEXT\tNAMESPACE\tNAME\tREQUESTER\tAGE\tAPPROVALS NEEDED\tCOMMAND\tREASON\n")
+	} else {
+		fmt.Fprintf(w, "#\tNAMESPACE\tNAME\tREQUESTER\tAGE\tAPPROVALS NEEDED\tCOMMAND\tREASON\n")
+	}
+	for ix, p := range ps {
+		if withContexts {
+			fmt.Fprintf(w, "%d\t%s\t", ix+1, p.Context)
+		} else {
+			fmt.Fprintf(w, "%d\t", ix+1)
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			p.Console.Namespace,
+			p.Console.Name,
+			p.Console.Spec.User,
+			duration.HumanDuration(time.Since(p.Console.CreationTimestamp.Time)),
+			p.ApprovalsNeeded(),
+			strings.Join(p.Console.Spec.Command, " "),
+			p.Console.Spec.Reason,
+		)
+	}
+
+	return w.Flush()
+}
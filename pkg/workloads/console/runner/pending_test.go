@@ -0,0 +1,245 @@
+package runner
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	rbacv1alpha1 "github.com/gocardless/theatre/v3/apis/rbac/v1alpha1"
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// dryRunClient answers dry-run patches with patchErr, standing in for RBAC and
+// the authorisation webhook, and fails to get rolebindings with getErr
+type dryRunClient struct {
+	client.Client
+	getErr   error
+	patchErr error
+	patched  bool
+}
+
+func (c *dryRunClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*rbacv1.RoleBinding); ok && c.getErr != nil {
+		return c.getErr
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// reviewClient answers SelfSubjectReviews of the served versions with username,
+// standing in for the authentication API
+type reviewClient struct {
+	client.Client
+	served   []string
+	username string
+}
+
+func (c *reviewClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	review := obj.(*unstructured.Unstructured)
+	gvk := review.GroupVersionKind()
+	for _, version := range c.served {
+		if version == gvk.Version {
+			return unstructured.SetNestedField(review.Object, c.username, "status", "userInfo", "username")
+		}
+	}
+
+	return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+}
+
+func (c *dryRunClient) Patch(_ context.Context, _ client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	c.patched = true
+	return c.patchErr
+}
+
+var _ = Describe("PendingConsole", func() {
+	Describe("ApprovalsNeeded", func() {
+		var pending PendingConsole
+
+		BeforeEach(func() {
+			pending = PendingConsole{
+				Rule: workloadsv1alpha1.ConsoleAuthorisationRule{
+					ConsoleAuthorisers: workloadsv1alpha1.ConsoleAuthorisers{AuthorisationsRequired: 2},
+				},
+			}
+		})
+
+		Context("without a console authorisation", func() {
+			It("requires all of the rule's authorisations", func() {
+				Expect(pending.ApprovalsNeeded()).To(Equal(2))
+			})
+		})
+
+		Context("with some authorisations given", func() {
+			BeforeEach(func() {
+				pending.Authorisation = &workloadsv1alpha1.ConsoleAuthorisation{
+					Spec: workloadsv1alpha1.ConsoleAuthorisationSpec{
+						Authorisations: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+					},
+				}
+			})
+
+			It("requires the remainder", func() {
+				Expect(pending.ApprovalsNeeded()).To(Equal(1))
+			})
+		})
+	})
+})
+
+var _ = Describe("Runner", func() {
+	Describe("canAuthorise", func() {
+		type testCase struct {
+			username string
+			// Subjects of the authorisation rolebinding, which doesn't exist if nil
+			roleBindingSubjects []rbacv1.Subject
+			getErr              error
+			ruleSubjects        []rbacv1.Subject
+			authorisations      []rbacv1.Subject
+			patchErr            error
+			eligible            bool
+			err                 string
+			dryRun              bool
+		}
+
+		alice := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"}
+		group := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "sre"}
+		googleGroup := rbacv1.Subject{Kind: rbacv1alpha1.GoogleGroupKind, Name: "sre@example.com"}
+		forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "rolebindings"}, "console-authorisation", errors.New("no"))
+
+		DescribeTable("determines whether the user can authorise the console",
+			func(tc testCase) {
+				scheme := runtime.NewScheme()
+				Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+
+				objects := []client.Object{}
+				if tc.roleBindingSubjects != nil {
+					objects = append(objects, &rbacv1.RoleBinding{
+						ObjectMeta: metav1.ObjectMeta{Name: "console-authorisation", Namespace: "default"},
+						Subjects:   tc.roleBindingSubjects,
+					})
+				}
+
+				kubeClient := &dryRunClient{
+					Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+					getErr:   tc.getErr,
+					patchErr: tc.patchErr,
+				}
+				runner := &Runner{kubeClient: kubeClient}
+
+				username := tc.username
+				if username == "" {
+					username = alice.Name
+				}
+
+				eligible, err := runner.canAuthorise(context.Background(), PendingConsole{
+					Console: workloadsv1alpha1.Console{
+						ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "default"},
+						Spec: workloadsv1alpha1.ConsoleSpec{
+							User:       "bob@example.com",
+							SharedWith: []string{"carol@example.com"},
+						},
+					},
+					Rule: workloadsv1alpha1.ConsoleAuthorisationRule{
+						ConsoleAuthorisers: workloadsv1alpha1.ConsoleAuthorisers{Subjects: tc.ruleSubjects},
+					},
+					Authorisation: &workloadsv1alpha1.ConsoleAuthorisation{
+						ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "default"},
+						Spec:       workloadsv1alpha1.ConsoleAuthorisationSpec{Authorisations: tc.authorisations},
+					},
+				}, username)
+
+				if tc.err != "" {
+					Expect(err).To(MatchError(ContainSubstring(tc.err)))
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(eligible).To(Equal(tc.eligible))
+				Expect(kubeClient.patched).To(Equal(tc.dryRun))
+			},
+			Entry("the requester", testCase{
+				username:            "bob@example.com",
+				roleBindingSubjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "bob@example.com"}},
+			}),
+			Entry("a collaborator", testCase{
+				username:            "carol@example.com",
+				roleBindingSubjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol@example.com"}},
+			}),
+			Entry("an existing authoriser", testCase{
+				roleBindingSubjects: []rbacv1.Subject{alice},
+				authorisations:      []rbacv1.Subject{alice},
+			}),
+			Entry("a user in the rolebinding", testCase{
+				roleBindingSubjects: []rbacv1.Subject{alice},
+				eligible:            true,
+			}),
+			Entry("a user missing from the rolebinding", testCase{
+				roleBindingSubjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "dave@example.com"}},
+				ruleSubjects:        []rbacv1.Subject{alice},
+			}),
+			Entry("a rolebinding with a group the user is in", testCase{
+				roleBindingSubjects: []rbacv1.Subject{group},
+				eligible:            true,
+				dryRun:              true,
+			}),
+			Entry("a rolebinding with a group the user isn't in", testCase{
+				roleBindingSubjects: []rbacv1.Subject{group},
+				patchErr:            apierrors.NewForbidden(schema.GroupResource{Resource: "consoleauthorisations"}, "console", errors.New("no")),
+				dryRun:              true,
+			}),
+			Entry("a user in the rule, when the rolebinding is forbidden", testCase{
+				getErr:       forbidden,
+				ruleSubjects: []rbacv1.Subject{alice},
+				eligible:     true,
+			}),
+			Entry("a Google group in the rule, when the rolebinding is forbidden", testCase{
+				getErr:       forbidden,
+				ruleSubjects: []rbacv1.Subject{googleGroup},
+				eligible:     true,
+				dryRun:       true,
+			}),
+			Entry("a group in the rule, when the rolebinding doesn't exist", testCase{
+				ruleSubjects: []rbacv1.Subject{group},
+				eligible:     true,
+				dryRun:       true,
+			}),
+			Entry("a user other than the one authenticated", testCase{
+				getErr:       forbidden,
+				ruleSubjects: []rbacv1.Subject{googleGroup},
+				patchErr: apierrors.NewForbidden(
+					schema.GroupResource{Resource: "consoleauthorisations"}, "console",
+					errors.New("the console authorisation spec is invalid: "+workloadsv1alpha1.ErrAuthoriserNotCurrentUser.Error()),
+				),
+				err:    "alice@example.com is not the user you are authenticated as",
+				dryRun: true,
+			}),
+			Entry("a failure to get the rolebinding", testCase{
+				getErr: errors.New("connection refused"),
+				err:    "failed to get console authorisation rolebinding",
+			}),
+		)
+	})
+
+	Describe("CurrentUser", func() {
+		It("returns the username from the newest SelfSubjectReview served", func() {
+			runner := &Runner{kubeClient: &reviewClient{served: []string{"v1beta1"}, username: "alice@example.com"}}
+			Expect(runner.CurrentUser(context.Background())).To(Equal("alice@example.com"))
+		})
+
+		It("fails when no SelfSubjectReview is served", func() {
+			runner := &Runner{kubeClient: &reviewClient{}}
+			_, err := runner.CurrentUser(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("does not support SelfSubjectReview")))
+		})
+	})
+})
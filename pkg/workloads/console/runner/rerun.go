@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// RerunOptions encapsulates the arguments to re-run a console
+type RerunOptions struct {
+	Namespace string
+	Name      string
+	// Override the timeout and reason of the original console, if set
+	Timeout time.Duration
+	Reason  string
+	Attach  bool
+
+	// Options only used when Attach is true
+	KubeConfig *rest.Config
+	IO         IOStreams
+
+	// Lifecycle hook to notify when the state of the console changes
+	Hook LifecycleHook
+}
+
+// Rerun creates a new console with the same spec as an existing one, labelled
+// with the name of the original. If the authorisation rule for its command
+// allows it, the original's authorisations are carried forward to the new
+// console.
+//
+// Once the original has been garbage collected, it is re-run from the newest
+// ConsoleHistory recorded for it. Its authorisations can't be carried forward
+// from the history, so the re-run must be authorised again.
+func (c *Runner) Rerun(ctx context.Context, opts RerunOptions) (*workloadsv1alpha1.Console, error) {
+	original, err := c.FindConsoleByName(opts.Namespace, opts.Name)
+	if errors.Is(err, errConsoleNotFound) {
+		original, err = c.findConsoleInHistory(ctx, opts.Namespace, opts.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tpl := &workloadsv1alpha1.ConsoleTemplate{}
+	tplName := types.NamespacedName{Name: original.Spec.ConsoleTemplateRef.Name, Namespace: original.Namespace}
+	if err := c.kubeClient.Get(ctx, tplName, tpl); err != nil {
+		return nil, fmt.Errorf("failed to get console template: %w", err)
+	}
+
+	createOpts := rerunCreateOptions(original, opts).WithDefaults()
+	if err := createOpts.Hook.TemplateFound(tpl); err != nil {
+		return nil, err
+	}
+
+	return c.createFromTemplate(ctx, tpl, createOpts, original.Name)
+}
+
+// findConsoleInHistory returns the console recorded by the newest
+// ConsoleHistory for the given console name, with only the fields needed to
+// re-run it
+func (c *Runner) findConsoleInHistory(ctx context.Context, namespace, name string) (*workloadsv1alpha1.Console, error) {
+	var histories workloadsv1alpha1.ConsoleHistoryList
+	if err := c.kubeClient.List(ctx, &histories, &client.ListOptions{Namespace: namespace}); err != nil {
+		return nil, fmt.Errorf("failed to list console history: %w", err)
+	}
+
+	var newest *workloadsv1alpha1.ConsoleHistory
+	for i, history := range histories.Items {
+		if history.Spec.ConsoleName != name {
+			continue
+		}
+		if newest != nil && newest.Namespace != history.Namespace {
+			return nil, fmt.Errorf("too many consoles found in history with name: %s, please specify namespace", name)
+		}
+		if newest == nil || newest.Spec.CreationTime.Before(&history.Spec.CreationTime) {
+			newest = &histories.Items[i]
+		}
+	}
+
+	if newest == nil {
+		return nil, fmt.Errorf("%w with name: %s, nor in console history", errConsoleNotFound, name)
+	}
+
+	return &workloadsv1alpha1.Console{
+		ObjectMeta: metav1.ObjectMeta{Name: newest.Spec.ConsoleName, Namespace: newest.Namespace},
+		Spec: workloadsv1alpha1.ConsoleSpec{
+			User:               newest.Spec.User,
+			Reason:             newest.Spec.Reason,
+			Command:            newest.Spec.Command,
+			TimeoutSeconds:     newest.Spec.TimeoutSeconds,
+			Noninteractive:     newest.Spec.Noninteractive,
+			SharedWith:         newest.Spec.SharedWith,
+			ConsoleTemplateRef: newest.Spec.ConsoleTemplateRef,
+		},
+	}, nil
+}
+
+// rerunCreateOptions returns the options to create a console with the same
+// spec as the original, applying any overrides
+func rerunCreateOptions(original *workloadsv1alpha1.Console, opts RerunOptions) CreateOptions {
+	createOpts := CreateOptions{
+		Namespace:      original.Namespace,
+		Timeout:        time.Duration(original.Spec.TimeoutSeconds) * time.Second,
+		Reason:         original.Spec.Reason,
+		Command:        original.Spec.Command,
+		Noninteractive: original.Spec.Noninteractive,
+		SharedWith:     original.Spec.SharedWith,
+		Attach:         opts.Attach,
+		KubeConfig:     opts.KubeConfig,
+		IO:             opts.IO,
+		Hook:           opts.Hook,
+	}
+
+	if opts.Timeout != 0 {
+		createOpts.Timeout = opts.Timeout
+	}
+	if opts.Reason != "" {
+		createOpts.Reason = opts.Reason
+	}
+
+	return createOpts
+}
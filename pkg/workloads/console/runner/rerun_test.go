@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("Rerun", func() {
+	var original *workloadsv1alpha1.Console
+
+	BeforeEach(func() {
+		original = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{Name: "template-abcde", Namespace: "default"},
+			Spec: workloadsv1alpha1.ConsoleSpec{
+				User:               "alice@example.com",
+				Reason:             "Fix a payment",
+				Command:            []string{"rake", "payments:fix"},
+				TimeoutSeconds:     600,
+				Noninteractive:     true,
+				SharedWith:         []string{"bob@example.com"},
+				ConsoleTemplateRef: corev1.LocalObjectReference{Name: "template"},
+			},
+		}
+	})
+
+	Describe("rerunCreateOptions", func() {
+		It("clones the spec of the original console", func() {
+			opts := rerunCreateOptions(original, RerunOptions{Attach: true})
+
+			Expect(opts.Namespace).To(Equal("default"))
+			Expect(opts.Reason).To(Equal("Fix a payment"))
+			Expect(opts.Command).To(Equal([]string{"rake", "payments:fix"}))
+			Expect(opts.Timeout).To(Equal(10 * time.Minute))
+			Expect(opts.Noninteractive).To(BeTrue())
+			Expect(opts.SharedWith).To(ConsistOf("bob@example.com"))
+			Expect(opts.Attach).To(BeTrue())
+		})
+
+		It("applies overrides", func() {
+			opts := rerunCreateOptions(original, RerunOptions{Timeout: time.Hour, Reason: "Try again"})
+
+			Expect(opts.Timeout).To(Equal(time.Hour))
+			Expect(opts.Reason).To(Equal("Try again"))
+		})
+	})
+
+	Describe("buildConsole", func() {
+		It("links the console to the original", func() {
+			template := workloadsv1alpha1.ConsoleTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "template",
+					Labels: map[string]string{"app": "payments"},
+				},
+			}
+			csl := buildConsole("default", template, Options{Cmd: original.Spec.Command, RerunOf: original.Name})
+
+			Expect(csl.Labels).To(HaveKeyWithValue(workloadsv1alpha1.ConsoleRerunOfLabel, "template-abcde"))
+			Expect(csl.Labels).To(HaveKeyWithValue("app", "payments"))
+			Expect(template.Labels).NotTo(HaveKey(workloadsv1alpha1.ConsoleRerunOfLabel))
+		})
+	})
+
+	Describe("findConsoleInHistory", func() {
+		var (
+			histories []client.Object
+			csl       *workloadsv1alpha1.Console
+			err       error
+		)
+
+		history := func(name, namespace, reason string, created time.Time) *workloadsv1alpha1.ConsoleHistory {
+			return &workloadsv1alpha1.ConsoleHistory{
+				ObjectMeta: metav1.ObjectMeta{Name: name + "-" + reason, Namespace: namespace},
+				Spec: workloadsv1alpha1.ConsoleHistorySpec{
+					ConsoleName:        name,
+					User:               "alice@example.com",
+					Reason:             reason,
+					Command:            []string{"rake", "payments:fix"},
+					TimeoutSeconds:     600,
+					ConsoleTemplateRef: corev1.LocalObjectReference{Name: "template"},
+					CreationTime:       metav1.NewTime(created),
+				},
+			}
+		}
+
+		created := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		BeforeEach(func() {
+			histories = []client.Object{
+				history("template-abcde", "default", "first", created),
+				history("template-abcde", "default", "second", created.Add(time.Hour)),
+				history("template-fghij", "default", "other", created.Add(2*time.Hour)),
+			}
+		})
+
+		JustBeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(workloadsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+			runner := &Runner{kubeClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(histories...).Build()}
+			csl, err = runner.findConsoleInHistory(context.Background(), "", "template-abcde")
+		})
+
+		It("returns the console recorded by the newest history", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(csl.Name).To(Equal("template-abcde"))
+			Expect(csl.Namespace).To(Equal("default"))
+			Expect(csl.Spec.Reason).To(Equal("second"))
+			Expect(csl.Spec.Command).To(Equal([]string{"rake", "payments:fix"}))
+			Expect(csl.Spec.ConsoleTemplateRef.Name).To(Equal("template"))
+		})
+
+		Context("when consoles with the name were in several namespaces", func() {
+			BeforeEach(func() {
+				histories = append(histories, history("template-abcde", "staging", "third", created))
+			})
+
+			It("asks for the namespace", func() {
+				Expect(err).To(MatchError(ContainSubstring("please specify namespace")))
+			})
+		})
+
+		Context("when there is no history of the console", func() {
+			BeforeEach(func() {
+				histories = histories[2:]
+			})
+
+			It("returns not found", func() {
+				Expect(err).To(MatchError(errConsoleNotFound))
+			})
+		})
+	})
+})
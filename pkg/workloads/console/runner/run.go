@@ -0,0 +1,206 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+// Phases reported in the status of a console being run
+const (
+	RunPhaseCreated              = "Created"
+	RunPhasePendingAuthorisation = "PendingAuthorisation"
+	RunPhaseRunning              = "Running"
+	RunPhaseSucceeded            = "Succeeded"
+	RunPhaseFailed               = "Failed"
+)
+
+// RunOptions encapsulates the arguments to run a non-interactive console to
+// completion
+type RunOptions struct {
+	Namespace  string
+	Selector   string
+	Timeout    time.Duration
+	Reason     string
+	Command    []string
+	SharedWith []string
+
+	// Maximum time to wait for the console to be authorised and start running.
+	// If unset we wait until interrupted.
+	StartTimeout time.Duration
+
+	// The console's output is streamed to Out, while its status is written to
+	// ErrOut as a JSON object per line
+	IO IOStreams
+}
+
+// RunStatus is the machine-readable status of a console being run, written
+// whenever it changes phase
+type RunStatus struct {
+	Time              time.Time `json:"time"`
+	Phase             string    `json:"phase"`
+	Namespace         string    `json:"namespace,omitempty"`
+	Console           string    `json:"console,omitempty"`
+	AuthorisationRule string    `json:"authorisationRule,omitempty"`
+	ExitCode          *int32    `json:"exitCode,omitempty"`
+	Error             string    `json:"error,omitempty"`
+}
+
+// RunResult is the outcome of running a console
+type RunResult struct {
+	Console *workloadsv1alpha1.Console
+	// Exit code of the console's container, or 1 if the console failed without
+	// its container reporting one
+	ExitCode int32
+}
+
+// Run creates a non-interactive console, waits for it to be authorised and
+// start, then streams its output until the container exits. Unless the console
+// could not be created, the returned result holds the exit code of the
+// container, and any error is also reported in the final status.
+func (c *Runner) Run(ctx context.Context, opts RunOptions) (*RunResult, error) {
+	statuses := &runStatusWriter{output: opts.IO.ErrOut}
+
+	result, err := c.run(ctx, opts, statuses)
+
+	final := RunStatus{Phase: RunPhaseSucceeded}
+	if result != nil {
+		final.ExitCode = &result.ExitCode
+		if result.ExitCode != 0 {
+			final.Phase = RunPhaseFailed
+		}
+	}
+	if err != nil {
+		final.Phase = RunPhaseFailed
+		final.Error = err.Error()
+	}
+	statuses.write(final)
+
+	return result, err
+}
+
+func (c *Runner) run(ctx context.Context, opts RunOptions, statuses *runStatusWriter) (*RunResult, error) {
+	startCtx := ctx
+	if opts.StartTimeout > 0 {
+		var cancel context.CancelFunc
+		startCtx, cancel = context.WithTimeout(ctx, opts.StartTimeout)
+		defer cancel()
+	}
+
+	csl, err := c.Create(startCtx, CreateOptions{
+		Namespace:      opts.Namespace,
+		Selector:       opts.Selector,
+		Timeout:        opts.Timeout,
+		Reason:         opts.Reason,
+		Command:        opts.Command,
+		Noninteractive: true,
+		SharedWith:     opts.SharedWith,
+		Hook:           statuses,
+	})
+	if err != nil {
+		if statuses.console == nil {
+			return nil, err
+		}
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("console did not start within %s: %w", opts.StartTimeout, err)
+		}
+
+		return &RunResult{Console: statuses.console, ExitCode: 1}, err
+	}
+
+	result := &RunResult{Console: csl, ExitCode: 1}
+
+	pod, containerName, err := c.GetAttachablePod(ctx, csl)
+	if err != nil {
+		return result, fmt.Errorf("could not find console pod: %w", err)
+	}
+
+	if err := c.streamLogs(ctx, pod, containerName, true, opts.IO.Out); err != nil {
+		return result, fmt.Errorf("failed to stream console output: %w", err)
+	}
+
+	waitErr := c.waitForSuccess(ctx, csl)
+
+	// The pod reports the container's exit code, in which case a non-zero code
+	// is the outcome of the console rather than a failure to run it. If the pod
+	// has gone then we fall back to the outcome of waiting for it.
+	pod, _, err = c.GetAttachablePod(ctx, csl)
+	if err == nil {
+		if exitCode, terminated := containerExitCode(pod, containerName); terminated {
+			result.ExitCode = exitCode
+			return result, nil
+		}
+	}
+	if waitErr != nil {
+		return result, waitErr
+	}
+
+	result.ExitCode = 0
+	return result, nil
+}
+
+// runStatusWriter is a lifecycle hook that writes the status of a console as it
+// progresses, as JSON
+type runStatusWriter struct {
+	DefaultLifecycleHook
+
+	output  io.Writer
+	console *workloadsv1alpha1.Console
+}
+
+func (w *runStatusWriter) ConsoleCreated(csl *workloadsv1alpha1.Console) error {
+	w.console = csl
+	w.write(RunStatus{Phase: RunPhaseCreated})
+	return nil
+}
+
+func (w *runStatusWriter) ConsoleRequiresAuthorisation(csl *workloadsv1alpha1.Console, rule *workloadsv1alpha1.ConsoleAuthorisationRule) error {
+	w.write(RunStatus{Phase: RunPhasePendingAuthorisation, AuthorisationRule: rule.Name})
+	return nil
+}
+
+func (w *runStatusWriter) ConsoleReady(csl *workloadsv1alpha1.Console) error {
+	w.console = csl
+	w.write(RunStatus{Phase: RunPhaseRunning})
+	return nil
+}
+
+// write outputs the status, populated with the time and the console, if it has
+// been created
+func (w *runStatusWriter) write(status RunStatus) {
+	if w.output == nil {
+		return
+	}
+
+	status.Time = time.Now().UTC()
+	if w.console != nil {
+		status.Namespace = w.console.Namespace
+		status.Console = w.console.Name
+	}
+
+	// A failure to write the status shouldn't fail the console itself
+	_ = json.NewEncoder(w.output).Encode(status)
+}
+
+// streamLogs copies the logs of the console container to the output. When
+// following, this blocks until the container exits.
+func (c *Runner) streamLogs(ctx context.Context, pod *corev1.Pod, containerName string, follow bool, output io.Writer) error {
+	pods := c.clientset.CoreV1().Pods(pod.Namespace)
+
+	logs, err := pods.GetLogs(pod.Name, &corev1.PodLogOptions{Container: containerName, Follow: follow}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer logs.Close()
+
+	_, err = io.Copy(output, logs)
+	return err
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("runStatusWriter", func() {
+	var (
+		output   *bytes.Buffer
+		statuses *runStatusWriter
+		csl      *workloadsv1alpha1.Console
+	)
+
+	readStatuses := func() []RunStatus {
+		result := []RunStatus{}
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			var status RunStatus
+			Expect(json.Unmarshal(scanner.Bytes(), &status)).To(Succeed())
+			result = append(result, status)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		statuses = &runStatusWriter{output: output}
+		csl = &workloadsv1alpha1.Console{
+			ObjectMeta: metav1.ObjectMeta{Name: "template-abcde", Namespace: "default"},
+		}
+	})
+
+	It("writes a JSON object per line as the console progresses", func() {
+		Expect(statuses.ConsoleCreated(csl)).To(Succeed())
+		Expect(statuses.ConsoleRequiresAuthorisation(csl, &workloadsv1alpha1.ConsoleAuthorisationRule{Name: "rails"})).To(Succeed())
+		Expect(statuses.ConsoleReady(csl)).To(Succeed())
+
+		exitCode := int32(3)
+		statuses.write(RunStatus{Phase: RunPhaseFailed, ExitCode: &exitCode})
+
+		written := readStatuses()
+		Expect(written).To(HaveLen(4))
+
+		Expect(written[0].Phase).To(Equal(RunPhaseCreated))
+		Expect(written[0].Namespace).To(Equal("default"))
+		Expect(written[0].Console).To(Equal("template-abcde"))
+		Expect(written[0].Time).NotTo(BeZero())
+
+		Expect(written[1].Phase).To(Equal(RunPhasePendingAuthorisation))
+		Expect(written[1].AuthorisationRule).To(Equal("rails"))
+
+		Expect(written[2].Phase).To(Equal(RunPhaseRunning))
+
+		Expect(written[3].Phase).To(Equal(RunPhaseFailed))
+		Expect(written[3].ExitCode).NotTo(BeNil())
+		Expect(*written[3].ExitCode).To(BeEquivalentTo(3))
+	})
+
+	It("omits the console before it has been created", func() {
+		statuses.write(RunStatus{Phase: RunPhaseFailed, Error: "no template found"})
+
+		Expect(output.String()).NotTo(ContainSubstring(`"console"`))
+		Expect(readStatuses()[0].Error).To(Equal("no template found"))
+	})
+})
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"reflect"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
@@ -15,12 +18,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -54,6 +60,13 @@ type Options struct {
 	// should be set to false but some execution environments, eg
 	// Tekton, do not like attaching to TTY-enabled pods.
 	Noninteractive bool
+	// Users to share the console with, who will be able to attach to it
+	// alongside the owner
+	SharedWith []string
+	// Name of the console that this console re-runs, if any
+	RerunOf string
+	// Batch to request that the console is added to, if any
+	Batch string
 }
 
 // New builds a runner
@@ -151,6 +164,7 @@ type CreateOptions struct {
 	Command        []string
 	Attach         bool
 	Noninteractive bool
+	SharedWith     []string
 
 	// Options only used when Attach is true
 	KubeConfig *rest.Config
@@ -185,7 +199,20 @@ func (c *Runner) Create(ctx context.Context, opts CreateOptions) (*workloadsv1al
 		return nil, err
 	}
 
-	opt := Options{Cmd: opts.Command, Timeout: int(opts.Timeout.Seconds()), Reason: opts.Reason, Noninteractive: opts.Noninteractive}
+	return c.createFromTemplate(ctx, tpl, opts, "")
+}
+
+// createFromTemplate creates a console from the template, waiting for it to be
+// authorised and become ready, before optionally attaching to it
+func (c *Runner) createFromTemplate(ctx context.Context, tpl *workloadsv1alpha1.ConsoleTemplate, opts CreateOptions, rerunOf string) (*workloadsv1alpha1.Console, error) {
+	opt := Options{
+		Cmd:            opts.Command,
+		Timeout:        int(opts.Timeout.Seconds()),
+		Reason:         opts.Reason,
+		Noninteractive: opts.Noninteractive,
+		SharedWith:     opts.SharedWith,
+		RerunOf:        rerunOf,
+	}
 	csl, err := c.CreateResource(tpl.Namespace, *tpl, opt)
 	if err != nil {
 		return nil, err
@@ -382,14 +409,39 @@ func (c *Runner) Attach(ctx context.Context, opts AttachOptions) error {
 		return err
 	}
 
-	var attacher Attacher
-	if !csl.Spec.Noninteractive {
-		attacher = newInteractiveAttacher(c.clientset, opts.KubeConfig)
-	} else {
-		attacher = newNoninteractiveAttacher(c.clientset, opts.KubeConfig)
+	backoff := reconnectBackoff
+	for {
+		activity := &streamActivity{}
+
+		var attacher Attacher
+		if !csl.Spec.Noninteractive {
+			attacher = newInteractiveAttacher(c.clientset, opts.KubeConfig, activity)
+		} else {
+			attacher = newNoninteractiveAttacher(c.clientset, opts.KubeConfig, activity)
+		}
+
+		err = attacher.Attach(ctx, pod, containerName, opts.IO)
+
+		// Only interactive sessions are resumed after losing the connection, as
+		// a non-interactive console's output may have been missed in the
+		// meantime.
+		if err == nil || csl.Spec.Noninteractive || ctx.Err() != nil || !isTransportError(err) {
+			break
+		}
+
+		// If the session was established then this is a fresh disconnection,
+		// rather than a failure to reconnect after a previous one
+		if activity.transferred() {
+			backoff = reconnectBackoff
+		}
+
+		reconnectPod, reconnectContainerName, ok := c.waitToReattach(ctx, csl, &backoff, err, opts.IO)
+		if !ok {
+			break
+		}
+		pod, containerName = reconnectPod, reconnectContainerName
 	}
 
-	err = attacher.Attach(ctx, pod, containerName, opts.IO)
 	if err != nil {
 		// If this is true, it is likely that the pod has already terminated for whatever
 		// reason - very often because a command has run so quickly that by the time waitForConsole
@@ -415,18 +467,113 @@ func (c *Runner) Attach(ctx context.Context, opts AttachOptions) error {
 	return c.waitForSuccess(ctx, csl)
 }
 
-func (c *Runner) extractLogs(ctx context.Context, csl *workloadsv1alpha1.Console, pod *corev1.Pod, containerName string, streams IOStreams) error {
-	pods := c.clientset.CoreV1().Pods(pod.Namespace)
+// reconnectBackoff controls how often, and for how long, we try to reattach to
+// an interactive console after losing the connection to it
+var reconnectBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    8,
+	Cap:      30 * time.Second,
+}
 
-	logs, err := pods.GetLogs(pod.Name, &corev1.PodLogOptions{Container: containerName}).Stream(ctx)
-	if err != nil {
-		return err
+// transportErrorMessages are found in the errors caused by losing the
+// connection to a console. client-go formats most of the errors from the
+// underlying connection into strings, so they can't all be matched by type.
+var transportErrorMessages = []string{
+	"error reading from error stream",
+	"connection reset by peer",
+	"broken pipe",
+	"use of closed network connection",
+	"unexpected EOF",
+	"i/o timeout",
+	"connection refused",
+	"no route to host",
+	"network is unreachable",
+	"TLS handshake timeout",
+}
+
+// isTransportError returns true if an attach failed due to losing the
+// connection to the console, rather than the process in the console exiting or
+// the API server rejecting the request, neither of which reattaching can fix
+func isTransportError(err error) bool {
+	// Context errors also satisfy net.Error, but mean we're giving up
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
 	}
 
-	defer logs.Close()
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
 
-	_, err = io.Copy(streams.Out, logs)
-	if err != nil {
+	// Requests the API server refused, such as those denied by the attach
+	// webhook, fail to upgrade the connection
+	var statusErr apierrors.APIStatus
+	if errors.As(err, &statusErr) || strings.Contains(err.Error(), "unable to upgrade connection") {
+		return false
+	}
+
+	for _, message := range transportErrorMessages {
+		if strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitToReattach waits, with backoff, for a console to become attachable
+// again after losing the connection to it. It returns false if the console is
+// no longer running, or if the backoff is exhausted. The terminal has been
+// restored by the time the attacher returns, so it's safe to print notices.
+func (c *Runner) waitToReattach(ctx context.Context, csl *workloadsv1alpha1.Console, backoff *wait.Backoff, attachErr error, streams IOStreams) (*corev1.Pod, string, bool) {
+	printf := func(format string, a ...interface{}) {
+		if streams.ErrOut != nil {
+			fmt.Fprintf(streams.ErrOut, format, a...)
+		}
+	}
+
+	printf("\r\nLost connection to console %s: %v\r\n", csl.Name, attachErr)
+
+	for backoff.Steps > 0 {
+		delay := backoff.Step()
+		printf("Reconnecting in %s...\r\n", delay.Round(time.Second))
+
+		select {
+		case <-ctx.Done():
+			return nil, "", false
+		case <-time.After(delay):
+		}
+
+		// Errors are expected here while the network is unavailable, so keep
+		// retrying until we've determined whether the console is still running
+		latest, err := c.FindConsoleByName(csl.Namespace, csl.Name)
+		if err != nil {
+			continue
+		}
+
+		if !latest.Running() {
+			printf("Console %s is no longer running (phase: %s)\r\n", latest.Name, latest.Status.Phase)
+			return nil, "", false
+		}
+
+		pod, containerName, err := c.GetAttachablePod(ctx, latest)
+		if err != nil {
+			continue
+		}
+
+		printf("Reconnected to console %s\r\n", latest.Name)
+		return pod, containerName, true
+	}
+
+	printf("Giving up reconnecting to console %s\r\n", csl.Name)
+	return nil, "", false
+}
+
+func (c *Runner) extractLogs(ctx context.Context, csl *workloadsv1alpha1.Console, pod *corev1.Pod, containerName string, streams IOStreams) error {
+	if err := c.streamLogs(ctx, pod, containerName, false, streams.Out); err != nil {
 		return err
 	}
 
@@ -434,8 +581,8 @@ func (c *Runner) extractLogs(ctx context.Context, csl *workloadsv1alpha1.Console
 	return c.waitForSuccess(ctx, csl)
 }
 
-func newInteractiveAttacher(clientset kubernetes.Interface, restconfig *rest.Config) Attacher {
-	return &interactiveAttacher{clientset, restconfig}
+func newInteractiveAttacher(clientset kubernetes.Interface, restconfig *rest.Config, activity *streamActivity) Attacher {
+	return &interactiveAttacher{clientset, restconfig, activity}
 }
 
 type Attacher interface {
@@ -447,6 +594,7 @@ type Attacher interface {
 type interactiveAttacher struct {
 	clientset  kubernetes.Interface
 	restconfig *rest.Config
+	activity   *streamActivity
 }
 
 // Attach will interactively attach to a container's output, creating a new TTY
@@ -476,7 +624,7 @@ func (a *interactiveAttacher) Attach(ctx context.Context, pod *corev1.Pod, conta
 
 	streamOptions, safe := CreateInteractiveStreamOptions(streams)
 
-	return safe(func() error { return remoteExecutor.Stream(streamOptions) })
+	return safe(func() error { return remoteExecutor.Stream(a.activity.wrap(streamOptions)) })
 }
 
 // CreateInteractiveStreamOptions constructs streaming configuration that
@@ -513,10 +661,11 @@ func CreateInteractiveStreamOptions(streams IOStreams) (remotecommand.StreamOpti
 type noninteractiveAttacher struct {
 	clientset  kubernetes.Interface
 	restconfig *rest.Config
+	activity   *streamActivity
 }
 
-func newNoninteractiveAttacher(clientset kubernetes.Interface, restconfig *rest.Config) Attacher {
-	return &noninteractiveAttacher{clientset, restconfig}
+func newNoninteractiveAttacher(clientset kubernetes.Interface, restconfig *rest.Config, activity *streamActivity) Attacher {
+	return &noninteractiveAttacher{clientset, restconfig, activity}
 }
 
 // Attach will attach to a container's output.
@@ -550,7 +699,62 @@ func (a *noninteractiveAttacher) Attach(ctx context.Context, pod *corev1.Pod, co
 		Tty:    false,
 	}
 
-	return remoteExecutor.Stream(streamOptions)
+	return remoteExecutor.Stream(a.activity.wrap(streamOptions))
+}
+
+// streamActivity records whether any data has been relayed between the user
+// and a console while attached to it. The stream options are wrapped, rather
+// than the IOStreams themselves, as setting up a TTY requires the original
+// file descriptors.
+type streamActivity struct {
+	relayed int32
+}
+
+func (a *streamActivity) wrap(opts remotecommand.StreamOptions) remotecommand.StreamOptions {
+	if opts.Stdin != nil {
+		opts.Stdin = &activityReader{opts.Stdin, a}
+	}
+	if opts.Stdout != nil {
+		opts.Stdout = &activityWriter{opts.Stdout, a}
+	}
+	if opts.Stderr != nil {
+		opts.Stderr = &activityWriter{opts.Stderr, a}
+	}
+
+	return opts
+}
+
+func (a *streamActivity) record(n int) {
+	if n > 0 {
+		atomic.StoreInt32(&a.relayed, 1)
+	}
+}
+
+// transferred returns true if any data has been relayed in either direction
+func (a *streamActivity) transferred() bool {
+	return atomic.LoadInt32(&a.relayed) == 1
+}
+
+type activityReader struct {
+	io.Reader
+	activity *streamActivity
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.activity.record(n)
+	return n, err
+}
+
+type activityWriter struct {
+	io.Writer
+	activity *streamActivity
+}
+
+func (w *activityWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.activity.record(n)
+	return n, err
 }
 
 type AuthoriseOptions struct {
@@ -653,26 +857,56 @@ type ListOptions struct {
 	Username  string
 	Selector  string
 	Output    io.Writer
+	// Format of the output, as accepted by NewConsolePrinter
+	OutputFormat string
 }
 
 // List is a wrapper around ListConsolesByLabelsAndUser that will output to a specified output.
 // This functionality is intended to be used in a CLI setting, where you are usually outputting to os.Stdout.
 func (c *Runner) List(ctx context.Context, opts ListOptions) (ConsoleSlice, error) {
+	// Check the output format before making any requests
+	if _, err := NewConsolePrinter(opts.OutputFormat); err != nil {
+		return nil, err
+	}
+
 	consoles, err := c.ListConsolesByLabelsAndUser(opts.Namespace, opts.Username, opts.Selector)
 	if err != nil {
 		return nil, err
 	}
 
-	return consoles, consoles.Print(opts.Output)
+	return consoles, consoles.PrintAs(opts.Output, opts.OutputFormat)
 }
 
 // CreateResource builds a console according to the supplied options and submits it to the API
 func (c *Runner) CreateResource(namespace string, template workloadsv1alpha1.ConsoleTemplate, opts Options) (*workloadsv1alpha1.Console, error) {
-	csl := &workloadsv1alpha1.Console{
+	csl := buildConsole(namespace, template, opts)
+
+	err := c.kubeClient.Create(
+		context.TODO(),
+		csl,
+	)
+	return csl, err
+}
+
+// buildConsole builds a console according to the supplied options
+func buildConsole(namespace string, template workloadsv1alpha1.ConsoleTemplate, opts Options) *workloadsv1alpha1.Console {
+	consoleLabels := labels.Merge(labels.Set{}, template.Labels)
+	if opts.RerunOf != "" {
+		consoleLabels[workloadsv1alpha1.ConsoleRerunOfLabel] = opts.RerunOf
+	}
+
+	// The batch label is set by the console authenticator webhook
+	var annotations map[string]string
+	if opts.Batch != "" {
+		annotations = map[string]string{workloadsv1alpha1.ConsoleBatchAnnotation: opts.Batch}
+	}
+
+	return &workloadsv1alpha1.Console{
 		ObjectMeta: metav1.ObjectMeta{
 			// Let Kubernetes generate a unique name
 			GenerateName: template.Name + "-",
-			Labels:       labels.Merge(labels.Set{}, template.Labels),
+			Labels:       consoleLabels,
+			Annotations:  annotations,
 			Namespace:    namespace,
 		},
 		Spec: workloadsv1alpha1.ConsoleSpec{
@@ -683,14 +917,9 @@ func (c *Runner) CreateResource(namespace string, template workloadsv1alpha1.Con
 			Command:        opts.Cmd,
 			Reason:         opts.Reason,
 			Noninteractive: opts.Noninteractive,
+			SharedWith:     opts.SharedWith,
 		},
 	}
-
-	err := c.kubeClient.Create(
-		context.TODO(),
-		csl,
-	)
-	return csl, err
 }
 
 // MultipleConsoleTemplateError is returned whenever our selector was too broad, and
@@ -715,6 +944,23 @@ func (e MultipleConsoleTemplateError) Error() string {
 // selector and return errors if none or multiple are found (when the selector
 // is too broad)
 func (c *Runner) FindTemplateBySelector(namespace string, labelSelector string) (*workloadsv1alpha1.ConsoleTemplate, error) {
+	templates, err := c.FindTemplatesBySelector(namespace, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(templates) != 1 {
+		return nil, MultipleConsoleTemplateError{templates}
+	}
+
+	template := templates[0]
+
+	return &template, nil
+}
+
+// FindTemplatesBySelector returns all the templates matching the given label
+// selector, across all namespaces if namespace is empty
+func (c *Runner) FindTemplatesBySelector(namespace string, labelSelector string) ([]workloadsv1alpha1.ConsoleTemplate, error) {
 	var templates workloadsv1alpha1.ConsoleTemplateList
 	selectorSet, err := labels.ConvertSelectorToLabelsMap(labelSelector)
 	if err != nil {
@@ -727,13 +973,7 @@ func (c *Runner) FindTemplateBySelector(namespace string, labelSelector string)
 		return nil, fmt.Errorf("failed to list consoles templates: %w", err)
 	}
 
-	if len(templates.Items) != 1 {
-		return nil, MultipleConsoleTemplateError{templates.Items}
-	}
-
-	template := templates.Items[0]
-
-	return &template, nil
+	return templates.Items, nil
 }
 
 func (c *Runner) FindConsoleByName(namespace, name string) (*workloadsv1alpha1.Console, error) {
@@ -757,7 +997,7 @@ func (c *Runner) FindConsoleByName(namespace, name string) (*workloadsv1alpha1.C
 	}
 
 	if len(matchingConsoles) == 0 {
-		return nil, fmt.Errorf("no consoles found with name: %s", name)
+		return nil, fmt.Errorf("%w with name: %s", errConsoleNotFound, name)
 	}
 	if len(matchingConsoles) > 1 {
 		return nil, fmt.Errorf("too many consoles found with name: %s, please specify namespace", name)
@@ -768,17 +1008,130 @@ func (c *Runner) FindConsoleByName(namespace, name string) (*workloadsv1alpha1.C
 
 type ConsoleSlice []workloadsv1alpha1.Console
 
+// Print writes the consoles to the output as a table
 func (cs ConsoleSlice) Print(output io.Writer) error {
-	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	return cs.PrintAs(output, "")
+}
 
-	if len(cs) == 0 {
-		return nil
+// PrintAs writes the consoles to the output in the given format, as accepted by
+// NewConsolePrinter. Structured formats print a ConsoleList.
+func (cs ConsoleSlice) PrintAs(output io.Writer, format string) error {
+	printer, err := NewConsolePrinter(format)
+	if err != nil {
+		return err
 	}
 
+	if table, ok := printer.(tablePrinter); ok {
+		// Don't print table headers when there's nothing to list
+		if len(cs) == 0 {
+			return nil
+		}
+
+		// Show which cluster each console is in, when listing across several
+		if cs.hasContexts() {
+			printer = tablePrinter{columns: contextColumn + "," + table.columns}
+		}
+	}
+
+	list := &workloadsv1alpha1.ConsoleList{Items: []workloadsv1alpha1.Console{}}
+	list.SetGroupVersionKind(workloadsv1alpha1.GroupVersion.WithKind("ConsoleList"))
+	for _, csl := range cs {
+		list.Items = append(list.Items, *withConsoleKind(&csl))
+	}
+
+	return printer.PrintObj(list, output)
+}
+
+// PrintConsole writes a single console to the output in the given format, as
+// accepted by NewConsolePrinter
+func PrintConsole(output io.Writer, format string, csl *workloadsv1alpha1.Console) error {
+	printer, err := NewConsolePrinter(format)
+	if err != nil {
+		return err
+	}
+
+	return printer.PrintObj(withConsoleKind(csl), output)
+}
+
+// Supported output formats for consoles, in addition to the default table
+// format and jsonpath=<template>
+const (
+	OutputWide = "wide"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+	OutputName = "name"
+)
+
+const (
+	contextColumn      = `CONTEXT:.metadata.annotations.workloads\.crd\.gocardless\.com/context`
+	consoleColumns     = "NAME:.metadata.name,NAMESPACE:.metadata.namespace,PHASE:.status.phase,CREATED:.metadata.creationTimestamp,USER:.spec.user,REASON:.spec.reason"
+	consoleWideColumns = consoleColumns + ",TEMPLATE:.spec.consoleTemplateRef.name,POD:.status.podName,EXPIRY:.status.expiryTime,COMMAND:.spec.command"
+)
+
+// NewConsolePrinter returns a printer for consoles in the given output format,
+// which is one of: empty for a table, wide, json, yaml, name or
+// jsonpath=<template>.
+func NewConsolePrinter(format string) (printers.ResourcePrinter, error) {
+	return newPrinter(format, consoleColumns, consoleWideColumns)
+}
+
+// newPrinter returns a printer in the given output format, printing tables
+// with the given columns
+func newPrinter(format, columns, wideColumns string) (printers.ResourcePrinter, error) {
+	switch {
+	case format == "":
+		return tablePrinter{columns: columns}, nil
+	case format == OutputWide:
+		return tablePrinter{columns: wideColumns}, nil
+	case format == OutputJSON:
+		return &printers.JSONPrinter{}, nil
+	case format == OutputYAML:
+		return &printers.YAMLPrinter{}, nil
+	case format == OutputName:
+		return listItemPrinter{&printers.NamePrinter{}}, nil
+	case strings.HasPrefix(format, "jsonpath="):
+		printer, err := printers.NewJSONPathPrinter(strings.TrimPrefix(format, "jsonpath="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonpath template: %w", err)
+		}
+		printer.AllowMissingKeys(true)
+		return printer, nil
+	}
+
+	return nil, fmt.Errorf("unsupported output format: %s", format)
+}
+
+// hasContexts returns whether any of the consoles are annotated with the
+// context they were found in
+func (cs ConsoleSlice) hasContexts() bool {
+	for _, csl := range cs {
+		if _, ok := csl.Annotations[ContextAnnotation]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withConsoleKind returns a copy of the console with its kind set, which is
+// required by the structured printers. This isn't populated by the client when
+// fetching typed objects.
+func withConsoleKind(csl *workloadsv1alpha1.Console) *workloadsv1alpha1.Console {
+	csl = csl.DeepCopy()
+	csl.SetGroupVersionKind(workloadsv1alpha1.GroupVersion.WithKind("Console"))
+	return csl
+}
+
+// tablePrinter prints objects as a table with the given custom columns
+type tablePrinter struct {
+	columns string
+}
+
+func (p tablePrinter) PrintObj(obj runtime.Object, output io.Writer) error {
 	decoder := scheme.Codecs.UniversalDecoder(scheme.Scheme.PrioritizedVersionsAllGroups()...)
 
 	printer, err := get.NewCustomColumnsPrinterFromSpec(
-		"NAME:.metadata.name,NAMESPACE:.metadata.namespace,PHASE:.status.phase,CREATED:.metadata.creationTimestamp,USER:.spec.user,REASON:.spec.reason",
+		p.columns,
 		decoder,
 		false, // false => print headers
 	)
@@ -786,12 +1139,36 @@ func (cs ConsoleSlice) Print(output io.Writer) error {
 		return err
 	}
 
-	for _, cnsl := range cs {
-		printer.PrintObj(&cnsl, w)
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	if err := printer.PrintObj(obj, w); err != nil {
+		return err
 	}
 
 	// Flush the printed buffer to output
-	w.Flush()
+	return w.Flush()
+}
+
+// listItemPrinter prints each item of a list individually, for printers that
+// don't support lists
+type listItemPrinter struct {
+	printers.ResourcePrinter
+}
+
+func (p listItemPrinter) PrintObj(obj runtime.Object, output io.Writer) error {
+	if !meta.IsListType(obj) {
+		return p.ResourcePrinter.PrintObj(obj, output)
+	}
+
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := p.ResourcePrinter.PrintObj(item, output); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,258 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/exec"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workloadsv1alpha1 "github.com/gocardless/theatre/v3/apis/workloads/v1alpha1"
+)
+
+var _ = Describe("ConsoleSlice", func() {
+	Describe("PrintAs", func() {
+		var (
+			consoles ConsoleSlice
+			format   string
+			output   *bytes.Buffer
+			err      error
+		)
+
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			consoles = ConsoleSlice{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "console-a", Namespace: "default"},
+					Spec: workloadsv1alpha1.ConsoleSpec{
+						User:               "alice@example.com",
+						ConsoleTemplateRef: corev1.LocalObjectReference{Name: "template"},
+					},
+					Status: workloadsv1alpha1.ConsoleStatus{Phase: workloadsv1alpha1.ConsoleRunning},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "console-b", Namespace: "default"},
+					Spec: workloadsv1alpha1.ConsoleSpec{
+						User:               "bob@example.com",
+						ConsoleTemplateRef: corev1.LocalObjectReference{Name: "template"},
+					},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = consoles.PrintAs(output, format)
+		})
+
+		Context("with the default format", func() {
+			BeforeEach(func() { format = "" })
+
+			It("prints a table", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(HavePrefix("NAME"))
+				Expect(output.String()).To(ContainSubstring("console-a"))
+				Expect(output.String()).NotTo(ContainSubstring("TEMPLATE"))
+			})
+
+			Context("with no consoles", func() {
+				BeforeEach(func() { consoles = ConsoleSlice{} })
+
+				It("prints nothing", func() {
+					Expect(output.String()).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("with the wide format", func() {
+			BeforeEach(func() { format = "wide" })
+
+			It("prints additional columns", func() {
+				Expect(output.String()).To(ContainSubstring("TEMPLATE"))
+			})
+		})
+
+		Context("with the json format", func() {
+			BeforeEach(func() { format = "json" })
+
+			It("prints a console list", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(ContainSubstring(`"kind": "ConsoleList"`))
+				Expect(output.String()).To(ContainSubstring(`"kind": "Console"`))
+			})
+		})
+
+		Context("with the yaml format", func() {
+			BeforeEach(func() { format = "yaml" })
+
+			It("prints a console list", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(ContainSubstring("kind: ConsoleList"))
+			})
+		})
+
+		Context("with the name format", func() {
+			BeforeEach(func() { format = "name" })
+
+			It("prints the resource name of each console", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(Equal(
+					"console.workloads.crd.gocardless.com/console-a\nconsole.workloads.crd.gocardless.com/console-b\n",
+				))
+			})
+		})
+
+		Context("with a jsonpath template", func() {
+			BeforeEach(func() { format = "jsonpath={.items[*].spec.user}" })
+
+			It("prints the template", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output.String()).To(Equal("alice@example.com bob@example.com"))
+			})
+		})
+
+		Context("with an unsupported format", func() {
+			BeforeEach(func() { format = "xml" })
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("unsupported output format: xml"))
+			})
+		})
+	})
+})
+
+var _ = Describe("isTransportError", func() {
+	DescribeTable("classifies attach errors",
+		func(err error, expected bool) {
+			Expect(isTransportError(err)).To(Equal(expected))
+		},
+		Entry("unexpected EOF", fmt.Errorf("stream: %w", io.ErrUnexpectedEOF), true),
+		Entry("closed connection", fmt.Errorf("read: %w", net.ErrClosed), true),
+		Entry("connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true),
+		Entry("broken pipe", fmt.Errorf("write: %w", syscall.EPIPE), true),
+		Entry("closed error stream",
+			errors.New("error reading from error stream: read tcp 10.0.0.1:443: connection reset by peer"), true),
+		Entry("failed request", errors.New("error sending request: dial tcp: i/o timeout"), true),
+		Entry("context cancelled", context.Canceled, false),
+		Entry("context deadline", context.DeadlineExceeded, false),
+		Entry("process exit", exec.CodeExitError{Err: errors.New("command terminated with exit code 1"), Code: 1}, false),
+		Entry("denied attach",
+			errors.New("unable to upgrade connection: admission webhook denied the request"), false),
+		Entry("forbidden",
+			apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "console", errors.New("no")), false),
+		Entry("bad request", apierrors.NewBadRequest("container console is not valid"), false),
+		Entry("missing container", errors.New("container debug not found in pod console"), false),
+	)
+})
+
+var _ = Describe("Runner", func() {
+	Describe("waitToReattach", func() {
+		var (
+			ctx           context.Context
+			runner        *Runner
+			csl           *workloadsv1alpha1.Console
+			objects       []client.Object
+			backoff       wait.Backoff
+			output        *bytes.Buffer
+			pod           *corev1.Pod
+			containerName string
+			ok            bool
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			output = &bytes.Buffer{}
+			backoff = wait.Backoff{Duration: time.Millisecond, Steps: 3}
+
+			csl = &workloadsv1alpha1.Console{
+				ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "default"},
+				Status: workloadsv1alpha1.ConsoleStatus{
+					Phase:   workloadsv1alpha1.ConsoleRunning,
+					PodName: "console-pod",
+				},
+			}
+			objects = []client.Object{
+				csl,
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "console-pod", Namespace: "default"},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "console", TTY: true}},
+					},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(workloadsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+			runner = &Runner{kubeClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()}
+			pod, containerName, ok = runner.waitToReattach(
+				ctx, csl, &backoff, io.ErrUnexpectedEOF, IOStreams{ErrOut: output},
+			)
+		})
+
+		Context("when the console is still running", func() {
+			It("returns the pod to reattach to", func() {
+				Expect(ok).To(BeTrue())
+				Expect(pod.Name).To(Equal("console-pod"))
+				Expect(containerName).To(Equal("console"))
+				Expect(output.String()).To(ContainSubstring("Reconnected to console console"))
+			})
+		})
+
+		Context("when the console has stopped", func() {
+			BeforeEach(func() {
+				csl.Status.Phase = workloadsv1alpha1.ConsoleStopped
+			})
+
+			It("gives up", func() {
+				Expect(ok).To(BeFalse())
+				Expect(output.String()).To(ContainSubstring("Console console is no longer running (phase: Stopped)"))
+			})
+		})
+
+		Context("when the pod can't be found", func() {
+			BeforeEach(func() {
+				objects = objects[:1]
+			})
+
+			It("gives up once the backoff is exhausted", func() {
+				Expect(ok).To(BeFalse())
+				Expect(backoff.Steps).To(BeZero())
+				Expect(output.String()).To(ContainSubstring("Giving up reconnecting to console console"))
+			})
+		})
+
+		Context("when the context is cancelled", func() {
+			BeforeEach(func() {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(ctx)
+				cancel()
+				backoff.Duration = time.Minute
+			})
+
+			It("stops waiting", func() {
+				Expect(ok).To(BeFalse())
+				Expect(output.String()).NotTo(ContainSubstring("Reconnected"))
+			})
+		})
+	})
+})